@@ -1,3 +1,10 @@
 module shortlink
 
 go 1.22.0
+
+require (
+	golang.org/x/crypto v0.31.0
+	golang.org/x/net v0.33.0
+)
+
+require golang.org/x/text v0.21.0 // indirect
@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ndjsonContentType = "application/x-ndjson"
+	// maxNDJSONLine NDJSON 导入中单行(一个条目)的最大长度
+	maxNDJSONLine = 1 << 20
+	// streamIdleTimeout 流式导入时读取下一行或写出下一条结果的超时，每处理一行顺延一次
+	streamIdleTimeout = 30 * time.Second
+)
+
+type BatchCreateRequest struct {
+	Items []CreateShortLinkRequest `json:"items"`
+}
+
+// BatchItemResult 是一个条目的处理结果，ShortCode 与 Error 只有一个非空
+type BatchItemResult struct {
+	// Index 条目在请求中的位置(从 0 开始)，NDJSON 导入中为行号减一(不含空行)
+	Index     int    `json:"index"`
+	ShortCode string `json:"short_code,omitempty"`
+	// ShortURL 与单个创建返回的 short_url 相同，未配置 BaseURL 时省略
+	ShortURL string   `json:"short_url,omitempty"`
+	Error    *Problem `json:"error,omitempty"`
+}
+
+type BatchCreateResponse struct {
+	Created int               `json:"created"`
+	Failed  int               `json:"failed"`
+	Results []BatchItemResult `json:"results"`
+}
+
+// BatchCreateLinks 批量创建短链接 POST /api/links:batch
+// 请求体为 JSON 时一次返回全部结果；Content-Type 为 application/x-ndjson 时每行一个条目，
+// 结果按完成顺序逐行写回，适合超大导入。单个条目失败不影响其他条目
+func (l *LinkAPI) BatchCreateLinks(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == ndjsonContentType {
+		l.streamBatch(w, r)
+		return
+	}
+	var req BatchCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		l.logger.Printf("ERROR: Failed to decode request body: %v\n", err)
+		l.writeProblem(w, r, decodeProblem(err))
+		return
+	}
+	if len(req.Items) == 0 {
+		l.writeProblem(w, r, invalidRequest("No items to create"))
+		return
+	}
+	if len(req.Items) > l.batchMaxItems {
+		l.logger.Printf("WARN: Rejected batch of %d items from %s\n", len(req.Items), r.RemoteAddr)
+		l.writeProblem(w, r, newProblem(http.StatusRequestEntityTooLarge, "too_many_items", "Too many items",
+			fmt.Sprintf("%d items exceed the limit of %d, use %s for larger imports", len(req.Items), l.batchMaxItems, ndjsonContentType)))
+		return
+	}
+	l.logger.Printf("INFO: Received request to create %d short links from %s\n", len(req.Items), r.RemoteAddr)
+
+	resp := BatchCreateResponse{Results: make([]BatchItemResult, len(req.Items))}
+	sem := make(chan struct{}, l.batchWorkers)
+	var wg sync.WaitGroup
+	for i, item := range req.Items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			resp.Results[i] = l.createItem(r.Context(), i, item)
+		}()
+	}
+	wg.Wait()
+	for _, res := range resp.Results {
+		if res.Error != nil {
+			resp.Failed++
+		}
+	}
+	resp.Created = len(resp.Results) - resp.Failed
+	l.logger.Printf("INFO: Batch from %s done. Created: %d, Failed: %d\n", r.RemoteAddr, resp.Created, resp.Failed)
+	l.writeJSON(w, http.StatusOK, resp)
+}
+
+// streamBatch 边读边处理 NDJSON 请求体，读取与处理并行，已完成的结果立即写回
+func (l *LinkAPI) streamBatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	rc := http.NewResponseController(w)
+	// HTTP/1.1 默认在开始写响应后不再读取请求体，流式导入需要同时读写；
+	// 服务器的读写超时按整个请求计算，这里改为按行顺延。测试中的 ResponseRecorder 不支持这些操作，忽略错误
+	rc.EnableFullDuplex()
+	rc.SetReadDeadline(time.Now().Add(streamIdleTimeout))
+	rc.SetWriteDeadline(time.Now().Add(streamIdleTimeout))
+	w.Header().Set("Content-Type", ndjsonContentType)
+	w.WriteHeader(http.StatusOK)
+	l.logger.Printf("INFO: Received streaming batch from %s\n", r.RemoteAddr)
+
+	results := make(chan BatchItemResult, l.batchWorkers)
+	go func() {
+		defer close(results)
+		sem := make(chan struct{}, l.batchWorkers)
+		var wg sync.WaitGroup
+		defer wg.Wait()
+		scanner := bufio.NewScanner(r.Body)
+		scanner.Buffer(make([]byte, 0, 64<<10), maxNDJSONLine)
+		index := 0
+		for scanner.Scan() {
+			rc.SetReadDeadline(time.Now().Add(streamIdleTimeout))
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			i := index
+			index++
+			var item CreateShortLinkRequest
+			if err := json.Unmarshal(line, &item); err != nil {
+				results <- BatchItemResult{Index: i, Error: invalidItem("Failed to decode item: " + err.Error())}
+				continue
+			}
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				results <- l.createItem(ctx, i, item)
+			}()
+		}
+		if err := scanner.Err(); err != nil {
+			l.logger.Printf("ERROR: Failed to read streaming batch from %s after %d items: %v\n", r.RemoteAddr, index, err)
+			results <- BatchItemResult{Index: index, Error: invalidItem("Failed to read request body: " + err.Error())}
+		}
+	}()
+
+	enc := json.NewEncoder(w)
+	created, failed := 0, 0
+	for res := range results {
+		if res.Error != nil {
+			failed++
+		} else {
+			created++
+		}
+		rc.SetWriteDeadline(time.Now().Add(streamIdleTimeout))
+		// 客户端断开后继续消费结果，让已开始的条目处理完
+		if err := enc.Encode(res); err == nil {
+			rc.Flush()
+		}
+	}
+	l.logger.Printf("INFO: Streaming batch from %s done. Created: %d, Failed: %d\n", r.RemoteAddr, created, failed)
+}
+
+func invalidItem(detail string) *Problem {
+	p := newProblem(http.StatusBadRequest, "invalid_item", "Invalid item", detail)
+	return &p
+}
+
+// createItem 创建一个条目，错误按 problemFor 归类后随结果返回
+func (l *LinkAPI) createItem(ctx context.Context, index int, item CreateShortLinkRequest) BatchItemResult {
+	if strings.TrimSpace(item.LongURL) == "" {
+		p := problemFor(errEmptyLongURL)
+		return BatchItemResult{Index: index, Error: &p}
+	}
+	link, err := l.service.Create(ctx, item.params())
+	if err != nil {
+		p := problemFor(err)
+		if p.Status >= http.StatusInternalServerError {
+			l.logger.Printf("ERROR: Failed to save short link in batch. Index: %d, Error: %v\n", index, err)
+		}
+		return BatchItemResult{Index: index, Error: &p}
+	}
+	return BatchItemResult{Index: index, ShortCode: link.ShortCode, ShortURL: l.shortURL(link.ShortCode)}
+}
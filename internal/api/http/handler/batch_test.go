@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shortlink/internal/shortener"
+)
+
+func TestLinkAPI_BatchCreateLinks(t *testing.T) {
+	api, svc := newTestAPI(t, Options{BatchMaxItems: 5, BatchConcurrency: 2, BaseURL: "https://sho.rt"})
+	body := `{"items":[
+		{"long_url":"https://example.com/a"},
+		{"long_url":"javascript:alert(1)"},
+		{"long_url":"https://example.com/b","alias":"docs/b"},
+		{"long_url":"https://example.com/c","alias":"docs/b"},
+		{"long_url":" "}
+	]}`
+	rec := httptest.NewRecorder()
+	api.BatchCreateLinks(rec, httptest.NewRequest(http.MethodPost, "/api/links:batch", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("BatchCreateLinks() status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	var resp BatchCreateResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Created != 2 || resp.Failed != 3 || len(resp.Results) != 5 {
+		t.Fatalf("BatchCreateLinks() = %+v, want 2 created and 3 failed", resp)
+	}
+	// 两个条目争用同一别名，只有一个成功
+	b, c := resp.Results[2], resp.Results[3]
+	if (b.ShortCode == "docs/b") == (c.ShortCode == "docs/b") {
+		t.Errorf("alias results = %+v, %+v, want exactly one docs/b", b, c)
+	}
+	conflict := c
+	if c.ShortCode != "" {
+		conflict = b
+	}
+	wantCodes := map[int]string{1: "invalid_url", 4: "invalid_url"}
+	for i, res := range resp.Results {
+		if res.Index != i {
+			t.Errorf("results[%d].Index = %d", i, res.Index)
+		}
+		if want, ok := wantCodes[i]; ok && (res.Error == nil || res.Error.Code != want || res.Error.Status != http.StatusBadRequest) {
+			t.Errorf("results[%d] = %+v, want error %s", i, res, want)
+		}
+	}
+	if conflict.Error == nil || conflict.Error.Code != "conflict" || conflict.Error.Status != http.StatusConflict {
+		t.Errorf("alias conflict result = %+v", conflict)
+	}
+	for _, res := range resp.Results {
+		if want := "https://sho.rt/" + res.ShortCode; res.ShortCode != "" && res.ShortURL != want {
+			t.Errorf("results[%d].ShortURL = %q, want %q", res.Index, res.ShortURL, want)
+		}
+		if res.ShortCode == "" && res.ShortURL != "" {
+			t.Errorf("failed results[%d] has ShortURL %q", res.Index, res.ShortURL)
+		}
+	}
+	if _, err := svc.Resolve(context.Background(), shortener.Visit{ShortCode: resp.Results[0].ShortCode}); err != nil {
+		t.Errorf("created link does not resolve: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "malformed JSON", body: `{"items":[`, wantStatus: http.StatusBadRequest},
+		{name: "no items", body: `{"items":[]}`, wantStatus: http.StatusBadRequest},
+		{name: "too many items", body: `{"items":[{},{},{},{},{},{}]}`, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			api.BatchCreateLinks(rec, httptest.NewRequest(http.MethodPost, "/api/links:batch", strings.NewReader(tt.body)))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("BatchCreateLinks() status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestLinkAPI_BatchCreateLinks_Stream(t *testing.T) {
+	// 条目数超过 BatchMaxItems 也可以流式导入
+	api, _ := newTestAPI(t, Options{BatchMaxItems: 1, BatchConcurrency: 4})
+	srv := httptest.NewServer(http.HandlerFunc(api.BatchCreateLinks))
+	defer srv.Close()
+
+	body, bodyWriter := io.Pipe()
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, srv.URL, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	respCh := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("Do() error = %v", err)
+			close(respCh)
+			return
+		}
+		respCh <- resp
+	}()
+
+	// 先发一行并读到它的结果，证明结果在请求体结束前就写回
+	fmt.Fprintln(bodyWriter, `{"long_url":"https://example.com/first"}`)
+	resp := <-respCh
+	if resp == nil {
+		t.FailNow()
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	results := bufio.NewScanner(resp.Body)
+	var first BatchItemResult
+	if !results.Scan() || json.Unmarshal(results.Bytes(), &first) != nil || first.Index != 0 || first.ShortCode == "" {
+		t.Fatalf("first result = %s", results.Text())
+	}
+
+	go func() {
+		fmt.Fprintln(bodyWriter, "")
+		fmt.Fprintln(bodyWriter, `not json`)
+		for i := range 10 {
+			fmt.Fprintf(bodyWriter, `{"long_url":"https://example.com/%d"}`+"\n", i)
+		}
+		fmt.Fprintln(bodyWriter, `{"long_url":"ftp://example.com/"}`)
+		bodyWriter.Close()
+	}()
+
+	got := map[int]BatchItemResult{}
+	for results.Scan() {
+		var res BatchItemResult
+		if err := json.Unmarshal(results.Bytes(), &res); err != nil {
+			t.Fatalf("decode result line %q: %v", results.Text(), err)
+		}
+		got[res.Index] = res
+	}
+	if len(got) != 12 {
+		t.Fatalf("got %d results, want 12: %v", len(got), got)
+	}
+	if res := got[1]; res.Error == nil || res.Error.Code != "invalid_item" {
+		t.Errorf("malformed line result = %+v", res)
+	}
+	if res := got[12]; res.Error == nil || res.Error.Code != "invalid_url" {
+		t.Errorf("ftp line result = %+v", res)
+	}
+	for i := 2; i < 12; i++ {
+		if got[i].ShortCode == "" {
+			t.Errorf("results[%d] = %+v, want created", i, got[i])
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"shortlink/internal/api/http/middleware"
+	"shortlink/internal/shortener"
+)
+
+const (
+	problemContentType = "application/problem+json"
+	// problemTypePrefix 问题类型 URI 的前缀，后接稳定的错误码，客户端按 type 分支
+	problemTypePrefix = "urn:shortlink:problem:"
+)
+
+// statusByKind 是领域错误类别到 HTTP 状态码的唯一映射，API 与浏览器响应都以此为准
+var statusByKind = map[shortener.Kind]int{
+	shortener.KindInvalid:      http.StatusBadRequest,
+	shortener.KindUnauthorized: http.StatusUnauthorized,
+	shortener.KindNotFound:     http.StatusNotFound,
+	shortener.KindConflict:     http.StatusConflict,
+	shortener.KindGone:         http.StatusGone,
+	shortener.KindRateLimited:  http.StatusTooManyRequests,
+	shortener.KindUnavailable:  http.StatusServiceUnavailable,
+	shortener.KindInternal:     http.StatusInternalServerError,
+}
+
+// Problem 是 RFC 7807 格式的错误响应(application/problem+json)，批量接口逐条返回同样的结构
+type Problem struct {
+	// Type 标识错误类型的 URI，同一类错误总是相同
+	Type string `json:"type"`
+	// Title 错误类型的简短描述，同一 Type 总是相同
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	// Detail 本次错误的具体说明，5xx 不包含内部细节
+	Detail string `json:"detail,omitempty"`
+	// Instance 出错的请求路径
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	// Code 与 Type 末尾相同的错误码，便于不解析 URI 的客户端使用
+	Code      string `json:"code"`
+	Retryable bool   `json:"retryable,omitempty"`
+	// Errors 校验失败的字段
+	Errors []FieldProblem `json:"errors,omitempty"`
+}
+
+// FieldProblem 描述一个请求字段的校验错误
+type FieldProblem struct {
+	// Field 字段名，嵌套字段形如 rules[1].condition
+	Field  string `json:"field"`
+	Detail string `json:"detail"`
+}
+
+func newProblem(status int, code, title, detail string) Problem {
+	return Problem{Type: problemTypePrefix + code, Title: title, Status: status, Detail: detail, Code: code}
+}
+
+// 请求本身(而非领域规则)不合法时的错误
+var (
+	errMethodNotAllowed = newProblem(http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", "The endpoint does not support this method; see the Allow header")
+	// errNoEndpoint /api/ 下没有对应接口的路径，常见原因是短码中的 / 没有编码
+	errNoEndpoint = newProblem(http.StatusNotFound, "not_found", "Not found", "No API endpoint matches this path. Percent-encode '/' in short codes as %2F")
+	// errEmptyLongURL 在调用 Service 之前拒绝空的 long_url，与 Service 的校验错误使用同一类型
+	errEmptyLongURL = &shortener.FieldError{Field: "long_url", Err: shortener.ErrInvalidLongURL}
+)
+
+func invalidRequest(detail string) Problem {
+	return newProblem(http.StatusBadRequest, "invalid_request", "Invalid request", detail)
+}
+
+// decodeProblem 描述请求体解码失败，字段类型不匹配时指出字段
+func decodeProblem(err error) Problem {
+	p := invalidRequest("Failed to decode request body")
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		p.Errors = []FieldProblem{{Field: typeErr.Field, Detail: "must be a JSON " + typeErr.Value + " of type " + typeErr.Type.String()}}
+	}
+	return p
+}
+
+// problemFor 把 Service 返回的错误转换为 Problem
+func problemFor(err error) Problem {
+	e := shortener.AsError(err)
+	status, ok := statusByKind[e.Kind]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	p := newProblem(status, e.Code, capitalize(e.Message), err.Error())
+	p.Retryable = e.Retryable
+	if status >= http.StatusInternalServerError {
+		p.Detail = ""
+		return p
+	}
+	var fe *shortener.FieldError
+	if errors.As(err, &fe) {
+		p.Errors = []FieldProblem{{Field: fe.Field, Detail: fe.Err.Error()}}
+	}
+	return p
+}
+
+func capitalize(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[n:]
+}
+
+// writeAPIError 把 Service 返回的错误写为 problem+json 响应
+func (l *LinkAPI) writeAPIError(w http.ResponseWriter, r *http.Request, err error) {
+	p := problemFor(err)
+	switch {
+	case p.Status >= http.StatusInternalServerError:
+		l.logger.Printf("ERROR: Request %s %s from %s failed. RequestID: %s, Error: %v\n", r.Method, r.URL.Path, r.RemoteAddr, requestID(w, r), err)
+	case p.Status != http.StatusNotFound:
+		l.logger.Printf("WARN: Rejected request %s %s from %s. Code: %s, Error: %v\n", r.Method, r.URL.Path, r.RemoteAddr, p.Code, err)
+	}
+	l.writeProblem(w, r, p)
+}
+
+func (l *LinkAPI) writeProblem(w http.ResponseWriter, r *http.Request, p Problem) {
+	p.Instance = r.URL.Path
+	p.RequestID = requestID(w, r)
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		l.logger.Printf("ERROR: Failed to encode problem response: %v\n", err)
+	}
+}
+
+// requestID 返回请求的 ID：经过 RequestID 中间件时使用它确定的 ID，
+// 否则(如测试中直接调用 handler)按同样的规则确定并写入响应头。同一请求多次调用返回同一个 ID
+func requestID(w http.ResponseWriter, r *http.Request) string {
+	if id := middleware.RequestIDFrom(r.Context()); id != "" {
+		return id
+	}
+	if id := w.Header().Get(middleware.RequestIDHeader); id != "" {
+		return id
+	}
+	id := middleware.NewRequestID(r)
+	w.Header().Set(middleware.RequestIDHeader, id)
+	return id
+}
+
+// errPanic 是 handler panic 后写出的错误，5xx 响应不包含它的内容
+var errPanic = errors.New("handler: panic")
+
+// InternalError 写出 500 问题详情，供恢复 panic 的中间件在 API 路由上使用
+func (l *LinkAPI) InternalError(w http.ResponseWriter, r *http.Request) {
+	l.writeProblem(w, r, problemFor(errPanic))
+}
+
+// InternalErrorPage 写出 500 错误页，供恢复 panic 的中间件在面向浏览器的路由上使用
+func (l *LinkAPI) InternalErrorPage(w http.ResponseWriter, r *http.Request) {
+	l.renderErrorPage(w, r, http.StatusInternalServerError)
+}
+
+// NotFound 写出 404 问题详情，供 server 处理 /api/ 下没有对应接口的路径
+func (l *LinkAPI) NotFound(w http.ResponseWriter, r *http.Request) {
+	l.writeProblem(w, r, errNoEndpoint)
+}
+
+// MethodNotAllowed 写出 405 问题详情，allow 是该路径支持的方法，写入 Allow 响应头
+func (l *LinkAPI) MethodNotAllowed(w http.ResponseWriter, r *http.Request, allow []string) {
+	w.Header().Set("Allow", strings.Join(allow, ", "))
+	l.writeProblem(w, r, errMethodNotAllowed)
+}
+
+// writeResolveError 把 Service.Resolve 的错误转换为面向浏览器的 HTML 错误页
+func (l *LinkAPI) writeResolveError(w http.ResponseWriter, r *http.Request, shortCode string, err error) {
+	if errors.Is(err, shortener.ErrPasswordRequired) {
+		l.renderPasswordForm(w, r, "", http.StatusOK)
+		return
+	}
+	p := problemFor(err)
+	if p.Status >= http.StatusInternalServerError {
+		l.logger.Printf("ERROR: Failed to get long URL for redirect. RequestID: %s, Error: %v\n", requestID(w, r), err)
+	}
+	l.renderErrorPage(w, r, p.Status)
+}
+
+// errorPages 是面向浏览器的错误说明，按状态码区分即可
+var errorPages = map[int]errorPageData{
+	http.StatusBadRequest:          {Title: "Invalid link", Message: "This link is missing some information or has an invalid path."},
+	http.StatusNotFound:            {Title: "Link not found", Message: "This short link does not exist or is not active yet."},
+	http.StatusConflict:            {Title: "Link not available", Message: "This short link cannot be used right now."},
+	http.StatusGone:                {Title: "Link no longer available", Message: "This short link has expired or its destination is no longer allowed."},
+	http.StatusTooManyRequests:     {Title: "Too many requests", Message: "Please wait a moment and try again."},
+	http.StatusServiceUnavailable:  {Title: "Temporarily unavailable", Message: "We could not open this link right now. Please try again shortly."},
+	http.StatusInternalServerError: {Title: "Something went wrong", Message: "We could not open this link. Please try again later."},
+}
+
+type errorPageData struct {
+	Title     string
+	Message   string
+	Status    int
+	RequestID string
+}
+
+func (l *LinkAPI) renderErrorPage(w http.ResponseWriter, r *http.Request, status int) {
+	data, ok := errorPages[status]
+	if !ok {
+		data = errorPageData{Title: http.StatusText(status)}
+	}
+	data.Status = status
+	data.RequestID = requestID(w, r)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := errorPageTemplate.Execute(w, data); err != nil {
+		l.logger.Printf("ERROR: Failed to render error page: %v\n", err)
+	}
+}
+
+var errorPageTemplate = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Title}}</title>
+<style>
+body{font-family:system-ui,sans-serif;display:flex;justify-content:center;margin-top:15vh;color:#222}
+main{width:24rem}small{color:#888}
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Title}}</h1>
+{{if .Message}}<p>{{.Message}}</p>{{end}}
+<p><small>Error {{.Status}} · Request ID {{.RequestID}}</small></p>
+</main>
+</body>
+</html>
+`))
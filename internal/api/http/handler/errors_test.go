@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shortlink/internal/shortener"
+)
+
+func TestProblemFor(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantStatus    int
+		wantCode      string
+		wantRetryable bool
+		wantField     string
+	}{
+		{name: "not found", err: fmt.Errorf("for code 'abc': %w", shortener.ErrLinkNotFound), wantStatus: http.StatusNotFound, wantCode: "link_not_found"},
+		{name: "too short", err: shortener.ErrShortCodeTooShort, wantStatus: http.StatusNotFound, wantCode: "short_code_invalid"},
+		{name: "invalid url", err: &shortener.FieldError{Field: "teaser_url", Err: &shortener.InvalidURLError{Reason: "missing_host", Err: errors.New("no host")}}, wantStatus: http.StatusBadRequest, wantCode: "invalid_url", wantField: "teaser_url"},
+		{name: "invalid rule", err: &shortener.InvalidRuleError{Index: 2, Err: errors.New("bad")}, wantStatus: http.StatusBadRequest, wantCode: "invalid_rule"},
+		{name: "template value", err: &shortener.TemplateValueError{Name: "id", Reason: "missing"}, wantStatus: http.StatusBadRequest, wantCode: "invalid_template_value"},
+		{name: "password required", err: shortener.ErrPasswordRequired, wantStatus: http.StatusUnauthorized, wantCode: "password_required"},
+		{name: "conflict", err: fmt.Errorf("alias 'a': %w", shortener.ErrConflict), wantStatus: http.StatusConflict, wantCode: "conflict"},
+		{name: "expired", err: shortener.ErrLinkExpired, wantStatus: http.StatusGone, wantCode: "link_expired"},
+		{name: "rate limited", err: shortener.ErrTooManyAttempts, wantStatus: http.StatusTooManyRequests, wantCode: "too_many_attempts", wantRetryable: true},
+		{name: "storage", err: fmt.Errorf("for code 'abc': failed to find link: %w", shortener.ErrStorageUnavailable), wantStatus: http.StatusServiceUnavailable, wantCode: "storage_unavailable", wantRetryable: true},
+		{name: "unclassified", err: errors.New("boom"), wantStatus: http.StatusInternalServerError, wantCode: "internal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := problemFor(tt.err)
+			if got.Status != tt.wantStatus || got.Code != tt.wantCode || got.Retryable != tt.wantRetryable {
+				t.Errorf("problemFor() = %+v, want status %d code %s retryable %v", got, tt.wantStatus, tt.wantCode, tt.wantRetryable)
+			}
+			if got.Type != "urn:shortlink:problem:"+tt.wantCode || got.Title == "" {
+				t.Errorf("problemFor() type = %q, title = %q", got.Type, got.Title)
+			}
+			if got.Status >= http.StatusInternalServerError && strings.Contains(got.Detail, "boom") {
+				t.Errorf("problemFor() detail %q leaks the internal cause", got.Detail)
+			}
+			if tt.wantField != "" && (len(got.Errors) != 1 || got.Errors[0].Field != tt.wantField) {
+				t.Errorf("problemFor() errors = %+v, want field %s", got.Errors, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestLinkAPI_ProblemResponses(t *testing.T) {
+	api, _ := newTestAPI(t, Options{})
+	tests := []struct {
+		name       string
+		call       func(w http.ResponseWriter, r *http.Request)
+		body       string
+		wantStatus int
+		wantCode   string
+		wantField  string
+	}{
+		{name: "malformed body", call: api.CreateLink, body: `{`, wantStatus: http.StatusBadRequest, wantCode: "invalid_request"},
+		{name: "wrong field type", call: api.CreateLink, body: `{"long_url":"https://example.com","max_visits":"ten"}`, wantStatus: http.StatusBadRequest, wantCode: "invalid_request", wantField: "max_visits"},
+		{name: "empty long url", call: api.CreateLink, body: `{"long_url":""}`, wantStatus: http.StatusBadRequest, wantCode: "invalid_url", wantField: "long_url"},
+		{name: "invalid rule condition", call: api.CreateLink, body: `{"long_url":"https://example.com","rules":[{"condition":"os ==","long_url":"https://example.com/x"}]}`,
+			wantStatus: http.StatusBadRequest, wantCode: "invalid_rule", wantField: "rules[0].condition"},
+		{name: "invalid variant", call: api.CreateLink, body: `{"long_url":"https://example.com","variants":[{"name":"a b","long_url":"https://example.com/a","weight":1}]}`,
+			wantStatus: http.StatusBadRequest, wantCode: "invalid_option", wantField: "variants[0].name"},
+		{name: "unknown link", call: api.ListVersions, wantStatus: http.StatusNotFound, wantCode: "link_not_found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/links", strings.NewReader(tt.body))
+			req.SetPathValue("code", "missing1")
+			req.Header.Set("X-Request-ID", "req-42")
+			rec := httptest.NewRecorder()
+			tt.call(rec, req)
+			var p Problem
+			if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+				t.Fatalf("decode problem: %v", err)
+			}
+			if rec.Code != tt.wantStatus || p.Status != tt.wantStatus || p.Code != tt.wantCode || p.Type != "urn:shortlink:problem:"+tt.wantCode {
+				t.Errorf("status = %d, problem = %+v, want %d %s", rec.Code, p, tt.wantStatus, tt.wantCode)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			if p.Instance != "/api/links" || p.RequestID != "req-42" || rec.Header().Get("X-Request-ID") != "req-42" {
+				t.Errorf("instance = %q, request id = %q / %q", p.Instance, p.RequestID, rec.Header().Get("X-Request-ID"))
+			}
+			if tt.wantField != "" && (len(p.Errors) != 1 || p.Errors[0].Field != tt.wantField) {
+				t.Errorf("errors = %+v, want field %s", p.Errors, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestLinkAPI_ErrorPages(t *testing.T) {
+	api, svc := newTestAPI(t, Options{})
+	link, err := svc.Create(context.Background(), shortener.CreateParams{LongURL: "https://example.com/once", MaxVisits: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.RedirectLink(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/"+link.ShortCode, nil))
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantTitle  string
+	}{
+		{name: "unknown code", path: "/missing1", wantStatus: http.StatusNotFound, wantTitle: "Link not found"},
+		{name: "exhausted", path: "/" + link.ShortCode, wantStatus: http.StatusGone, wantTitle: "Link no longer available"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			api.RedirectLink(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("RedirectLink() status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+				t.Errorf("Content-Type = %q, want an HTML page", ct)
+			}
+			body := rec.Body.String()
+			if !strings.Contains(body, "<h1>"+tt.wantTitle+"</h1>") || !strings.Contains(body, rec.Header().Get("X-Request-ID")) {
+				t.Errorf("error page = %s", body)
+			}
+		})
+	}
+}
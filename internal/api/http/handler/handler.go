@@ -1,39 +1,171 @@
 package handler
 
 import (
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"shortlink/internal/idempotency"
+	"shortlink/internal/ratelimit"
 	"shortlink/internal/shortener"
+	"shortlink/internal/storage"
+	"shortlink/internal/targeting"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type LinkAPI struct {
-	service *shortener.Service
-	logger  *log.Logger
+	service         *shortener.Service
+	logger          *log.Logger
+	unlock          unlockSigner
+	passwordLimiter *ratelimit.Limiter
+	countries       targeting.CountryResolver
+	interstitial    bool
+	batchMaxItems   int
+	batchWorkers    int
+	idempotency     *idempotency.Store
+	baseURL         string
 }
 
-func NewLinkAPI(service *shortener.Service, l *log.Logger) *LinkAPI {
+// Options 是 LinkAPI 的可选配置，零值可用
+type Options struct {
+	// CookieSecret 用于签名解锁 cookie，为空时随机生成(进程重启后需要重新输入密码)
+	CookieSecret []byte
+	// UnlockTTL 输入正确密码后免输入的时长
+	UnlockTTL time.Duration
+	// PasswordAttempts 同一短码、同一客户端在 PasswordWindow 内最多尝试密码的次数
+	PasswordAttempts int
+	PasswordWindow   time.Duration
+	// CountryResolver 为定向规则中的 country 提供数据，为空时 country 总是空字符串
+	CountryResolver targeting.CountryResolver
+	// BrokenLinkInterstitial 为 true 时，被健康检查标记为失效的链接先展示提示页而不是直接跳转
+	BrokenLinkInterstitial bool
+	// BatchMaxItems 批量创建一次请求最多包含的条目数(NDJSON 流式导入不受此限制)
+	BatchMaxItems int
+	// BatchConcurrency 批量创建时同时处理的条目数
+	BatchConcurrency int
+	// IdempotencyTTL 按 Idempotency-Key 保存创建响应的时长
+	IdempotencyTTL time.Duration
+	// BaseURL 对外的短链接前缀(如 https://sho.rt)，响应中的 short_url 以它拼接；为空时不返回 short_url
+	BaseURL string
+}
+
+func NewLinkAPI(service *shortener.Service, l *log.Logger, opts Options) *LinkAPI {
 	if l == nil {
-		logger := log.New(os.Stdout, "[LinkAPI] ", log.LstdFlags|log.Lshortfile)
-		return &LinkAPI{
-			service: service,
-			logger:  logger,
+		l = log.New(os.Stdout, "[LinkAPI] ", log.LstdFlags|log.Lshortfile)
+	}
+	if len(opts.CookieSecret) == 0 {
+		opts.CookieSecret = make([]byte, 32)
+		if _, err := rand.Read(opts.CookieSecret); err != nil {
+			l.Fatalf("FATAL: Failed to generate cookie secret: %v", err)
 		}
 	}
+	if opts.UnlockTTL <= 0 {
+		opts.UnlockTTL = 15 * time.Minute
+	}
+	if opts.PasswordAttempts <= 0 {
+		opts.PasswordAttempts = 5
+	}
+	if opts.PasswordWindow <= 0 {
+		opts.PasswordWindow = 15 * time.Minute
+	}
+	if opts.BatchMaxItems <= 0 {
+		opts.BatchMaxItems = 500
+	}
+	if opts.BatchConcurrency <= 0 {
+		opts.BatchConcurrency = 8
+	}
+	if opts.IdempotencyTTL <= 0 {
+		opts.IdempotencyTTL = 24 * time.Hour
+	}
 	return &LinkAPI{
-		service: service,
-		logger:  l,
+		service:         service,
+		logger:          l,
+		unlock:          unlockSigner{secret: opts.CookieSecret, ttl: opts.UnlockTTL, now: time.Now},
+		passwordLimiter: ratelimit.New(opts.PasswordAttempts, opts.PasswordWindow, nil),
+		countries:       opts.CountryResolver,
+		interstitial:    opts.BrokenLinkInterstitial,
+		batchMaxItems:   opts.BatchMaxItems,
+		batchWorkers:    opts.BatchConcurrency,
+		idempotency:     idempotency.New(opts.IdempotencyTTL, nil),
+		baseURL:         strings.TrimRight(opts.BaseURL, "/"),
 	}
 }
 
 type CreateShortLinkRequest struct {
 	LongURL string `json:"long_url"`
+	// Password 可选，设置后访问短链接需要先输入密码
+	Password string `json:"password,omitempty"`
+	// MaxVisits 可选，链接在被访问这么多次后失效，1 表示一次性链接
+	MaxVisits int64 `json:"max_visits,omitempty"`
+	// NotBefore/NotAfter 可选(RFC 3339)，链接只在该时间窗口内有效
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	NotAfter  *time.Time `json:"not_after,omitempty"`
+	// TeaserURL 可选，生效前访问时跳转到此地址，需要同时设置 not_before
+	TeaserURL string `json:"teaser_url,omitempty"`
+	// Rules 可选，按顺序求值的定向规则，都不命中时跳转到 long_url
+	Rules []RedirectRule `json:"rules,omitempty"`
+	// Variants 可选，A/B 测试的加权目的地，访问者按权重分配并保持粘性
+	Variants []VariantRequest `json:"variants,omitempty"`
+	// Template 可选，为 true 时 long_url 是含 {占位符} 的模板，由查询参数或短码之后的路径段填充
+	Template bool `json:"template,omitempty"`
+	// QueryMode 可选，访问短链接时携带的查询参数如何带到目的地址：drop(默认)、append、merge
+	QueryMode string `json:"query_mode,omitempty"`
+	// Alias 可选，自定义短码，可以由 / 分隔为多段，例如 docs/api
+	Alias string `json:"alias,omitempty"`
+	// Prefix 可选，为 true 时短码之后的路径会拼接到 long_url 之后，例如 /docs/a/b -> long_url/a/b
+	Prefix bool `json:"prefix,omitempty"`
+	// Group 可选，链接分组，分组配置了默认打标策略时自动添加 UTM 参数
+	Group string `json:"group,omitempty"`
+	// TaggingPolicy 可选，显式指定的 UTM 打标策略名，优先于分组的默认策略
+	TaggingPolicy string `json:"tagging_policy,omitempty"`
+	// RedirectType 可选，跳转状态码：301/308 永久迁移，302/307 临时跳转，307/308 保留请求方法；默认使用服务配置
+	RedirectType int `json:"redirect_type,omitempty"`
+}
+
+// params 把请求转换为 Service.Create 的参数
+func (req CreateShortLinkRequest) params() shortener.CreateParams {
+	params := shortener.CreateParams{
+		LongURL:       req.LongURL,
+		Password:      req.Password,
+		MaxVisits:     req.MaxVisits,
+		TeaserURL:     req.TeaserURL,
+		Template:      req.Template,
+		QueryMode:     req.QueryMode,
+		Alias:         req.Alias,
+		Prefix:        req.Prefix,
+		Group:         req.Group,
+		TaggingPolicy: req.TaggingPolicy,
+		RedirectType:  req.RedirectType,
+	}
+	for _, rule := range req.Rules {
+		params.Rules = append(params.Rules, storage.RedirectRule{Condition: rule.Condition, LongURL: rule.LongURL})
+	}
+	for _, v := range req.Variants {
+		params.Variants = append(params.Variants, storage.Variant{Name: v.Name, LongURL: v.LongURL, Weight: v.Weight})
+	}
+	if req.NotBefore != nil {
+		params.NotBefore = *req.NotBefore
+	}
+	if req.NotAfter != nil {
+		params.NotAfter = *req.NotAfter
+	}
+	return params
+}
+
+type RedirectRule struct {
+	// Condition 规则条件，语法见 targeting 包，例如 platform == "ios"
+	Condition string `json:"condition"`
+	LongURL   string `json:"long_url"`
 }
 
 type CreateShortLinkResponse struct {
 	ShortCode string `json:"short_code"`
+	// ShortURL 可以直接分享的完整短链接，未配置 BaseURL 时省略
+	ShortURL string `json:"short_url,omitempty"`
 }
 
 // CreateLink 创建短链接 POST请求
@@ -42,31 +174,31 @@ func (l *LinkAPI) CreateLink(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	if r.Method != http.MethodPost {
 		l.logger.Printf("WARN: Invalid method for create link: %s from %s\n", r.Method, r.RemoteAddr)
-		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		l.MethodNotAllowed(w, r, []string{http.MethodPost})
 		return
 	}
 	var req CreateShortLinkRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		l.logger.Printf("ERROR: Failed to decode request body: %v\n", err)
-		http.Error(w, "Failed to decode request body", http.StatusBadRequest)
+		l.writeProblem(w, r, decodeProblem(err))
 		return
 	}
 	defer r.Body.Close()
 	if strings.TrimSpace(req.LongURL) == "" {
-		l.logger.Printf("ERROR: Long URL is empty")
-		http.Error(w, "Long URL is empty", http.StatusBadRequest)
+		l.writeAPIError(w, r, errEmptyLongURL)
 		return
 	}
 	l.logger.Printf("INFO: Received request to create short link from %s, LongURL: %s\n", r.RemoteAddr, req.LongURL)
 
-	shortCode, err := l.service.CreateShortLink(ctx, req.LongURL)
+	link, err := l.service.Create(ctx, req.params())
 	if err != nil {
-		l.logger.Printf("ERROR: Failed to create short link: %v\n", err)
-		http.Error(w, "Failed to create short link", http.StatusInternalServerError)
+		l.writeAPIError(w, r, err)
 		return
 	}
+	shortCode := link.ShortCode
 	resp := CreateShortLinkResponse{
 		ShortCode: shortCode,
+		ShortURL:  l.shortURL(shortCode),
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -81,28 +213,167 @@ func (l *LinkAPI) CreateLink(w http.ResponseWriter, r *http.Request) {
 
 func (l *LinkAPI) RedirectLink(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	shortCode := strings.TrimPrefix(r.URL.Path, "/")
-	l.logger.Printf("INFO: Received request to redirect short link from %s. ShortCode: %s, Path: %s\n", r.RemoteAddr, shortCode, r.URL.Path)
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	l.logger.Printf("INFO: Received request to redirect short link from %s. Path: %s\n", r.RemoteAddr, r.URL.Path)
 
 	if r.Method != http.MethodGet {
 		l.logger.Printf("ERROR: Only GET method is allowed")
-		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		l.renderErrorPage(w, r, http.StatusMethodNotAllowed)
 		return
 	}
 	// 基础路径检查，避免匹配到 /api/links,/healthz等
-	if shortCode == "" || shortCode == "api/links" || shortCode == "healthz" {
+	if !isShortCodePath(path) {
 		l.logger.Printf("INFO: Path is not a shortcode, treating as not found. Path: %s, from %s\n", r.URL.Path, r.RemoteAddr)
-		http.NotFound(w, r)
+		l.renderErrorPage(w, r, http.StatusNotFound)
+		return
+	}
+	// 短码可以包含 /，按最长匹配拆分出短码与其后的路径段(前缀链接与模板链接使用)
+	shortCode, segments, err := l.service.Locate(ctx, path)
+	if err != nil {
+		l.logger.Printf("ERROR: Failed to locate short link for path %s: %v\n", r.URL.Path, err)
+		l.writeResolveError(w, r, path, err)
 		return
 	}
-	longURL, err := l.service.GetAndTrackLongURL(ctx, shortCode)
-	l.logger.Printf("WARN: Service failed to get long URL for redirect from %s. ShortCode: %s, Error: %v\n", r.RemoteAddr, shortCode, err)
-	// 具体错误处理
+	l.follow(w, r, shortCode, segments)
+}
+
+// follow 解析一次访问并按链接的跳转类型跳转
+func (l *LinkAPI) follow(w http.ResponseWriter, r *http.Request, shortCode string, segments []string) {
+	redirect, err := l.service.Resolve(r.Context(), shortener.Visit{
+		ShortCode:    shortCode,
+		Unlocked:     l.unlocked(r, shortCode),
+		Target:       targeting.FromHTTP(r, l.countries),
+		Variant:      assignedVariant(r, shortCode),
+		ClientID:     visitorID(r),
+		Query:        r.URL.Query(),
+		PathSegments: segments,
+	})
 	if err != nil {
-		l.logger.Printf("ERROR: Failed to get long URL for redirect: %v\n", err)
-		http.Error(w, "Failed to get long URL for redirect", http.StatusInternalServerError)
+		l.logger.Printf("WARN: Service failed to get long URL for redirect from %s. ShortCode: %s, Error: %v\n", r.RemoteAddr, shortCode, err)
+		l.writeResolveError(w, r, shortCode, err)
+		return
+	}
+	if redirect.Variant != "" {
+		http.SetCookie(w, variantCookie(shortCode, redirect.Variant, r.TLS != nil))
+	}
+	if redirect.Broken && l.interstitial {
+		l.logger.Printf("INFO: Showing broken link interstitial for %s to %s\n", shortCode, r.RemoteAddr)
+		l.renderInterstitial(w, redirect.URL)
 		return
 	}
-	l.logger.Printf("INFO: Redirecting %s from %s to %s\n", shortCode, r.RemoteAddr, longURL)
-	http.Redirect(w, r, longURL, http.StatusFound)
+	l.logger.Printf("INFO: Redirecting %s from %s to %s with %d\n", shortCode, r.RemoteAddr, redirect.URL, redirect.Status)
+	l.writeRedirect(w, r, redirect)
+}
+
+// unlocked 判断请求是否携带了按链接当前密码签发的解锁 cookie，没有 cookie 时不读取链接
+func (l *LinkAPI) unlocked(r *http.Request, shortCode string) bool {
+	if _, err := r.Cookie(cookieName(unlockCookiePrefix, shortCode)); err != nil {
+		return false
+	}
+	link, err := l.service.Get(r.Context(), shortCode)
+	if err != nil || link.PasswordHash == "" {
+		return false
+	}
+	return l.unlock.valid(r, shortCode, link.PasswordHash)
+}
+
+// UnlockLink 处理密码表单提交 POST /{code}，验证成功后签发解锁 cookie 并跳转
+// 与 RedirectLink 一样按最长匹配拆分短码与其后的路径段，前缀链接与模板链接解锁后跳转到完整的目的地址
+func (l *LinkAPI) UnlockLink(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if !isShortCodePath(path) {
+		l.renderErrorPage(w, r, http.StatusNotFound)
+		return
+	}
+	shortCode, segments, err := l.service.Locate(ctx, path)
+	if err != nil {
+		l.logger.Printf("ERROR: Failed to locate short link for path %s: %v\n", r.URL.Path, err)
+		l.writeResolveError(w, r, path, err)
+		return
+	}
+	// 跳转类型为 307/308 的公开链接把 POST 转发到目的地址，客户端按状态码原样重发请求体
+	if l.forwardsPost(ctx, shortCode) {
+		l.follow(w, r, shortCode, segments)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 4<<10)
+	if err := r.ParseForm(); err != nil {
+		l.renderErrorPage(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// 按短码 + 客户端限流，防止在线穷举密码
+	limitKey := shortCode + "|" + clientIP(r)
+	if ok, retryAfter := l.passwordLimiter.Allow(limitKey); !ok {
+		l.logger.Printf("WARN: Too many password attempts from %s. ShortCode: %s\n", clientIP(r), shortCode)
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+		l.renderPasswordForm(w, r, "Too many attempts. Please try again later.", problemFor(shortener.ErrTooManyAttempts).Status)
+		return
+	}
+
+	// 先读出密码哈希再校验：校验期间密码被修改时，cookie 绑定的是旧哈希，不会放行新密码
+	link, err := l.service.Get(ctx, shortCode)
+	if err != nil {
+		l.writeResolveError(w, r, shortCode, err)
+		return
+	}
+	err = l.service.VerifyPassword(ctx, shortCode, r.PostForm.Get("password"))
+	switch {
+	case errors.Is(err, shortener.ErrPasswordMismatch):
+		l.logger.Printf("WARN: Incorrect password from %s. ShortCode: %s\n", clientIP(r), shortCode)
+		l.renderPasswordForm(w, r, "Incorrect password.", problemFor(err).Status)
+		return
+	case errors.Is(err, shortener.ErrLinkNotProtected):
+		http.Redirect(w, r, r.URL.RequestURI(), http.StatusSeeOther)
+		return
+	case err != nil:
+		l.writeResolveError(w, r, shortCode, err)
+		return
+	}
+
+	l.passwordLimiter.Reset(limitKey)
+	redirect, err := l.service.Resolve(ctx, shortener.Visit{
+		ShortCode:    shortCode,
+		Unlocked:     true,
+		Target:       targeting.FromHTTP(r, l.countries),
+		Variant:      assignedVariant(r, shortCode),
+		ClientID:     visitorID(r),
+		Query:        r.URL.Query(),
+		PathSegments: segments,
+	})
+	if err != nil {
+		l.logger.Printf("WARN: Service failed to get long URL after unlock. ShortCode: %s, Error: %v\n", shortCode, err)
+		l.writeResolveError(w, r, shortCode, err)
+		return
+	}
+	http.SetCookie(w, l.unlock.cookie(shortCode, link.PasswordHash, r.TLS != nil))
+	if redirect.Variant != "" {
+		http.SetCookie(w, variantCookie(shortCode, redirect.Variant, r.TLS != nil))
+	}
+	if redirect.Broken && l.interstitial {
+		l.renderInterstitial(w, redirect.URL)
+		return
+	}
+	l.logger.Printf("INFO: Unlocked %s from %s, redirecting to %s\n", shortCode, clientIP(r), redirect.URL)
+	// 无论链接的跳转类型如何都用 303，让浏览器以 GET 访问目的地址而不是重发密码表单
+	redirect.Status = http.StatusSeeOther
+	redirect.MaxAge = 0
+	l.writeRedirect(w, r, redirect)
+}
+
+// renderPasswordForm 渲染密码表单，表单提交到本次请求的完整路径与查询参数，
+// 解锁后跳转到与直接访问相同的目的地址(前缀链接的后续路径段、模板参数、透传的查询参数)
+func (l *LinkAPI) renderPasswordForm(w http.ResponseWriter, r *http.Request, message string, status int) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := passwordFormTemplate.Execute(w, passwordFormData{Action: r.URL.RequestURI(), Error: message}); err != nil {
+		l.logger.Printf("ERROR: Failed to render password form: %v\n", err)
+	}
+}
+
+// isShortCodePath 排除 /api/links、/healthz 等非短码路径
+func isShortCodePath(shortCode string) bool {
+	return shortCode != "" && shortCode != "api/links" && shortCode != "healthz"
 }
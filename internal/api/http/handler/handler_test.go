@@ -0,0 +1,931 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"shortlink/internal/idgen"
+	"shortlink/internal/shortener"
+	"shortlink/internal/storage"
+	"shortlink/internal/tagging"
+	"shortlink/internal/targeting"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTestAPI 使用真实的 MemoryStore 与生成器组装 LinkAPI(遵循宪法 2.3：拒绝 Mocks)
+func newTestAPI(t *testing.T, opts Options) (*LinkAPI, *shortener.Service) {
+	t.Helper()
+	logger := log.New(io.Discard, "", 0)
+	svc := shortener.NewService(shortener.Config{
+		Store:            storage.NewMemoryStore(),
+		Generator:        idgen.NewGenerator(),
+		Logger:           logger,
+		PasswordHashCost: bcrypt.MinCost,
+	})
+	return NewLinkAPI(svc, logger, opts), svc
+}
+
+func TestLinkAPI_CreateLink(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       Options
+		body       string
+		wantStatus int
+		wantBody   string
+		noBody     string
+	}{
+		{name: "valid URL", opts: Options{BaseURL: "https://sho.rt/"}, body: `{"long_url":"https://example.com/a"}`, wantStatus: http.StatusCreated, wantBody: `"short_url":"https://sho.rt/`},
+		{name: "no short_url without base URL", body: `{"long_url":"https://example.com/a"}`, wantStatus: http.StatusCreated, wantBody: `"short_code"`, noBody: "short_url"},
+		{name: "malformed JSON", body: `{`, wantStatus: http.StatusBadRequest},
+		{name: "empty URL", body: `{"long_url":"  "}`, wantStatus: http.StatusBadRequest},
+		{name: "javascript scheme", body: `{"long_url":"javascript:alert(1)"}`, wantStatus: http.StatusBadRequest, wantBody: "scheme_not_allowed"},
+		{name: "relative path", body: `{"long_url":"/etc/passwd"}`, wantStatus: http.StatusBadRequest, wantBody: "not_absolute"},
+		{name: "missing host", body: `{"long_url":"https:///x"}`, wantStatus: http.StatusBadRequest, wantBody: "missing_host"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, _ := newTestAPI(t, tt.opts)
+			req := httptest.NewRequest(http.MethodPost, "/api/links", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+
+			api.CreateLink(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("CreateLink() status = %d, want %d, body = %q", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("CreateLink() body = %q, want it to contain %q", rec.Body.String(), tt.wantBody)
+			}
+			if tt.noBody != "" && strings.Contains(rec.Body.String(), tt.noBody) {
+				t.Errorf("CreateLink() body = %q, want no %q", rec.Body.String(), tt.noBody)
+			}
+		})
+	}
+}
+
+func TestLinkAPI_ShortURL(t *testing.T) {
+	api, _ := newTestAPI(t, Options{BaseURL: "https://sho.rt/"})
+	tests := []struct {
+		code string
+		want string
+	}{
+		{code: "abc1234", want: "https://sho.rt/abc1234"},
+		{code: "docs/api", want: "https://sho.rt/docs/api"},
+		{code: "a b/c?d#e", want: "https://sho.rt/a%20b/c%3Fd%23e"},
+	}
+	for _, tt := range tests {
+		if got := api.shortURL(tt.code); got != tt.want {
+			t.Errorf("shortURL(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestLinkAPI_PasswordProtectedLink(t *testing.T) {
+	api, svc := newTestAPI(t, Options{PasswordAttempts: 2})
+	link, err := svc.Create(context.Background(), shortener.CreateParams{
+		LongURL:  "https://docs.internal.example/secret",
+		Password: "open sesame",
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	path := "/" + link.ShortCode
+
+	get := func(cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		api.RedirectLink(rec, req)
+		return rec
+	}
+	post := func(password, remoteAddr string) *httptest.ResponseRecorder {
+		form := url.Values{"password": {password}}
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		api.UnlockLink(rec, req)
+		return rec
+	}
+
+	// 未解锁：返回密码表单而不是跳转
+	rec := get()
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `type="password"`) {
+		t.Fatalf("GET without cookie: status = %d, want form; body = %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Location") != "" {
+		t.Fatalf("GET without cookie must not redirect")
+	}
+
+	// 错误密码：401，并消耗一次尝试
+	if rec := post("wrong", "192.0.2.1:1234"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("POST wrong password: status = %d, want 401", rec.Code)
+	}
+
+	// 正确密码：303 跳转并签发 cookie
+	rec = post("open sesame", "192.0.2.1:1234")
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "https://docs.internal.example/secret" {
+		t.Fatalf("POST correct password: status = %d, location = %q", rec.Code, rec.Header().Get("Location"))
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly {
+		t.Fatalf("POST correct password: cookies = %v, want one HttpOnly cookie", cookies)
+	}
+
+	// 携带 cookie 再次访问：直接跳转
+	if rec := get(cookies[0]); rec.Code != http.StatusFound {
+		t.Errorf("GET with unlock cookie: status = %d, want 302", rec.Code)
+	}
+	// 篡改 cookie：重新要求密码
+	forged := *cookies[0]
+	forged.Value = forged.Value + "x"
+	if rec := get(&forged); rec.Code != http.StatusOK || rec.Header().Get("Location") != "" {
+		t.Errorf("GET with forged cookie: status = %d, want password form", rec.Code)
+	}
+
+	// 另一个客户端超出尝试次数后被限流，即使密码正确
+	post("wrong", "198.51.100.7:1")
+	post("wrong", "198.51.100.7:1")
+	rec = post("open sesame", "198.51.100.7:1")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("POST after limit: status = %d, Retry-After = %q, want 429 with Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
+
+// TestLinkAPI_PasswordChangeRevokesUnlock 修改或重新设置密码后，之前签发的解锁 cookie 不再有效
+func TestLinkAPI_PasswordChangeRevokesUnlock(t *testing.T) {
+	api, svc := newTestAPI(t, Options{})
+	link, err := svc.Create(context.Background(), shortener.CreateParams{LongURL: "https://example.com/secret", Password: "old"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	path := "/" + link.ShortCode
+	unlock := func(password string) *http.Cookie {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(url.Values{"password": {password}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		api.UnlockLink(rec, req)
+		cookies := rec.Result().Cookies()
+		if rec.Code != http.StatusSeeOther || len(cookies) != 1 {
+			t.Fatalf("POST password: status = %d, cookies = %v, want 303 with the unlock cookie", rec.Code, cookies)
+		}
+		return cookies[0]
+	}
+	get := func(c *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.AddCookie(c)
+		rec := httptest.NewRecorder()
+		api.RedirectLink(rec, req)
+		return rec
+	}
+	patch := func(body string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPatch, "/api/links/"+link.ShortCode, strings.NewReader(body))
+		req.SetPathValue("code", link.ShortCode)
+		rec := httptest.NewRecorder()
+		api.UpdateLink(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("PATCH %s: status = %d, body = %q", body, rec.Code, rec.Body.String())
+		}
+	}
+
+	tests := []struct {
+		name  string
+		patch string
+	}{
+		{name: "password changed", patch: `{"password":"new"}`},
+		{name: "same password set again", patch: `{"password":"old"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch(`{"password":"old"}`)
+			cookie := unlock("old")
+			if rec := get(cookie); rec.Code != http.StatusFound {
+				t.Fatalf("GET with unlock cookie: status = %d, want 302", rec.Code)
+			}
+			patch(tt.patch)
+			if rec := get(cookie); rec.Code != http.StatusOK || rec.Header().Get("Location") != "" {
+				t.Errorf("GET with stale unlock cookie: status = %d, location = %q, want password form", rec.Code, rec.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestLinkAPI_LinkResource(t *testing.T) {
+	api, svc := newTestAPI(t, Options{BaseURL: "https://sho.rt/"})
+	link, err := svc.Create(context.Background(), shortener.CreateParams{LongURL: "https://example.com/a", Password: "secret", MaxVisits: 3})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	call := func(h http.HandlerFunc, method, code, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/links/"+code, strings.NewReader(body))
+		req.SetPathValue("code", code)
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		method     string
+		code       string
+		body       string
+		wantStatus int
+		wantBody   []string
+	}{
+		{name: "get", handler: api.GetLink, method: http.MethodGet, code: link.ShortCode, wantStatus: http.StatusOK,
+			wantBody: []string{`"short_url":"https://sho.rt/` + link.ShortCode + `"`, `"visit_count":0`, `"password_protected":true`, `"max_visits":3`}},
+		{name: "get does not count visits", handler: api.GetLink, method: http.MethodGet, code: link.ShortCode, wantStatus: http.StatusOK,
+			wantBody: []string{`"visit_count":0`}},
+		{name: "get unknown", handler: api.GetLink, method: http.MethodGet, code: "missing", wantStatus: http.StatusNotFound,
+			wantBody: []string{"link_not_found"}},
+		{name: "patch options keeps destination", handler: api.UpdateLink, method: http.MethodPatch, code: link.ShortCode,
+			body: `{"max_visits":null,"query_mode":"merge"}`, wantStatus: http.StatusOK,
+			wantBody: []string{`"long_url":"https://example.com/a"`, `"query_mode":"merge"`, `"version":1`}},
+		{name: "patch removes password", handler: api.UpdateLink, method: http.MethodPatch, code: link.ShortCode,
+			body: `{"password":null}`, wantStatus: http.StatusOK, wantBody: []string{`"password_protected":false`}},
+		{name: "patch null long_url", handler: api.UpdateLink, method: http.MethodPatch, code: link.ShortCode,
+			body: `{"long_url":null}`, wantStatus: http.StatusBadRequest, wantBody: []string{`"field":"long_url"`}},
+		{name: "patch invalid window", handler: api.UpdateLink, method: http.MethodPatch, code: link.ShortCode,
+			body: `{"not_before":"2030-01-02T00:00:00Z","not_after":"2030-01-01T00:00:00Z"}`, wantStatus: http.StatusBadRequest,
+			wantBody: []string{`"field":"not_after"`}},
+		{name: "patch wrong type", handler: api.UpdateLink, method: http.MethodPatch, code: link.ShortCode,
+			body: `{"max_visits":"many"}`, wantStatus: http.StatusBadRequest, wantBody: []string{"invalid_request"}},
+		{name: "delete", handler: api.DeleteLink, method: http.MethodDelete, code: link.ShortCode, wantStatus: http.StatusNoContent},
+		{name: "get after delete", handler: api.GetLink, method: http.MethodGet, code: link.ShortCode, wantStatus: http.StatusNotFound},
+		{name: "delete again", handler: api.DeleteLink, method: http.MethodDelete, code: link.ShortCode, wantStatus: http.StatusNotFound},
+	}
+
+	// 各步骤依次作用于同一个链接
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := call(tt.handler, tt.method, tt.code, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body = %q", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(rec.Body.String(), want) {
+					t.Errorf("body = %q, want it to contain %q", rec.Body.String(), want)
+				}
+			}
+			if strings.Contains(rec.Body.String(), "$2a$") {
+				t.Errorf("body leaks the password hash: %q", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestLinkAPI_EditDestination(t *testing.T) {
+	api, svc := newTestAPI(t, Options{})
+	link, err := svc.Create(context.Background(), shortener.CreateParams{LongURL: "https://example.com/print"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	call := func(h http.HandlerFunc, method, path, code, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.SetPathValue("code", code)
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec
+	}
+	base := "/api/links/" + link.ShortCode
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		method     string
+		path       string
+		code       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "update destination", handler: api.UpdateLink, method: http.MethodPatch, path: base, code: link.ShortCode,
+			body: `{"long_url":"https://example.com/launch","actor":"marketing"}`, wantStatus: http.StatusOK, wantBody: `"version":2`},
+		{name: "invalid destination", handler: api.UpdateLink, method: http.MethodPatch, path: base, code: link.ShortCode,
+			body: `{"long_url":"ftp://example.com"}`, wantStatus: http.StatusBadRequest, wantBody: "scheme_not_allowed"},
+		{name: "unknown code", handler: api.UpdateLink, method: http.MethodPatch, path: "/api/links/missing", code: "missing",
+			body: `{"long_url":"https://example.com/"}`, wantStatus: http.StatusNotFound},
+		{name: "list versions", handler: api.ListVersions, method: http.MethodGet, path: base + "/versions", code: link.ShortCode,
+			wantStatus: http.StatusOK, wantBody: `"set_by":"marketing"`},
+		{name: "rollback", handler: api.RollbackLink, method: http.MethodPost, path: base + "/rollback", code: link.ShortCode,
+			body: `{"version":1}`, wantStatus: http.StatusOK, wantBody: `"long_url":"https://example.com/print"`},
+		{name: "rollback unknown version", handler: api.RollbackLink, method: http.MethodPost, path: base + "/rollback", code: link.ShortCode,
+			body: `{"version":9}`, wantStatus: http.StatusNotFound},
+	}
+
+	// 各步骤依次作用于同一个链接
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := call(tt.handler, tt.method, tt.path, tt.code, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body = %q", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestLinkAPI_ActivationWindow(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "not yet active", body: `{"long_url":"https://example.com/launch","not_before":"2999-01-01T00:00:00Z"}`, wantStatus: http.StatusNotFound},
+		{name: "expired", body: `{"long_url":"https://example.com/launch","not_after":"2000-01-01T00:00:00Z"}`, wantStatus: http.StatusGone},
+		{name: "teaser", body: `{"long_url":"https://example.com/launch","not_before":"2999-01-01T00:00:00Z","teaser_url":"https://example.com/soon"}`, wantStatus: http.StatusFound},
+		{name: "active", body: `{"long_url":"https://example.com/launch","not_before":"2000-01-01T00:00:00Z","not_after":"2999-01-01T00:00:00Z"}`, wantStatus: http.StatusFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, _ := newTestAPI(t, Options{})
+			rec := httptest.NewRecorder()
+			api.CreateLink(rec, httptest.NewRequest(http.MethodPost, "/api/links", strings.NewReader(tt.body)))
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("CreateLink() status = %d, body = %q", rec.Code, rec.Body.String())
+			}
+			var created CreateShortLinkResponse
+			if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+
+			rec = httptest.NewRecorder()
+			api.RedirectLink(rec, httptest.NewRequest(http.MethodGet, "/"+created.ShortCode, nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("RedirectLink() status = %d, want %d, body = %q", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestLinkAPI_RedirectTypes_Interstitial 开启失效提示页时永久跳转降级为临时跳转，浏览器不缓存，之后失效的链接仍能展示提示页
+func TestLinkAPI_RedirectTypes_Interstitial(t *testing.T) {
+	api, svc := newTestAPI(t, Options{BrokenLinkInterstitial: true})
+	tests := []struct {
+		redirectType int
+		wantStatus   int
+	}{
+		{redirectType: http.StatusMovedPermanently, wantStatus: http.StatusFound},
+		{redirectType: http.StatusPermanentRedirect, wantStatus: http.StatusTemporaryRedirect},
+		{redirectType: http.StatusFound, wantStatus: http.StatusFound},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.redirectType), func(t *testing.T) {
+			link, err := svc.Create(context.Background(), shortener.CreateParams{LongURL: "https://example.com/", RedirectType: tt.redirectType})
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			rec := httptest.NewRecorder()
+			api.RedirectLink(rec, httptest.NewRequest(http.MethodGet, "/"+link.ShortCode, nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != "private, no-store, max-age=0" {
+				t.Errorf("Cache-Control = %q, want no-store", got)
+			}
+		})
+	}
+}
+
+func TestLinkAPI_RedirectTypes(t *testing.T) {
+	api, svc := newTestAPI(t, Options{CountryResolver: targeting.HeaderCountryResolver{Header: "CF-IPCountry"}})
+	tests := []struct {
+		name             string
+		params           shortener.CreateParams
+		method           string
+		wantStatus       int
+		wantCacheControl string
+		wantVary         string
+	}{
+		{name: "default temporary", params: shortener.CreateParams{LongURL: "https://example.com/"},
+			wantStatus: http.StatusFound, wantCacheControl: "private, no-store, max-age=0"},
+		{name: "permanent", params: shortener.CreateParams{LongURL: "https://example.com/", RedirectType: http.StatusMovedPermanently},
+			wantStatus: http.StatusMovedPermanently, wantCacheControl: "public, max-age=86400"},
+		{name: "permanent targeted", params: shortener.CreateParams{LongURL: "https://example.com/", RedirectType: http.StatusPermanentRedirect,
+			Rules: []storage.RedirectRule{{Condition: `platform == "ios"`, LongURL: "https://example.com/ios"}}},
+			wantStatus: http.StatusTemporaryRedirect, wantCacheControl: "private, no-store, max-age=0", wantVary: "User-Agent, Accept-Language, Referer, CF-IPCountry"},
+		{name: "permanent with variants", params: shortener.CreateParams{LongURL: "https://example.com/", RedirectType: http.StatusMovedPermanently,
+			Variants: []storage.Variant{{Name: "a", LongURL: "https://example.com/a", Weight: 1}}},
+			wantStatus: http.StatusFound, wantCacheControl: "private, no-store, max-age=0", wantVary: "Cookie"},
+		{name: "method preserving", params: shortener.CreateParams{LongURL: "https://example.com/hook", RedirectType: http.StatusTemporaryRedirect},
+			wantStatus: http.StatusTemporaryRedirect, wantCacheControl: "private, no-store, max-age=0"},
+		{name: "POST forwarded", params: shortener.CreateParams{LongURL: "https://example.com/hook", RedirectType: http.StatusTemporaryRedirect},
+			method: http.MethodPost, wantStatus: http.StatusTemporaryRedirect, wantCacheControl: "private, no-store, max-age=0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link, err := svc.Create(context.Background(), tt.params)
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			rec := httptest.NewRecorder()
+			if tt.method == http.MethodPost {
+				api.UnlockLink(rec, httptest.NewRequest(http.MethodPost, "/"+link.ShortCode, strings.NewReader(`{"event":"push"}`)))
+			} else {
+				api.RedirectLink(rec, httptest.NewRequest(http.MethodGet, "/"+link.ShortCode, nil))
+			}
+			if rec.Code != tt.wantStatus || rec.Header().Get("Location") == "" {
+				t.Fatalf("status = %d, Location = %q, want %d", rec.Code, rec.Header().Get("Location"), tt.wantStatus)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != tt.wantCacheControl {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.wantCacheControl)
+			}
+			if got := rec.Header().Get("Vary"); got != tt.wantVary {
+				t.Errorf("Vary = %q, want %q", got, tt.wantVary)
+			}
+			expires, err := http.ParseTime(rec.Header().Get("Expires"))
+			if err != nil {
+				t.Fatalf("Expires = %q: %v", rec.Header().Get("Expires"), err)
+			}
+			if cacheable := strings.Contains(tt.wantCacheControl, "max-age=86400"); cacheable != expires.After(time.Now()) {
+				t.Errorf("Expires = %v, want it in the future only for cacheable redirects", expires)
+			}
+		})
+	}
+
+	// 临时跳转的链接收到 POST 时仍然是密码表单的提交
+	link, err := svc.Create(context.Background(), shortener.CreateParams{LongURL: "https://example.com/"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	rec := httptest.NewRecorder()
+	api.UnlockLink(rec, httptest.NewRequest(http.MethodPost, "/"+link.ShortCode, strings.NewReader("password=x")))
+	if rec.Code != http.StatusSeeOther {
+		t.Errorf("POST to a 302 link = %d, want %d", rec.Code, http.StatusSeeOther)
+	}
+}
+
+func TestLinkAPI_TargetedRedirect(t *testing.T) {
+	api, _ := newTestAPI(t, Options{})
+	rec := httptest.NewRecorder()
+	api.CreateLink(rec, httptest.NewRequest(http.MethodPost, "/api/links", strings.NewReader(`{
+		"long_url": "https://example.com/",
+		"rules": [
+			{"condition": "platform == \"ios\"", "long_url": "https://apps.apple.com/app/id1"},
+			{"condition": "lang == \"de\"", "long_url": "https://example.com/de"}
+		]
+	}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("CreateLink() status = %d, body = %q", rec.Code, rec.Body.String())
+	}
+	var created CreateShortLinkResponse
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	code := created.ShortCode
+
+	tests := []struct {
+		name           string
+		userAgent      string
+		acceptLanguage string
+		wantLocation   string
+	}{
+		{name: "iPhone", userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)", wantLocation: "https://apps.apple.com/app/id1"},
+		{name: "German browser", userAgent: "Mozilla/5.0 (Windows NT 10.0)", acceptLanguage: "de-DE,de;q=0.9", wantLocation: "https://example.com/de"},
+		{name: "default", userAgent: "curl/8.5.0", wantLocation: "https://example.com/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/"+code, nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			req.Header.Set("Accept-Language", tt.acceptLanguage)
+			rec := httptest.NewRecorder()
+			api.RedirectLink(rec, req)
+			if got := rec.Header().Get("Location"); rec.Code != http.StatusFound || got != tt.wantLocation {
+				t.Errorf("RedirectLink() = %d %q, want 302 %q", rec.Code, got, tt.wantLocation)
+			}
+
+			body := `{"user_agent":` + strconv.Quote(tt.userAgent) + `,"accept_language":` + strconv.Quote(tt.acceptLanguage) + `}`
+			req = httptest.NewRequest(http.MethodPost, "/api/links/"+code+"/rules/test", strings.NewReader(body))
+			req.SetPathValue("code", code)
+			rec = httptest.NewRecorder()
+			api.TestRules(rec, req)
+			if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"long_url":"`+tt.wantLocation+`"`) {
+				t.Errorf("TestRules() = %d %q, want long_url %q", rec.Code, rec.Body.String(), tt.wantLocation)
+			}
+		})
+	}
+
+	rec = httptest.NewRecorder()
+	api.CreateLink(rec, httptest.NewRequest(http.MethodPost, "/api/links", strings.NewReader(
+		`{"long_url":"https://example.com/","rules":[{"condition":"platform = \"ios\"","long_url":"https://example.com/ios"}]}`)))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"code":"invalid_rule"`) || !strings.Contains(rec.Body.String(), "rule 0") {
+		t.Errorf("CreateLink() with invalid rule = %d %q, want 400 invalid_rule for rule 0", rec.Code, rec.Body.String())
+	}
+}
+
+func TestLinkAPI_Variants(t *testing.T) {
+	api, _ := newTestAPI(t, Options{})
+	rec := httptest.NewRecorder()
+	api.CreateLink(rec, httptest.NewRequest(http.MethodPost, "/api/links", strings.NewReader(`{
+		"long_url": "https://example.com/",
+		"variants": [
+			{"name": "a", "long_url": "https://example.com/a", "weight": 1},
+			{"name": "b", "long_url": "https://example.com/b", "weight": 1}
+		]
+	}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("CreateLink() status = %d, body = %q", rec.Code, rec.Body.String())
+	}
+	var created CreateShortLinkResponse
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	code := created.ShortCode
+	withCode := func(method, path, body string, cookies ...*http.Cookie) *http.Request {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.SetPathValue("code", code)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		return req
+	}
+
+	// 首次访问分配变体并写入 cookie
+	rec = httptest.NewRecorder()
+	api.RedirectLink(rec, httptest.NewRequest(http.MethodGet, "/"+code, nil))
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusFound || len(cookies) != 1 || cookies[0].Name != "sl_ab_"+code {
+		t.Fatalf("RedirectLink() = %d, cookies %v; want 302 with variant cookie", rec.Code, cookies)
+	}
+	assigned := cookies[0].Value
+	wantLocation := "https://example.com/" + assigned
+
+	// 把另一个变体的权重调高，携带 cookie 的访问者不受影响
+	other := map[string]string{"a": "b", "b": "a"}[assigned]
+	rec = httptest.NewRecorder()
+	api.SetVariantWeights(rec, withCode(http.MethodPatch, "/api/links/"+code+"/variants", `{"weights":{"`+other+`":1000}}`))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("SetVariantWeights() status = %d, body = %q", rec.Code, rec.Body.String())
+	}
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/"+code, nil)
+		req.AddCookie(cookies[0])
+		rec = httptest.NewRecorder()
+		api.RedirectLink(rec, req)
+		if got := rec.Header().Get("Location"); got != wantLocation {
+			t.Fatalf("RedirectLink() with cookie = %q, want %q", got, wantLocation)
+		}
+	}
+
+	// 不带 body 的转化上报使用 cookie 中的变体
+	rec = httptest.NewRecorder()
+	api.RecordConversion(rec, withCode(http.MethodPost, "/api/links/"+code+"/conversions", "", cookies[0]))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("RecordConversion() status = %d, body = %q", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	api.RecordConversion(rec, withCode(http.MethodPost, "/api/links/"+code+"/conversions", `{"variant":"zzz"}`))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("RecordConversion(unknown variant) status = %d, want 404", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	api.ListVariants(rec, withCode(http.MethodGet, "/api/links/"+code+"/variants", ""))
+	var stats ListVariantsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("decode ListVariants response: %v", err)
+	}
+	for _, v := range stats.Variants {
+		wantVisits, wantConversions := int64(0), int64(0)
+		if v.Name == assigned {
+			wantVisits, wantConversions = 4, 1
+		}
+		if v.Visits != wantVisits || v.Conversions != wantConversions {
+			t.Errorf("variant %s: visits = %d, conversions = %d, want %d, %d", v.Name, v.Visits, v.Conversions, wantVisits, wantConversions)
+		}
+	}
+}
+
+func TestLinkAPI_TemplateRedirect(t *testing.T) {
+	api, _ := newTestAPI(t, Options{})
+	rec := httptest.NewRecorder()
+	api.CreateLink(rec, httptest.NewRequest(http.MethodPost, "/api/links", strings.NewReader(
+		`{"long_url":"https://shop.example.com/p/{sku}?ref={ref}","template":true,"query_mode":"append"}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("CreateLink() status = %d, body = %q", rec.Code, rec.Body.String())
+	}
+	var created CreateShortLinkResponse
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		target       string
+		wantStatus   int
+		wantLocation string
+	}{
+		{name: "segment and query", target: "/" + created.ShortCode + "/A-100?ref=news&utm_source=mail", wantStatus: http.StatusFound, wantLocation: "https://shop.example.com/p/A-100?ref=news&utm_source=mail"},
+		{name: "missing value", target: "/" + created.ShortCode + "/A-100", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			api.RedirectLink(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.wantStatus || rec.Header().Get("Location") != tt.wantLocation {
+				t.Errorf("RedirectLink(%s) = %d %q, want %d %q", tt.target, rec.Code, rec.Header().Get("Location"), tt.wantStatus, tt.wantLocation)
+			}
+		})
+	}
+}
+
+// TestLinkAPI_PasswordProtectedPrefixLink 多段短码的前缀链接：表单提交到完整路径，解锁后跳转到带后续路径段与查询参数的目的地址
+func TestLinkAPI_PasswordProtectedPrefixLink(t *testing.T) {
+	api, svc := newTestAPI(t, Options{})
+	if _, err := svc.Create(context.Background(), shortener.CreateParams{
+		LongURL:   "https://api.example.com/ref",
+		Alias:     "docs/api",
+		Prefix:    true,
+		QueryMode: shortener.QueryAppend,
+		Password:  "open sesame",
+	}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	const target = "/docs/api/x/y?lang=en"
+
+	rec := httptest.NewRecorder()
+	api.RedirectLink(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `action="/docs/api/x/y?lang=en"`) {
+		t.Fatalf("GET %s: status = %d, want form posting to the full path; body = %q", target, rec.Code, rec.Body.String())
+	}
+
+	form := url.Values{"password": {"open sesame"}}
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	api.UnlockLink(rec, req)
+	if want := "https://api.example.com/ref/x/y?lang=en"; rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != want {
+		t.Fatalf("POST %s: status = %d, location = %q, want 303 %q", target, rec.Code, rec.Header().Get("Location"), want)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("POST %s: cookies = %v, want the unlock cookie", target, cookies)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/docs/api/other", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	api.RedirectLink(rec, req)
+	if want := "https://api.example.com/ref/other"; rec.Code != http.StatusFound || rec.Header().Get("Location") != want {
+		t.Errorf("GET with unlock cookie: status = %d, location = %q, want 302 %q", rec.Code, rec.Header().Get("Location"), want)
+	}
+}
+
+func TestLinkAPI_PrefixRedirect(t *testing.T) {
+	api, _ := newTestAPI(t, Options{})
+	for _, body := range []string{
+		`{"long_url":"https://docs.example.com/","alias":"docs","prefix":true}`,
+		`{"long_url":"https://api.example.com/reference","alias":"docs/api"}`,
+	} {
+		rec := httptest.NewRecorder()
+		api.CreateLink(rec, httptest.NewRequest(http.MethodPost, "/api/links", strings.NewReader(body)))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("CreateLink(%s) status = %d, body = %q", body, rec.Code, rec.Body.String())
+		}
+	}
+	rec := httptest.NewRecorder()
+	api.CreateLink(rec, httptest.NewRequest(http.MethodPost, "/api/links", strings.NewReader(`{"long_url":"https://example.com/","alias":"docs"}`)))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("CreateLink(duplicate alias) status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	tests := []struct {
+		name         string
+		target       string
+		wantStatus   int
+		wantLocation string
+	}{
+		{name: "prefix root", target: "/docs", wantStatus: http.StatusFound, wantLocation: "https://docs.example.com/"},
+		{name: "trailing segments", target: "/docs/guide/intro", wantStatus: http.StatusFound, wantLocation: "https://docs.example.com/guide/intro"},
+		{name: "longer code wins", target: "/docs/api", wantStatus: http.StatusFound, wantLocation: "https://api.example.com/reference"},
+		{name: "plain link falls back to prefix", target: "/docs/api/v2", wantStatus: http.StatusFound, wantLocation: "https://docs.example.com/api/v2"},
+		{name: "unknown code", target: "/nothing/here", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			api.RedirectLink(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.wantStatus || rec.Header().Get("Location") != tt.wantLocation {
+				t.Errorf("RedirectLink(%s) = %d %q, want %d %q", tt.target, rec.Code, rec.Header().Get("Location"), tt.wantStatus, tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestLinkAPI_PreviewTagging(t *testing.T) {
+	policies, err := tagging.Parse(strings.NewReader("policy spring utm_source=newsletter utm_campaign=spring\ngroup growth spring\n"))
+	if err != nil {
+		t.Fatalf("tagging.Parse() error = %v", err)
+	}
+	logger := log.New(io.Discard, "", 0)
+	api := NewLinkAPI(shortener.NewService(shortener.Config{
+		Store:     storage.NewMemoryStore(),
+		Generator: idgen.NewGenerator(),
+		Logger:    logger,
+		Tagging:   policies,
+	}), logger, Options{})
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantFinal  string
+	}{
+		{name: "group policy", body: `{"long_url":"https://example.com/a?utm_source=blog","group":"growth"}`, wantStatus: http.StatusOK, wantFinal: "https://example.com/a?utm_source=blog&utm_campaign=spring"},
+		{name: "named policy", body: `{"long_url":"https://example.com/a","tagging_policy":"spring"}`, wantStatus: http.StatusOK, wantFinal: "https://example.com/a?utm_source=newsletter&utm_campaign=spring"},
+		{name: "unknown policy", body: `{"long_url":"https://example.com/a","tagging_policy":"autumn"}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid long URL", body: `{"long_url":"ftp://example.com/a","group":"growth"}`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			api.PreviewTagging(rec, httptest.NewRequest(http.MethodPost, "/api/tagging/preview", strings.NewReader(tt.body)))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("PreviewTagging() status = %d, body = %q, want %d", rec.Code, rec.Body.String(), tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var got shortener.TaggingPreview
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if got.FinalURL != tt.wantFinal {
+				t.Errorf("final_url = %q, want %q", got.FinalURL, tt.wantFinal)
+			}
+		})
+	}
+}
+
+func TestLinkAPI_GetLinkMetadata(t *testing.T) {
+	api, svc := newTestAPI(t, Options{})
+	link, err := svc.Create(context.Background(), shortener.CreateParams{LongURL: "https://example.com/"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		code       string
+		wantStatus int
+		wantState  string
+	}{
+		{name: "not fetched yet", code: link.ShortCode, wantStatus: http.StatusOK, wantState: "pending"},
+		{name: "unknown code", code: "nothing", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/links/"+tt.code+"/metadata", nil)
+			req.SetPathValue("code", tt.code)
+			rec := httptest.NewRecorder()
+			api.GetLinkMetadata(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("GetLinkMetadata() status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var got LinkMetadataResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if got.Status != tt.wantState {
+				t.Errorf("status = %q, want %q", got.Status, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestLinkAPI_BrokenLink(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	logger := log.New(io.Discard, "", 0)
+	svc := shortener.NewService(shortener.Config{Store: store, Generator: idgen.NewGenerator(), Logger: logger})
+	link, err := svc.Create(ctx, shortener.CreateParams{LongURL: "https://example.com/old-page"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	checkedAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := store.Update(ctx, link.ShortCode, func(l *storage.Link) error {
+		l.Health = &storage.Health{StatusCode: http.StatusNotFound, CheckedAt: checkedAt, Failures: 3, Broken: true, Error: "status 404"}
+		return nil
+	}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		interstitial bool
+		wantStatus   int
+	}{
+		{name: "blind redirect by default", wantStatus: http.StatusFound},
+		{name: "interstitial", interstitial: true, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := NewLinkAPI(svc, logger, Options{BrokenLinkInterstitial: tt.interstitial})
+			rec := httptest.NewRecorder()
+			api.RedirectLink(rec, httptest.NewRequest(http.MethodGet, "/"+link.ShortCode, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("RedirectLink() status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.interstitial && !strings.Contains(rec.Body.String(), `href="https://example.com/old-page"`) {
+				t.Errorf("interstitial body does not link to the destination: %s", rec.Body.String())
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/links/"+link.ShortCode+"/health", nil)
+	req.SetPathValue("code", link.ShortCode)
+	rec := httptest.NewRecorder()
+	NewLinkAPI(svc, logger, Options{}).GetLinkHealth(rec, req)
+	var got LinkHealthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got.Status != "broken" || got.StatusCode != http.StatusNotFound || got.Failures != 3 || got.LastSuccess != nil {
+		t.Errorf("GetLinkHealth() = %+v", got)
+	}
+}
+
+func TestLinkAPI_Idempotency(t *testing.T) {
+	api, _ := newTestAPI(t, Options{})
+	create := api.Idempotent(api.CreateLink)
+	post := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/links", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		create(rec, req)
+		return rec
+	}
+	body := `{"long_url":"https://example.com/a","alias":"retry-me"}`
+
+	first := post("key-1", body)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first request status = %d, body = %s", first.Code, first.Body.String())
+	}
+	// 不带 key 的重试会因为别名已存在而失败，带 key 的重试得到第一次的响应
+	if rec := post("", body); rec.Code != http.StatusConflict {
+		t.Errorf("retry without key status = %d, want 409", rec.Code)
+	}
+	replay := post("key-1", body)
+	if replay.Code != http.StatusCreated || replay.Body.String() != first.Body.String() || replay.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("replay = %d %q %v, want the first response", replay.Code, replay.Body.String(), replay.Header())
+	}
+	if rec := post("key-1", `{"long_url":"https://example.com/b"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("same key with different body status = %d, want 422", rec.Code)
+	}
+	// 错误响应同样被保存
+	if rec := post("key-2", `{"long_url":"javascript:alert(1)"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid request status = %d", rec.Code)
+	}
+	if rec := post("key-2", `{"long_url":"javascript:alert(1)"}`); rec.Code != http.StatusBadRequest || rec.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("replayed error = %d %v", rec.Code, rec.Header())
+	}
+
+	// 并发的重复请求都得到同一个结果，而不是一个成功、其余 409
+	var wg sync.WaitGroup
+	recs := make([]*httptest.ResponseRecorder, 8)
+	for i := range recs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recs[i] = post("key-3", `{"long_url":"https://example.com/c","alias":"race"}`)
+		}()
+	}
+	wg.Wait()
+	for i, rec := range recs {
+		if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), `"race"`) {
+			t.Errorf("concurrent request %d = %d %s", i, rec.Code, rec.Body.String())
+		}
+	}
+}
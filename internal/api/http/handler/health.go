@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+)
+
+// 目的地址的健康状态
+const (
+	healthUnchecked = "unchecked"
+	healthHealthy   = "healthy"
+	healthFailing   = "failing"
+	healthBroken    = "broken"
+)
+
+type LinkHealthResponse struct {
+	ShortCode string `json:"short_code"`
+	// Status 为 unchecked、healthy、failing(失败但未达到阈值) 或 broken
+	Status      string     `json:"status"`
+	StatusCode  int        `json:"status_code,omitempty"`
+	LatencyMS   int64      `json:"latency_ms,omitempty"`
+	CheckedAt   *time.Time `json:"checked_at,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	Failures    int        `json:"failures,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	NextCheckAt *time.Time `json:"next_check_at,omitempty"`
+}
+
+// GetLinkHealth 返回目的地址最近一次健康检查的结果 GET /api/links/{code}/health
+func (l *LinkAPI) GetLinkHealth(w http.ResponseWriter, r *http.Request) {
+	shortCode := r.PathValue("code")
+	h, err := l.service.Health(r.Context(), shortCode)
+	if err != nil {
+		l.writeAPIError(w, r, err)
+		return
+	}
+	resp := LinkHealthResponse{ShortCode: shortCode, Status: healthUnchecked}
+	if h != nil {
+		switch {
+		case h.Broken:
+			resp.Status = healthBroken
+		case h.Failures > 0:
+			resp.Status = healthFailing
+		default:
+			resp.Status = healthHealthy
+		}
+		resp.StatusCode, resp.LatencyMS, resp.Failures, resp.Error = h.StatusCode, h.Latency.Milliseconds(), h.Failures, h.Error
+		resp.CheckedAt, resp.NextCheckAt = &h.CheckedAt, &h.NextCheckAt
+		if !h.LastSuccess.IsZero() {
+			resp.LastSuccess = &h.LastSuccess
+		}
+	}
+	l.writeJSON(w, http.StatusOK, resp)
+}
+
+// renderInterstitial 展示失效链接的提示页，由访问者决定是否继续前往目的地址
+func (l *LinkAPI) renderInterstitial(w http.ResponseWriter, destination string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if err := interstitialTemplate.Execute(w, destination); err != nil {
+		l.logger.Printf("ERROR: Failed to render interstitial: %v\n", err)
+	}
+}
+
+var interstitialTemplate = template.Must(template.New("interstitial").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>This link may be broken</title>
+<style>
+body{font-family:system-ui,sans-serif;display:flex;justify-content:center;margin-top:15vh;color:#222}
+main{width:24rem}code{word-break:break-all}
+</style>
+</head>
+<body>
+<main>
+<h1>This link may be broken</h1>
+<p>The destination has not been reachable recently:</p>
+<p><code>{{.}}</code></p>
+<p><a href="{{.}}" rel="noreferrer">Continue anyway</a></p>
+</main>
+</body>
+</html>
+`))
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"shortlink/internal/shortener"
+	"shortlink/internal/storage"
+	"time"
+)
+
+type RollbackRequest struct {
+	Version int    `json:"version"`
+	Actor   string `json:"actor,omitempty"`
+}
+
+// LinkDestinationResponse 是修改目的地后的链接状态
+type LinkDestinationResponse struct {
+	ShortCode string    `json:"short_code"`
+	LongURL   string    `json:"long_url"`
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UpdatedBy string    `json:"updated_by,omitempty"`
+}
+
+type ListVersionsResponse struct {
+	ShortCode string              `json:"short_code"`
+	Versions  []shortener.Version `json:"versions"`
+}
+
+// ListVersions 列出短链接目的地的全部历史版本 GET /api/links/{code}/versions
+func (l *LinkAPI) ListVersions(w http.ResponseWriter, r *http.Request) {
+	shortCode := r.PathValue("code")
+	versions, err := l.service.Versions(r.Context(), shortCode)
+	if err != nil {
+		l.writeAPIError(w, r, err)
+		return
+	}
+	l.writeJSON(w, http.StatusOK, ListVersionsResponse{ShortCode: shortCode, Versions: versions})
+}
+
+// RollbackLink 把目的地恢复为某个历史版本 POST /api/links/{code}/rollback
+func (l *LinkAPI) RollbackLink(w http.ResponseWriter, r *http.Request) {
+	shortCode := r.PathValue("code")
+	var req RollbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		l.logger.Printf("ERROR: Failed to decode request body: %v\n", err)
+		l.writeProblem(w, r, decodeProblem(err))
+		return
+	}
+	defer r.Body.Close()
+	l.logger.Printf("INFO: Received request to roll back from %s. ShortCode: %s, Version: %d\n", r.RemoteAddr, shortCode, req.Version)
+
+	link, err := l.service.Rollback(r.Context(), shortCode, req.Version, req.Actor)
+	if err != nil {
+		l.writeAPIError(w, r, err)
+		return
+	}
+	l.writeJSON(w, http.StatusOK, destinationResponse(link))
+}
+
+func destinationResponse(link *storage.Link) LinkDestinationResponse {
+	updatedAt := link.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = link.CreatedAt
+	}
+	return LinkDestinationResponse{
+		ShortCode: link.ShortCode,
+		LongURL:   link.LongURL,
+		Version:   len(link.History) + 1,
+		UpdatedAt: updatedAt,
+		UpdatedBy: link.UpdatedBy,
+	}
+}
+
+func (l *LinkAPI) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		l.logger.Printf("ERROR: Failed to encode response: %v\n", err)
+	}
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+
+	"shortlink/internal/idempotency"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayHeader 标记重放的响应
+	idempotentReplayHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLen   = 255
+	// maxIdempotentBody 带 Idempotency-Key 的请求需要先读完请求体计算指纹，限制其大小
+	maxIdempotentBody = 1 << 20
+)
+
+// Idempotent 为创建接口提供 Idempotency-Key 支持：第一次请求的响应(状态码与响应体)按 key 保存，
+// 重试时直接重放；同一个 key 用于内容不同的请求返回 422；并发的重复请求等待第一个完成。
+// 5xx 响应不保存，客户端可以用同一个 key 重试。没有 Idempotency-Key 的请求不受影响
+func (l *LinkAPI) Idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			l.writeProblem(w, r, invalidRequest("Idempotency-Key is too long"))
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBody))
+		r.Body.Close()
+		if err != nil {
+			l.logger.Printf("ERROR: Failed to read request body: %v\n", err)
+			l.writeProblem(w, r, invalidRequest("Failed to read request body"))
+			return
+		}
+
+		stored, finish, err := l.idempotency.Begin(r.Context(), key, fingerprint(r, body))
+		switch {
+		case errors.Is(err, idempotency.ErrKeyReused):
+			l.logger.Printf("WARN: Idempotency-Key reused with a different request from %s. Key: %s\n", r.RemoteAddr, key)
+			l.writeProblem(w, r, newProblem(http.StatusUnprocessableEntity, "idempotency_key_reused", "Idempotency-Key reused", "Idempotency-Key was already used for a different request"))
+			return
+		case err != nil:
+			// 等待第一个请求时客户端断开
+			l.logger.Printf("WARN: Gave up waiting for request with the same Idempotency-Key from %s. Key: %s, Error: %v\n", r.RemoteAddr, key, err)
+			return
+		case stored != nil:
+			l.logger.Printf("INFO: Replaying response for Idempotency-Key from %s. Key: %s, Status: %d\n", r.RemoteAddr, key, stored.Status)
+			w.Header().Set("Content-Type", stored.ContentType)
+			w.Header().Set(idempotentReplayHeader, "true")
+			w.WriteHeader(stored.Status)
+			w.Write(stored.Body)
+			return
+		}
+
+		// next panic 时也要释放 key，否则等待中的请求会一直阻塞
+		var resp *idempotency.Response
+		defer func() { finish(resp) }()
+		capture := &capturingWriter{ResponseWriter: w}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next(capture, r)
+		if capture.status == 0 {
+			capture.status = http.StatusOK
+		}
+		if capture.status < http.StatusInternalServerError {
+			resp = &idempotency.Response{Status: capture.status, ContentType: capture.contentType, Body: capture.body.Bytes()}
+		}
+	}
+}
+
+// fingerprint 标识请求内容：方法、路径与请求体
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// capturingWriter 把响应写给客户端的同时记录下来
+type capturingWriter struct {
+	http.ResponseWriter
+	status      int
+	contentType string
+	body        bytes.Buffer
+}
+
+func (c *capturingWriter) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+		c.contentType = c.Header().Get("Content-Type")
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *capturingWriter) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.WriteHeader(http.StatusOK)
+	}
+	c.body.Write(b)
+	return c.ResponseWriter.Write(b)
+}
+
+// Unwrap 让 http.ResponseController 可以访问底层的 ResponseWriter
+func (c *capturingWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"shortlink/internal/shortener"
+	"shortlink/internal/storage"
+	"strings"
+	"time"
+)
+
+// LinkResponse 是链接的完整记录，不包含密码哈希
+type LinkResponse struct {
+	ShortCode string `json:"short_code"`
+	// ShortURL 可以直接分享的完整短链接，未配置 BaseURL 时省略
+	ShortURL  string    `json:"short_url,omitempty"`
+	LongURL   string    `json:"long_url"`
+	CreatedAt time.Time `json:"created_at"`
+	// UpdatedAt/UpdatedBy 当前目的地的设置时间与操作人，从未修改过时 UpdatedAt 等于 CreatedAt
+	UpdatedAt time.Time `json:"updated_at"`
+	UpdatedBy string    `json:"updated_by,omitempty"`
+	// Version 当前目的地的版本号，历史见 GET /api/links/{code}/versions
+	Version    int         `json:"version"`
+	VisitCount int64       `json:"visit_count"`
+	Options    LinkOptions `json:"options"`
+}
+
+// LinkOptions 是创建时可以设置的链接选项，未设置的选项省略
+type LinkOptions struct {
+	PasswordProtected bool             `json:"password_protected"`
+	MaxVisits         int64            `json:"max_visits,omitempty"`
+	NotBefore         *time.Time       `json:"not_before,omitempty"`
+	NotAfter          *time.Time       `json:"not_after,omitempty"`
+	TeaserURL         string           `json:"teaser_url,omitempty"`
+	Rules             []RedirectRule   `json:"rules,omitempty"`
+	Variants          []VariantRequest `json:"variants,omitempty"`
+	Template          bool             `json:"template,omitempty"`
+	QueryMode         string           `json:"query_mode,omitempty"`
+	Prefix            bool             `json:"prefix,omitempty"`
+	Group             string           `json:"group,omitempty"`
+	TaggingPolicy     string           `json:"tagging_policy,omitempty"`
+	// RedirectType 单独设置的跳转状态码，省略时使用服务的默认类型
+	RedirectType int `json:"redirect_type,omitempty"`
+}
+
+// Optional 区分 PATCH 请求中缺省的字段(保持不变)与显式的 null(取消该选项)
+type Optional[T any] struct {
+	Set   bool
+	Value *T
+}
+
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if bytes.Equal(data, []byte("null")) {
+		o.Value = nil
+		return nil
+	}
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	o.Value = &v
+	return nil
+}
+
+// patch 转换为 LinkPatch 的字段：缺省为 nil，null 为零值
+func (o Optional[T]) patch() *T {
+	if !o.Set {
+		return nil
+	}
+	if o.Value == nil {
+		var zero T
+		return &zero
+	}
+	return o.Value
+}
+
+// UpdateLinkRequest 是 PATCH /api/links/{code} 的请求体，只修改出现的字段，null 取消该选项
+type UpdateLinkRequest struct {
+	// LongURL 新的目的地，旧目的地保留在历史版本中，不能为 null
+	LongURL   Optional[string]    `json:"long_url"`
+	Password  Optional[string]    `json:"password"`
+	MaxVisits Optional[int64]     `json:"max_visits"`
+	NotBefore Optional[time.Time] `json:"not_before"`
+	NotAfter  Optional[time.Time] `json:"not_after"`
+	TeaserURL Optional[string]    `json:"teaser_url"`
+	QueryMode Optional[string]    `json:"query_mode"`
+	// RedirectType null 恢复为服务的默认跳转类型
+	RedirectType Optional[int] `json:"redirect_type"`
+	// Actor 可选，记录到历史版本中的操作人
+	Actor string `json:"actor,omitempty"`
+}
+
+// GetLink 返回链接的完整记录，不计入访问次数 GET /api/links/{code}
+func (l *LinkAPI) GetLink(w http.ResponseWriter, r *http.Request) {
+	link, err := l.service.Get(r.Context(), r.PathValue("code"))
+	if err != nil {
+		l.writeAPIError(w, r, err)
+		return
+	}
+	l.writeJSON(w, http.StatusOK, l.linkResponse(r, link))
+}
+
+// UpdateLink 部分修改短链接 PATCH /api/links/{code}
+func (l *LinkAPI) UpdateLink(w http.ResponseWriter, r *http.Request) {
+	shortCode := r.PathValue("code")
+	var req UpdateLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		l.logger.Printf("ERROR: Failed to decode request body: %v\n", err)
+		l.writeProblem(w, r, decodeProblem(err))
+		return
+	}
+	defer r.Body.Close()
+	if req.LongURL.Set && (req.LongURL.Value == nil || strings.TrimSpace(*req.LongURL.Value) == "") {
+		l.writeAPIError(w, r, errEmptyLongURL)
+		return
+	}
+	l.logger.Printf("INFO: Received request to update link from %s. ShortCode: %s\n", r.RemoteAddr, shortCode)
+
+	link, err := l.service.UpdateLink(r.Context(), shortCode, shortener.LinkPatch{
+		LongURL:      req.LongURL.patch(),
+		Password:     req.Password.patch(),
+		MaxVisits:    req.MaxVisits.patch(),
+		NotBefore:    req.NotBefore.patch(),
+		NotAfter:     req.NotAfter.patch(),
+		TeaserURL:    req.TeaserURL.patch(),
+		QueryMode:    req.QueryMode.patch(),
+		RedirectType: req.RedirectType.patch(),
+		Actor:        req.Actor,
+	})
+	if err != nil {
+		l.writeAPIError(w, r, err)
+		return
+	}
+	l.writeJSON(w, http.StatusOK, l.linkResponse(r, link))
+}
+
+// DeleteLink 删除短链接 DELETE /api/links/{code}
+func (l *LinkAPI) DeleteLink(w http.ResponseWriter, r *http.Request) {
+	shortCode := r.PathValue("code")
+	if err := l.service.Delete(r.Context(), shortCode); err != nil {
+		l.writeAPIError(w, r, err)
+		return
+	}
+	l.logger.Printf("INFO: Deleted short link %s from %s\n", shortCode, r.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (l *LinkAPI) linkResponse(r *http.Request, link *storage.Link) LinkResponse {
+	resp := LinkResponse{
+		ShortCode:  link.ShortCode,
+		ShortURL:   l.shortURL(link.ShortCode),
+		LongURL:    link.LongURL,
+		CreatedAt:  link.CreatedAt,
+		UpdatedAt:  link.UpdatedAt,
+		UpdatedBy:  link.UpdatedBy,
+		Version:    len(link.History) + 1,
+		VisitCount: link.VisitCount,
+		Options: LinkOptions{
+			PasswordProtected: link.PasswordHash != "",
+			MaxVisits:         link.MaxVisits,
+			TeaserURL:         link.TeaserURL,
+			Template:          link.Template,
+			QueryMode:         link.QueryMode,
+			Prefix:            link.Prefix,
+			Group:             link.Group,
+			TaggingPolicy:     link.TaggingPolicy,
+			RedirectType:      link.RedirectType,
+		},
+	}
+	if resp.UpdatedAt.IsZero() {
+		resp.UpdatedAt = link.CreatedAt
+	}
+	if !link.NotBefore.IsZero() {
+		resp.Options.NotBefore = &link.NotBefore
+	}
+	if !link.NotAfter.IsZero() {
+		resp.Options.NotAfter = &link.NotAfter
+	}
+	for _, rule := range link.Rules {
+		resp.Options.Rules = append(resp.Options.Rules, RedirectRule{Condition: rule.Condition, LongURL: rule.LongURL})
+	}
+	for _, v := range link.Variants {
+		resp.Options.Variants = append(resp.Options.Variants, VariantRequest{Name: v.Name, LongURL: v.LongURL, Weight: v.Weight})
+	}
+	return resp
+}
+
+// shortURL 以 BaseURL 为前缀拼接完整的短链接，短码的每一段分别做路径转义
+// 没有配置 BaseURL 时返回空字符串：请求的 Host 头由客户端控制，不能拿来拼接对外分享的链接
+func (l *LinkAPI) shortURL(shortCode string) string {
+	if l.baseURL == "" {
+		return ""
+	}
+	segments := strings.Split(shortCode, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return l.baseURL + "/" + strings.Join(segments, "/")
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+)
+
+// 预览信息的抓取状态
+const (
+	metadataPending = "pending"
+	metadataReady   = "ready"
+	metadataFailed  = "failed"
+)
+
+type LinkMetadataResponse struct {
+	ShortCode string `json:"short_code"`
+	// Status 为 pending(尚未抓取)、ready 或 failed
+	Status      string     `json:"status"`
+	Title       string     `json:"title,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Favicon     string     `json:"favicon,omitempty"`
+	Image       string     `json:"image,omitempty"`
+	FetchedAt   *time.Time `json:"fetched_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// GetLinkMetadata 返回目的页面的预览信息 GET /api/links/{code}/metadata
+func (l *LinkAPI) GetLinkMetadata(w http.ResponseWriter, r *http.Request) {
+	shortCode := r.PathValue("code")
+	md, err := l.service.Metadata(r.Context(), shortCode)
+	if err != nil {
+		l.writeAPIError(w, r, err)
+		return
+	}
+	resp := LinkMetadataResponse{ShortCode: shortCode, Status: metadataPending}
+	if md != nil {
+		resp.Status = metadataReady
+		if md.Error != "" {
+			resp.Status = metadataFailed
+		}
+		resp.Title, resp.Description, resp.Favicon, resp.Image, resp.Error = md.Title, md.Description, md.Favicon, md.Image, md.Error
+		resp.FetchedAt = &md.FetchedAt
+	}
+	l.writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"shortlink/internal/shortener"
+	"shortlink/internal/targeting"
+	"strings"
+	"time"
+)
+
+// expiredDate 是过去的时间，让只认 Expires 的 HTTP/1.0 缓存也不保存响应
+const expiredDate = "Thu, 01 Jan 1970 00:00:00 GMT"
+
+// targetingHeaders 是定向规则读取的请求头
+var targetingHeaders = []string{"User-Agent", "Accept-Language", "Referer"}
+
+// writeRedirect 按跳转类型写出跳转及缓存头
+// 可以缓存的永久跳转带 max-age 与对应的 Expires；其他跳转一律 no-store，修改目的地后立即生效。
+// Vary 列出决定目的地的请求头：定向规则读取的请求头，以及密码解锁、A/B 变体所用的 Cookie
+func (l *LinkAPI) writeRedirect(w http.ResponseWriter, r *http.Request, redirect *shortener.Redirect) {
+	// 开启失效提示页时，任何链接都可能在之后被健康检查标记为失效，被缓存的永久跳转会绕过提示页
+	if l.interstitial && shortener.PermanentRedirect(redirect.Status) {
+		redirect.Status = shortener.TemporaryRedirectType(redirect.Status)
+		redirect.MaxAge = 0
+	}
+	h := w.Header()
+	var vary []string
+	if redirect.Targeted {
+		vary = append(vary, targetingHeaders...)
+		if hr, ok := l.countries.(targeting.HeaderCountryResolver); ok {
+			vary = append(vary, hr.Header)
+		}
+	}
+	if redirect.Personal {
+		vary = append(vary, "Cookie")
+	}
+	if len(vary) > 0 {
+		h.Set("Vary", strings.Join(vary, ", "))
+	}
+	if redirect.MaxAge > 0 {
+		// 带定向规则的链接不会被缓存，可以缓存的跳转对所有访问者相同
+		h.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(redirect.MaxAge.Seconds())))
+		h.Set("Expires", time.Now().Add(redirect.MaxAge).UTC().Format(http.TimeFormat))
+	} else {
+		h.Set("Cache-Control", "private, no-store, max-age=0")
+		h.Set("Expires", expiredDate)
+	}
+	http.Redirect(w, r, redirect.URL, redirect.Status)
+}
+
+// forwardsPost 判断对 shortCode 的 POST 是否应当转发到目的地址：链接不受密码保护且跳转类型保留请求方法(307/308)
+// 其他链接的 POST 是密码表单提交
+func (l *LinkAPI) forwardsPost(ctx context.Context, shortCode string) bool {
+	link, err := l.service.Get(ctx, shortCode)
+	if err != nil || link.PasswordHash != "" {
+		return false
+	}
+	return shortener.PreservesMethod(l.service.RedirectType(link))
+}
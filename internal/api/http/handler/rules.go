@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"shortlink/internal/targeting"
+	"strings"
+	"time"
+)
+
+// TestRulesRequest 描述一个模拟请求，字段均可选
+type TestRulesRequest struct {
+	UserAgent      string `json:"user_agent"`
+	AcceptLanguage string `json:"accept_language"`
+	// Country 直接指定国家代码，不经过 CountryResolver
+	Country  string `json:"country"`
+	Referrer string `json:"referrer"`
+	// Time 模拟的访问时间(RFC 3339)，为空时使用当前时间
+	Time *time.Time `json:"time,omitempty"`
+}
+
+type TestRulesResponse struct {
+	ShortCode string `json:"short_code"`
+	// RuleIndex 命中规则的序号(从 0 开始)，-1 表示使用默认目的地
+	RuleIndex  int                `json:"rule_index"`
+	Condition  string             `json:"condition,omitempty"`
+	LongURL    string             `json:"long_url"`
+	Attributes TestRuleAttributes `json:"attributes"`
+}
+
+// TestRuleAttributes 是从模拟请求中解析出的、规则可见的属性
+type TestRuleAttributes struct {
+	Platform string `json:"platform"`
+	Lang     string `json:"lang"`
+	Country  string `json:"country"`
+	Referrer string `json:"referrer"`
+}
+
+// TestRules 用模拟请求对链接的定向规则求值，不跳转也不计入访问 POST /api/links/{code}/rules/test
+func (l *LinkAPI) TestRules(w http.ResponseWriter, r *http.Request) {
+	shortCode := r.PathValue("code")
+	var req TestRulesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		l.logger.Printf("ERROR: Failed to decode request body: %v\n", err)
+		l.writeProblem(w, r, decodeProblem(err))
+		return
+	}
+	defer r.Body.Close()
+
+	// 构造一个等价的 HTTP 请求，保证与真实跳转使用同一套解析逻辑
+	synthetic, err := http.NewRequestWithContext(r.Context(), http.MethodGet, "/"+shortCode, nil)
+	if err != nil {
+		l.writeProblem(w, r, invalidRequest("Invalid short code"))
+		return
+	}
+	synthetic.Header.Set("User-Agent", req.UserAgent)
+	synthetic.Header.Set("Accept-Language", req.AcceptLanguage)
+	synthetic.Header.Set("Referer", req.Referrer)
+	target := targeting.FromHTTP(synthetic, nil)
+	target.Country = strings.ToUpper(strings.TrimSpace(req.Country))
+	if req.Time != nil {
+		target.Time = *req.Time
+	}
+
+	match, err := l.service.TestRules(r.Context(), shortCode, target)
+	if err != nil {
+		l.writeAPIError(w, r, err)
+		return
+	}
+	l.writeJSON(w, http.StatusOK, TestRulesResponse{
+		ShortCode: shortCode,
+		RuleIndex: match.Index,
+		Condition: match.Condition,
+		LongURL:   match.LongURL,
+		Attributes: TestRuleAttributes{
+			Platform: target.Platform,
+			Lang:     target.Language,
+			Country:  target.Country,
+			Referrer: target.Referrer,
+		},
+	})
+}
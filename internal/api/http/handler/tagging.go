@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+type PreviewTaggingRequest struct {
+	LongURL string `json:"long_url"`
+	// Group 使用分组的默认策略，TaggingPolicy 非空时以它为准
+	Group         string `json:"group,omitempty"`
+	TaggingPolicy string `json:"tagging_policy,omitempty"`
+}
+
+// PreviewTagging 预览打标策略作用后的最终地址，不创建链接 POST /api/tagging/preview
+func (l *LinkAPI) PreviewTagging(w http.ResponseWriter, r *http.Request) {
+	var req PreviewTaggingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		l.logger.Printf("ERROR: Failed to decode request body: %v\n", err)
+		l.writeProblem(w, r, decodeProblem(err))
+		return
+	}
+	defer r.Body.Close()
+	if strings.TrimSpace(req.LongURL) == "" {
+		l.writeAPIError(w, r, errEmptyLongURL)
+		return
+	}
+
+	preview, err := l.service.PreviewTagging(req.LongURL, req.Group, req.TaggingPolicy)
+	if err != nil {
+		l.writeAPIError(w, r, err)
+		return
+	}
+	l.writeJSON(w, http.StatusOK, preview)
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"html/template"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"shortlink/internal/api/http/middleware"
+)
+
+const unlockCookiePrefix = "sl_unlock_"
+
+// unlockSigner 签发与校验"已输入过密码"的短期 cookie
+// cookie 值为 "<过期时间戳>.<HMAC-SHA256(code + 密码哈希 + 过期时间戳)>"，不包含任何密码信息
+// MAC 覆盖签发时的密码哈希，修改或取消密码后哈希改变，已签发的 cookie 随即失效
+type unlockSigner struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+func (s unlockSigner) cookie(code, passwordHash string, secure bool) *http.Cookie {
+	expires := s.now().Add(s.ttl)
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return &http.Cookie{
+		Name:     cookieName(unlockCookiePrefix, code),
+		Value:    exp + "." + s.mac(code, passwordHash, exp),
+		Path:     "/",
+		Expires:  expires,
+		MaxAge:   int(s.ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// valid 判断请求是否携带了 code 对应、且按当前密码哈希签发的有效解锁 cookie
+func (s unlockSigner) valid(r *http.Request, code, passwordHash string) bool {
+	c, err := r.Cookie(cookieName(unlockCookiePrefix, code))
+	if err != nil {
+		return false
+	}
+	exp, sig, ok := strings.Cut(c.Value, ".")
+	if !ok {
+		return false
+	}
+	expUnix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || !s.now().Before(time.Unix(expUnix, 0)) {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(s.mac(code, passwordHash, exp)))
+}
+
+func (s unlockSigner) mac(code, passwordHash, exp string) string {
+	m := hmac.New(sha256.New, s.secret)
+	m.Write([]byte(code))
+	m.Write([]byte{0})
+	m.Write([]byte(passwordHash))
+	m.Write([]byte{0})
+	m.Write([]byte(exp))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+// cookieName 返回短码对应的 cookie 名，cookie 名中不能出现 /，自定义短码中的 / 替换为 .
+func cookieName(prefix, code string) string {
+	return prefix + strings.ReplaceAll(code, "/", ".")
+}
+
+// clientIP 返回用于限流的客户端标识，经过 ClientIP 中间件时为按可信代理识别出的真实客户端 IP
+func clientIP(r *http.Request) string {
+	if ip := middleware.ClientIPFrom(r.Context()); ip != "" {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+var passwordFormTemplate = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Password required</title>
+<style>
+body{font-family:system-ui,sans-serif;display:flex;justify-content:center;margin-top:15vh;color:#222}
+form{width:18rem}input{width:100%;box-sizing:border-box;padding:.5rem;margin:.5rem 0}
+.error{color:#b00020}
+</style>
+</head>
+<body>
+<form method="post" action="{{.Action}}">
+<h1>Password required</h1>
+<p>This link is protected. Enter the password to continue.</p>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<input type="password" name="password" autocomplete="current-password" autofocus required>
+<input type="submit" value="Continue">
+</form>
+</body>
+</html>
+`))
+
+type passwordFormData struct {
+	// Action 表单提交的地址，即展示表单的请求路径(含查询参数)
+	Action string
+	Error  string
+}
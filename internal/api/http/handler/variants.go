@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"shortlink/internal/shortener"
+	"time"
+)
+
+const (
+	variantCookiePrefix = "sl_ab_"
+	// variantCookieTTL 访问者在该时长内总是看到同一个变体
+	variantCookieTTL = 30 * 24 * time.Hour
+)
+
+type VariantRequest struct {
+	Name    string `json:"name"`
+	LongURL string `json:"long_url"`
+	// Weight 相对权重，例如 50/50 或 90/10
+	Weight int `json:"weight"`
+}
+
+type SetVariantWeightsRequest struct {
+	// Weights 变体名到新权重的映射，未列出的变体保持原权重
+	Weights map[string]int `json:"weights"`
+}
+
+type RecordConversionRequest struct {
+	// Variant 为空时使用请求携带的变体 cookie
+	Variant string `json:"variant"`
+}
+
+type ListVariantsResponse struct {
+	ShortCode string                   `json:"short_code"`
+	Variants  []shortener.VariantStats `json:"variants"`
+}
+
+// ListVariants 返回各变体的权重与访问、转化统计 GET /api/links/{code}/variants
+func (l *LinkAPI) ListVariants(w http.ResponseWriter, r *http.Request) {
+	shortCode := r.PathValue("code")
+	stats, err := l.service.Variants(r.Context(), shortCode)
+	if err != nil {
+		l.writeAPIError(w, r, err)
+		return
+	}
+	l.writeJSON(w, http.StatusOK, ListVariantsResponse{ShortCode: shortCode, Variants: stats})
+}
+
+// SetVariantWeights 在线调整变体权重 PATCH /api/links/{code}/variants
+func (l *LinkAPI) SetVariantWeights(w http.ResponseWriter, r *http.Request) {
+	shortCode := r.PathValue("code")
+	var req SetVariantWeightsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		l.logger.Printf("ERROR: Failed to decode request body: %v\n", err)
+		l.writeProblem(w, r, decodeProblem(err))
+		return
+	}
+	defer r.Body.Close()
+
+	stats, err := l.service.SetVariantWeights(r.Context(), shortCode, req.Weights)
+	if err != nil {
+		l.writeAPIError(w, r, err)
+		return
+	}
+	l.writeJSON(w, http.StatusOK, ListVariantsResponse{ShortCode: shortCode, Variants: stats})
+}
+
+// RecordConversion 为变体记录一次转化 POST /api/links/{code}/conversions
+func (l *LinkAPI) RecordConversion(w http.ResponseWriter, r *http.Request) {
+	shortCode := r.PathValue("code")
+	var req RecordConversionRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			l.logger.Printf("ERROR: Failed to decode request body: %v\n", err)
+			l.writeProblem(w, r, decodeProblem(err))
+			return
+		}
+		defer r.Body.Close()
+	}
+	if req.Variant == "" {
+		req.Variant = assignedVariant(r, shortCode)
+	}
+	if req.Variant == "" {
+		l.writeProblem(w, r, invalidRequest("Variant is required"))
+		return
+	}
+
+	if err := l.service.RecordConversion(r.Context(), shortCode, req.Variant); err != nil {
+		l.writeAPIError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// assignedVariant 返回请求 cookie 中记录的变体
+func assignedVariant(r *http.Request, shortCode string) string {
+	c, err := r.Cookie(cookieName(variantCookiePrefix, shortCode))
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+func variantCookie(shortCode, variant string, secure bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     cookieName(variantCookiePrefix, shortCode),
+		Value:    variant,
+		Path:     "/",
+		MaxAge:   int(variantCookieTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// visitorID 是没有变体 cookie 的访问者的稳定标识，用于哈希分配变体
+func visitorID(r *http.Request) string {
+	return clientIP(r) + "|" + r.UserAgent()
+}
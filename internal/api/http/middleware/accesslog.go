@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// AccessLog 在请求结束后记录一行访问日志：方法、路径、状态码、响应字节数、耗时、客户端 IP 与请求 ID
+// 应放在 RequestID 与 ClientIP 之内；handler panic 时同样会记录
+func AccessLog(logger *log.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := capture(w)
+			defer func() {
+				ip := ClientIPFrom(r.Context())
+				if ip == "" {
+					ip = r.RemoteAddr
+				}
+				logger.Printf("INFO: Request completed. Method: %s, Path: %s, Status: %d, Bytes: %d, Duration: %s, ClientIP: %s, RequestID: %s\n",
+					r.Method, r.URL.Path, rw.statusCode(), rw.bytes, time.Since(start).Round(time.Microsecond), ip, RequestIDFrom(r.Context()))
+			}()
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
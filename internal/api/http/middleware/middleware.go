@@ -0,0 +1,69 @@
+// Package middleware 提供 HTTP 服务共用的中间件：请求 ID、客户端 IP、访问日志与 panic 恢复
+package middleware
+
+import (
+	"net/http"
+)
+
+// Middleware 包装一个 http.Handler，在其前后执行额外的逻辑
+type Middleware func(http.Handler) http.Handler
+
+// Chain 把多个中间件组合为一个，第一个位于最外层、最先执行
+func Chain(mws ...Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		for i := len(mws) - 1; i >= 0; i-- {
+			next = mws[i](next)
+		}
+		return next
+	}
+}
+
+// responseWriter 记录写出的状态码与字节数，供访问日志与 panic 恢复判断响应的状态
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// capture 返回记录状态的 ResponseWriter，w 已经是时直接复用，同一请求的中间件看到同一份记录
+func capture(w http.ResponseWriter) *responseWriter {
+	if rw, ok := w.(*responseWriter); ok {
+		return rw
+	}
+	return &responseWriter{ResponseWriter: w}
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	// 1xx(如 103 Early Hints)之后还会有最终状态码，101 除外
+	if w.status == 0 && (status >= http.StatusOK || status == http.StatusSwitchingProtocols) {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap 让 http.ResponseController 可以访问底层的 ResponseWriter(Flush、读写超时等)
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// wroteHeader 判断响应头是否已经发出
+func (w *responseWriter) wroteHeader() bool {
+	return w.status != 0
+}
+
+// statusCode 返回最终的状态码，handler 没有写任何内容时为 200
+func (w *responseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
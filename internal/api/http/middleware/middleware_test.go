@@ -0,0 +1,214 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestChain_Order(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	h := Chain(mark("a"), mark("b"), mark("c"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := strings.Join(order, ","); got != "a,b,c,handler" {
+		t.Errorf("order = %s, want a,b,c,handler", got)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{name: "propagates incoming ID", incoming: "req-123", keep: true},
+		{name: "generates when missing", incoming: ""},
+		{name: "replaces ID with spaces", incoming: "a b"},
+		{name: "replaces non-ASCII ID", incoming: "请求"},
+		{name: "replaces overlong ID", incoming: strings.Repeat("x", maxRequestIDLen+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			h := RequestID()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = RequestIDFrom(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			got := rec.Header().Get(RequestIDHeader)
+			if got == "" || got != seen {
+				t.Fatalf("response ID %q, context ID %q; want equal and non-empty", got, seen)
+			}
+			if (got == tt.incoming) != tt.keep {
+				t.Errorf("ID = %q, incoming %q, want kept = %v", got, tt.incoming, tt.keep)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("fd00::/8")}
+	tests := []struct {
+		name    string
+		trusted []netip.Prefix
+		remote  string
+		xff     []string
+		want    string
+	}{
+		{name: "no proxies configured ignores header", remote: "10.0.0.1:1234", xff: []string{"203.0.113.7"}, want: "10.0.0.1"},
+		{name: "untrusted peer cannot spoof", trusted: trusted, remote: "198.51.100.1:1234", xff: []string{"203.0.113.7"}, want: "198.51.100.1"},
+		{name: "trusted proxy", trusted: trusted, remote: "10.0.0.1:1234", xff: []string{"203.0.113.7"}, want: "203.0.113.7"},
+		{name: "skips trusted hops from the right", trusted: trusted, remote: "10.0.0.1:1234", xff: []string{"203.0.113.7, 10.1.1.1"}, want: "203.0.113.7"},
+		{name: "ignores client-supplied entries", trusted: trusted, remote: "10.0.0.1:1234", xff: []string{"1.1.1.1, 203.0.113.7"}, want: "203.0.113.7"},
+		{name: "multiple headers", trusted: trusted, remote: "10.0.0.1:1234", xff: []string{"1.1.1.1", "203.0.113.7, 10.1.1.1"}, want: "203.0.113.7"},
+		{name: "all hops trusted", trusted: trusted, remote: "10.0.0.1:1234", xff: []string{"10.2.2.2, 10.1.1.1"}, want: "10.2.2.2"},
+		{name: "stops at malformed entry", trusted: trusted, remote: "10.0.0.1:1234", xff: []string{"203.0.113.7, garbage, 10.1.1.1"}, want: "10.1.1.1"},
+		{name: "trusted proxy without header", trusted: trusted, remote: "10.0.0.1:1234", want: "10.0.0.1"},
+		{name: "IPv6", trusted: trusted, remote: "[fd00::1]:1234", xff: []string{"2001:db8::7"}, want: "2001:db8::7"},
+		{name: "IPv4-mapped proxy", trusted: trusted, remote: "[::ffff:10.0.0.1]:1234", xff: []string{"203.0.113.7"}, want: "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := ClientIP(tt.trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = ClientIPFrom(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			for _, v := range tt.xff {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Errorf("client IP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{
+			name:    "implicit 200",
+			handler: func(w http.ResponseWriter, r *http.Request) {},
+			want:    "Status: 200, Bytes: 0,",
+		},
+		{
+			name: "status and bytes",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				io.WriteString(w, "missing")
+			},
+			want: "Status: 404, Bytes: 7,",
+		},
+		{
+			name: "informational status is not final",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusEarlyHints)
+				w.WriteHeader(http.StatusFound)
+			},
+			want: "Status: 302,",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := Chain(RequestID(), ClientIP(nil), AccessLog(log.New(&buf, "", 0)))(tt.handler)
+			req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+			req.Header.Set(RequestIDHeader, "req-1")
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			line := buf.String()
+			for _, want := range []string{"INFO: Request completed. Method: GET, Path: /abc,", tt.want, "ClientIP: 192.0.2.1,", "RequestID: req-1"} {
+				if !strings.Contains(line, want) {
+					t.Errorf("log %q does not contain %q", line, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRecover(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New(&buf, "", 0)
+	onPanic := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "custom")
+	}
+	h := Chain(RequestID(), AccessLog(logger), Recover(logger, onPanic))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "https://example.com")
+		panic("boom")
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError || rec.Body.String() != "custom" {
+		t.Errorf("response = %d %q, want 500 from onPanic", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Location") != "" {
+		t.Errorf("Location header of the aborted response was kept")
+	}
+	if rec.Header().Get(RequestIDHeader) != "req-1" {
+		t.Errorf("X-Request-ID = %q, want req-1", rec.Header().Get(RequestIDHeader))
+	}
+	logs := buf.String()
+	for _, want := range []string{"ERROR: Panic serving GET /abc. RequestID: req-1, Panic: boom", "middleware_test.go", "Status: 500"} {
+		if !strings.Contains(logs, want) {
+			t.Errorf("logs do not contain %q:\n%s", want, logs)
+		}
+	}
+}
+
+func TestRecover_AfterHeaderWritten(t *testing.T) {
+	h := Recover(log.New(io.Discard, "", 0), nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "partial")
+		panic("boom")
+	}))
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("panic = %v, want http.ErrAbortHandler to abort the connection", v)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+// TestResponseWriter_Unwrap 保证经过中间件后 http.ResponseController 仍能访问底层连接，流式批量导入依赖它
+func TestResponseWriter_Unwrap(t *testing.T) {
+	h := Chain(AccessLog(log.New(io.Discard, "", 0)), Recover(log.New(io.Discard, "", 0), nil))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "line\n")
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Flush: %v", err)
+		}
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !rec.Flushed {
+		t.Error("response was not flushed")
+	}
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"runtime/debug"
+)
+
+// Recover 捕获 handler 的 panic，记录调用栈后由 onPanic 写出错误响应，避免请求无声地中断
+// 不同路由分组的错误格式不同(API 为 problem+json，跳转为 HTML 页面)，onPanic 为 nil 时写出纯文本 500。
+// 响应头已经发出时无法再改写状态码，只能中断连接，让客户端知道响应不完整
+func Recover(logger *log.Logger, onPanic http.HandlerFunc) Middleware {
+	if onPanic == nil {
+		onPanic = func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := capture(w)
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				// net/http 用 ErrAbortHandler 主动中断响应，不是程序错误
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				logger.Printf("ERROR: Panic serving %s %s. RequestID: %s, Panic: %v\n%s", r.Method, r.URL.Path, RequestIDFrom(r.Context()), v, debug.Stack())
+				if rw.wroteHeader() {
+					panic(http.ErrAbortHandler)
+				}
+				// panic 之前设置的响应头(Location、ETag 等)属于未完成的响应，只保留请求 ID
+				h := rw.Header()
+				id := h.Get(RequestIDHeader)
+				clear(h)
+				if id != "" {
+					h.Set(RequestIDHeader, id)
+				}
+				onPanic(rw, r)
+			}()
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
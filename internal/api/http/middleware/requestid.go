@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+const (
+	// RequestIDHeader 携带请求 ID 的请求头与响应头
+	RequestIDHeader = "X-Request-ID"
+	maxRequestIDLen = 128
+)
+
+type requestIDKey struct{}
+
+// RequestID 为每个请求确定一个 ID，写入响应头并放入 context，日志与错误响应据此关联同一请求
+func RequestID() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := NewRequestID(r)
+			w.Header().Set(RequestIDHeader, id)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+		})
+	}
+}
+
+// RequestIDFrom 返回 RequestID 中间件放入 context 的请求 ID，没有经过该中间件时为空
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID 沿用客户端或上游代理传入的 X-Request-ID，没有或不合法(过长、含空白或非 ASCII 字符)时生成一个
+func NewRequestID(r *http.Request) string {
+	id := r.Header.Get(RequestIDHeader)
+	if id == "" || len(id) > maxRequestIDLen || strings.ContainsFunc(id, func(c rune) bool { return c <= ' ' || c > '~' }) {
+		b := make([]byte, 8)
+		rand.Read(b)
+		id = hex.EncodeToString(b)
+	}
+	return id
+}
@@ -0,0 +1,46 @@
+package openapi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// Spec 是 HTTP API 的 OpenAPI 3.1 描述，修改接口时需要同步更新 openapi.json
+//
+//go:embed openapi.json
+var Spec []byte
+
+//go:embed docs.html
+var docsPage []byte
+
+var (
+	specETag = etag(Spec)
+	docsETag = etag(docsPage)
+)
+
+// ServeSpec 输出 OpenAPI 文档 GET /api/openapi.json
+func ServeSpec(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, "application/json", specETag, Spec)
+}
+
+// ServeDocs 输出渲染 OpenAPI 文档的页面 GET /api/docs，页面不引用任何外部资源
+func ServeDocs(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, "text/html; charset=utf-8", docsETag, docsPage)
+}
+
+// serve 文档随二进制发布，内容只在升级时变化，客户端用 ETag 重新验证
+func serve(w http.ResponseWriter, r *http.Request, contentType, tag string, content []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", tag)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+}
+
+func etag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
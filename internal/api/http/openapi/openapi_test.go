@@ -0,0 +1,79 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSpec_Valid(t *testing.T) {
+	var spec map[string]any
+	if err := json.Unmarshal(Spec, &spec); err != nil {
+		t.Fatalf("parse openapi.json: %v", err)
+	}
+	if spec["openapi"] != "3.1.0" {
+		t.Errorf("openapi = %v, want 3.1.0", spec["openapi"])
+	}
+	if info, _ := spec["info"].(map[string]any); info["version"] == "" || info["version"] == nil {
+		t.Errorf("info.version is empty")
+	}
+
+	// 每个 $ref 都必须指向文档内存在的定义
+	var walk func(node any)
+	walk = func(node any) {
+		switch v := node.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				var target any = spec
+				for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+					m, _ := target.(map[string]any)
+					target = m[key]
+				}
+				if target == nil {
+					t.Errorf("unresolved $ref %q", ref)
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(spec)
+}
+
+func TestServe(t *testing.T) {
+	tests := []struct {
+		name            string
+		handler         http.HandlerFunc
+		ifNoneMatch     bool
+		wantStatus      int
+		wantContentType string
+	}{
+		{name: "spec", handler: ServeSpec, wantStatus: http.StatusOK, wantContentType: "application/json"},
+		{name: "docs", handler: ServeDocs, wantStatus: http.StatusOK, wantContentType: "text/html; charset=utf-8"},
+		{name: "spec revalidated", handler: ServeSpec, ifNoneMatch: true, wantStatus: http.StatusNotModified},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil)
+			if tt.ifNoneMatch {
+				req.Header.Set("If-None-Match", specETag)
+			}
+			rec := httptest.NewRecorder()
+			tt.handler(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantContentType != "" && rec.Header().Get("Content-Type") != tt.wantContentType {
+				t.Errorf("Content-Type = %q, want %q", rec.Header().Get("Content-Type"), tt.wantContentType)
+			}
+		})
+	}
+}
@@ -5,9 +5,15 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"net/netip"
 	"os"
 	"shortlink/internal/api/http/handler"
+	"shortlink/internal/api/http/middleware"
+	"shortlink/internal/api/http/openapi"
+	"shortlink/internal/metrics"
 	"shortlink/internal/shortener"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -17,33 +23,155 @@ type Server struct {
 	logger     *log.Logger
 }
 
-func NewServer(port string, service *shortener.Service) *Server {
-	logger := log.New(os.Stdout, "[HTTP Server] ", log.LstdFlags|log.Lshortfile)
-	linkAPIHandler := handler.NewLinkAPI(service, logger)
-	mux := http.NewServeMux()
+type Config struct {
+	Port    string
+	Service *shortener.Service
+	// Metrics 非空时在 /metrics 暴露计数器
+	Metrics *metrics.Registry
+	LinkAPI handler.Options
+	// TrustedProxies 可信反向代理的网段，只有来自它们的请求才按 X-Forwarded-For 识别客户端 IP
+	TrustedProxies []netip.Prefix
+	// AccessLog 记录访问日志的路由分组，为空时不记录
+	AccessLog []Group
+	// Middleware 按路由分组追加的中间件，位于内置中间件之内，按顺序执行
+	Middleware map[Group][]middleware.Middleware
+}
 
-	mux.HandleFunc("POST /api/links", linkAPIHandler.CreateLink)
-	mux.HandleFunc("GET /", linkAPIHandler.RedirectLink)
-	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+// Group 是路由分组，同一分组的路由共用一条中间件链
+type Group string
 
-	})
+const (
+	// GroupAPI /api/ 下的 JSON 接口，错误为 problem+json
+	GroupAPI Group = "api"
+	// GroupRedirect 短链接跳转与密码解锁，面向浏览器，错误为 HTML 页面
+	GroupRedirect Group = "redirect"
+	// GroupOps /healthz 与 /metrics，供探针与监控系统频繁访问
+	GroupOps Group = "ops"
+)
+
+func NewServer(cfg Config) *Server {
+	logger := log.New(os.Stdout, "[HTTP Server] ", log.LstdFlags|log.Lshortfile)
+	linkAPIHandler := handler.NewLinkAPI(cfg.Service, logger, cfg.LinkAPI)
+	chains := map[Group]middleware.Middleware{}
+	for _, g := range []Group{GroupAPI, GroupRedirect, GroupOps} {
+		chains[g] = chain(g, linkAPIHandler, logger, cfg)
+	}
+	mux := http.NewServeMux()
+	rs := routes(linkAPIHandler, cfg)
+	for _, rt := range rs {
+		mux.Handle(rt.pattern, chains[rt.group](rt.handler))
+	}
+	// 没有兜底时，/api/ 下未知的路径落入短链接跳转并得到 HTML 错误页。
+	// 不带方法的 "/api/" 与 "GET /" 互不包含，ServeMux 视为冲突，因此按方法逐个注册
+	fallback := chains[GroupAPI](apiFallback(mux, rs, linkAPIHandler))
+	for _, method := range fallbackMethods {
+		mux.Handle(method+" /api/", fallback)
+	}
 
 	return &Server{
 		httpServer: &http.Server{
-			Addr:         ":" + port,
+			Addr:         ":" + cfg.Port,
 			Handler:      mux,
 			ReadTimeout:  5 * time.Second,
 			WriteTimeout: 10 * time.Second,
 			IdleTimeout:  120 * time.Second,
 		},
-		service: service,
+		service: cfg.Service,
 		logger:  logger,
 	}
 }
 
+// route 是一条注册到 ServeMux 的路由，pattern 使用 "METHOD /path/{code}" 语法
+type route struct {
+	group   Group
+	pattern string
+	handler http.Handler
+}
+
+// chain 返回分组 g 的中间件链，由外到内依次为：
+// 请求 ID、客户端 IP、访问日志(分组在 cfg.AccessLog 中时)、panic 恢复、cfg.Middleware 中该分组的中间件。
+// 恢复位于访问日志之内，panic 的请求以 500 记录；panic 时按分组写出 problem+json、HTML 错误页或纯文本
+func chain(g Group, api *handler.LinkAPI, logger *log.Logger, cfg Config) middleware.Middleware {
+	mws := []middleware.Middleware{middleware.RequestID(), middleware.ClientIP(cfg.TrustedProxies)}
+	if slices.Contains(cfg.AccessLog, g) {
+		mws = append(mws, middleware.AccessLog(logger))
+	}
+	var onPanic http.HandlerFunc
+	switch g {
+	case GroupAPI:
+		onPanic = api.InternalError
+	case GroupRedirect:
+		onPanic = api.InternalErrorPage
+	}
+	mws = append(mws, middleware.Recover(logger, onPanic))
+	return middleware.Chain(append(mws, cfg.Middleware[g]...)...)
+}
+
+// routes 返回服务的全部路由及其分组，NewServer 逐条套上分组的中间件链后注册；测试据此检查每条路由都在 OpenAPI 文档中有描述
+func routes(api *handler.LinkAPI, cfg Config) []route {
+	rs := []route{
+		{GroupAPI, "POST /api/links", api.Idempotent(api.CreateLink)},
+		{GroupAPI, "POST /api/links:batch", http.HandlerFunc(api.BatchCreateLinks)},
+		{GroupAPI, "GET /api/links/{code}", http.HandlerFunc(api.GetLink)},
+		{GroupAPI, "PATCH /api/links/{code}", http.HandlerFunc(api.UpdateLink)},
+		{GroupAPI, "DELETE /api/links/{code}", http.HandlerFunc(api.DeleteLink)},
+		{GroupAPI, "GET /api/links/{code}/versions", http.HandlerFunc(api.ListVersions)},
+		{GroupAPI, "POST /api/links/{code}/rollback", http.HandlerFunc(api.RollbackLink)},
+		{GroupAPI, "POST /api/links/{code}/rules/test", http.HandlerFunc(api.TestRules)},
+		{GroupAPI, "GET /api/links/{code}/variants", http.HandlerFunc(api.ListVariants)},
+		{GroupAPI, "PATCH /api/links/{code}/variants", http.HandlerFunc(api.SetVariantWeights)},
+		{GroupAPI, "POST /api/links/{code}/conversions", http.HandlerFunc(api.RecordConversion)},
+		{GroupAPI, "GET /api/links/{code}/metadata", http.HandlerFunc(api.GetLinkMetadata)},
+		{GroupAPI, "GET /api/links/{code}/health", http.HandlerFunc(api.GetLinkHealth)},
+		{GroupAPI, "POST /api/tagging/preview", http.HandlerFunc(api.PreviewTagging)},
+		{GroupAPI, "GET /api/openapi.json", http.HandlerFunc(openapi.ServeSpec)},
+		{GroupAPI, "GET /api/docs", http.HandlerFunc(openapi.ServeDocs)},
+		{GroupRedirect, "GET /", http.HandlerFunc(api.RedirectLink)},
+		{GroupRedirect, "POST /", http.HandlerFunc(api.UnlockLink)},
+		{GroupOps, "GET /healthz", http.HandlerFunc(healthz)},
+	}
+	if cfg.Metrics != nil {
+		rs = append(rs, route{GroupOps, "GET /metrics", cfg.Metrics})
+	}
+	return rs
+}
+
+// fallbackMethods 由 API 兜底处理的请求方法，其他方法由 ServeMux 直接返回 405
+var fallbackMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions}
+
+// apiFallback 处理 /api/ 下没有匹配路由的请求，以 problem+json 响应：
+// 路径存在但方法不支持时返回 405 并在 Allow 中列出支持的方法，否则返回 404
+func apiFallback(mux *http.ServeMux, rs []route, api *handler.LinkAPI) http.Handler {
+	var methods []string
+	for _, rt := range rs {
+		if method, _, ok := strings.Cut(rt.pattern, " "); ok && !slices.Contains(methods, method) {
+			methods = append(methods, method)
+		}
+	}
+	slices.Sort(methods)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var allow []string
+		for _, method := range methods {
+			probe := r.Clone(r.Context())
+			probe.Method = method
+			if _, pattern := mux.Handler(probe); pattern != "" && !strings.HasSuffix(pattern, " /") && !strings.HasSuffix(pattern, " /api/") {
+				allow = append(allow, method)
+			}
+		}
+		if len(allow) > 0 {
+			api.MethodNotAllowed(w, r, allow)
+			return
+		}
+		api.NotFound(w, r)
+	})
+}
+
+func healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
 func (s *Server) Start() error {
 	s.logger.Printf("Server listening on %s\n", s.httpServer.Addr)
 	return s.httpServer.ListenAndServe()
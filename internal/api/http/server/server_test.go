@@ -0,0 +1,209 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shortlink/internal/api/http/handler"
+	"shortlink/internal/api/http/middleware"
+	"shortlink/internal/api/http/openapi"
+	"shortlink/internal/idgen"
+	"shortlink/internal/metrics"
+	"shortlink/internal/shortener"
+	"shortlink/internal/storage"
+)
+
+// TestRoutes_DocumentedInOpenAPI 保证 NewServer 注册的每条路由都在 OpenAPI 文档中有描述，反之亦然
+func TestRoutes_DocumentedInOpenAPI(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	svc := shortener.NewService(shortener.Config{
+		Store:     storage.NewMemoryStore(),
+		Generator: idgen.NewGenerator(),
+		Logger:    logger,
+	})
+	cfg := Config{Service: svc, Metrics: metrics.NewRegistry()}
+	api := handler.NewLinkAPI(svc, logger, cfg.LinkAPI)
+
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openapi.Spec, &spec); err != nil {
+		t.Fatalf("parse openapi.json: %v", err)
+	}
+
+	registered := map[string]bool{}
+	for _, rt := range routes(api, cfg) {
+		method, path, ok := strings.Cut(rt.pattern, " ")
+		if !ok {
+			t.Errorf("route %q has no method", rt.pattern)
+			continue
+		}
+		// "/" 匹配所有未注册的路径，即短链接本身
+		if path == "/" {
+			path = "/{code}"
+		}
+		op := strings.ToLower(method) + " " + path
+		registered[op] = true
+		if _, ok := spec.Paths[path][strings.ToLower(method)]; !ok {
+			t.Errorf("route %q is not documented in openapi.json (want paths[%q].%s)", rt.pattern, path, strings.ToLower(method))
+		}
+	}
+	for path, item := range spec.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+			if !registered[method+" "+path] {
+				t.Errorf("openapi.json documents %s %s, which is not registered", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+// TestChain_Groups 检查各分组的中间件链：panic 的错误格式、访问日志开关与按分组追加的中间件
+func TestChain_Groups(t *testing.T) {
+	var logs bytes.Buffer
+	logger := log.New(&logs, "", 0)
+	svc := shortener.NewService(shortener.Config{
+		Store:     storage.NewMemoryStore(),
+		Generator: idgen.NewGenerator(),
+		Logger:    log.New(io.Discard, "", 0),
+	})
+	api := handler.NewLinkAPI(svc, logger, handler.Options{})
+	tagged := false
+	tag := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tagged = true
+			next.ServeHTTP(w, r)
+		})
+	}
+	cfg := Config{
+		AccessLog:  []Group{GroupAPI},
+		Middleware: map[Group][]middleware.Middleware{GroupOps: {tag}},
+	}
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+
+	tests := []struct {
+		group       Group
+		contentType string
+		accessLog   bool
+		tagged      bool
+	}{
+		{group: GroupAPI, contentType: "application/problem+json", accessLog: true},
+		{group: GroupRedirect, contentType: "text/html; charset=utf-8"},
+		{group: GroupOps, contentType: "text/plain; charset=utf-8", tagged: true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.group), func(t *testing.T) {
+			logs.Reset()
+			tagged = false
+			req := httptest.NewRequest(http.MethodGet, "/x", nil)
+			req.Header.Set("X-Request-ID", "req-1")
+			rec := httptest.NewRecorder()
+			chain(tt.group, api, logger, cfg)(panicking).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusInternalServerError {
+				t.Errorf("status = %d, want 500", rec.Code)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if !strings.Contains(rec.Body.String(), "req-1") && tt.group != GroupOps {
+				t.Errorf("body does not show the request ID: %s", rec.Body.String())
+			}
+			if rec.Header().Get("X-Request-ID") != "req-1" {
+				t.Errorf("X-Request-ID = %q, want req-1", rec.Header().Get("X-Request-ID"))
+			}
+			if !strings.Contains(logs.String(), "ERROR: Panic serving GET /x") {
+				t.Errorf("panic was not logged:\n%s", logs.String())
+			}
+			if got := strings.Contains(logs.String(), "Request completed"); got != tt.accessLog {
+				t.Errorf("access logged = %v, want %v", got, tt.accessLog)
+			}
+			if tagged != tt.tagged {
+				t.Errorf("group middleware applied = %v, want %v", tagged, tt.tagged)
+			}
+		})
+	}
+}
+
+// TestServer_SlashInShortCode 自定义短码中的 / 在管理接口中编码为 %2F，整个短码作为一个路径段匹配
+func TestServer_SlashInShortCode(t *testing.T) {
+	svc := shortener.NewService(shortener.Config{
+		Store:     storage.NewMemoryStore(),
+		Generator: idgen.NewGenerator(),
+		Logger:    log.New(io.Discard, "", 0),
+	})
+	if _, err := svc.Create(context.Background(), shortener.CreateParams{LongURL: "https://api.example.com/ref", Alias: "docs/api"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	h := NewServer(Config{Service: svc}).httpServer.Handler
+
+	tests := []struct {
+		method     string
+		target     string
+		wantStatus int
+		wantBody   string
+	}{
+		{method: http.MethodGet, target: "/api/links/docs%2Fapi", wantStatus: http.StatusOK, wantBody: `"short_code":"docs/api"`},
+		{method: http.MethodGet, target: "/api/links/docs%2Fapi/versions", wantStatus: http.StatusOK, wantBody: `"short_code":"docs/api"`},
+		{method: http.MethodGet, target: "/docs/api", wantStatus: http.StatusFound},
+		{method: http.MethodDelete, target: "/api/links/docs%2Fapi", wantStatus: http.StatusNoContent},
+		{method: http.MethodGet, target: "/api/links/docs%2Fapi", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+		if rec.Code != tt.wantStatus || !strings.Contains(rec.Body.String(), tt.wantBody) {
+			t.Errorf("%s %s = %d %q, want %d containing %q", tt.method, tt.target, rec.Code, rec.Body.String(), tt.wantStatus, tt.wantBody)
+		}
+	}
+}
+
+// TestServer_APIFallback /api/ 下的未知路径与不支持的方法同样返回 problem+json，而不是跳转的 HTML 错误页
+func TestServer_APIFallback(t *testing.T) {
+	svc := shortener.NewService(shortener.Config{
+		Store:     storage.NewMemoryStore(),
+		Generator: idgen.NewGenerator(),
+		Logger:    log.New(io.Discard, "", 0),
+	})
+	h := NewServer(Config{Service: svc}).httpServer.Handler
+
+	tests := []struct {
+		method     string
+		target     string
+		wantStatus int
+		wantCode   string
+		wantAllow  string
+	}{
+		{method: http.MethodGet, target: "/api/nothing", wantStatus: http.StatusNotFound, wantCode: "not_found"},
+		{method: http.MethodGet, target: "/api/links/docs/api", wantStatus: http.StatusNotFound, wantCode: "not_found"},
+		{method: http.MethodPost, target: "/api/nothing", wantStatus: http.StatusNotFound, wantCode: "not_found"},
+		{method: http.MethodPut, target: "/api/links/abc", wantStatus: http.StatusMethodNotAllowed, wantCode: "method_not_allowed", wantAllow: "DELETE, GET, PATCH"},
+		{method: http.MethodGet, target: "/api/links", wantStatus: http.StatusMethodNotAllowed, wantCode: "method_not_allowed", wantAllow: "POST"},
+		{method: http.MethodDelete, target: "/api/openapi.json", wantStatus: http.StatusMethodNotAllowed, wantCode: "method_not_allowed", wantAllow: "GET"},
+		{method: http.MethodGet, target: "/api/links/abcdefg", wantStatus: http.StatusNotFound, wantCode: "link_not_found"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+		var p handler.Problem
+		json.Unmarshal(rec.Body.Bytes(), &p)
+		if rec.Code != tt.wantStatus || rec.Header().Get("Content-Type") != "application/problem+json" || p.Code != tt.wantCode {
+			t.Errorf("%s %s = %d %s %q, want %d problem+json %q", tt.method, tt.target, rec.Code, rec.Header().Get("Content-Type"), p.Code, tt.wantStatus, tt.wantCode)
+		}
+		if got := rec.Header().Get("Allow"); got != tt.wantAllow {
+			t.Errorf("%s %s Allow = %q, want %q", tt.method, tt.target, got, tt.wantAllow)
+		}
+		if p.RequestID == "" || p.RequestID != rec.Header().Get("X-Request-ID") {
+			t.Errorf("%s %s request_id = %q, X-Request-ID = %q", tt.method, tt.target, p.RequestID, rec.Header().Get("X-Request-ID"))
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"shortlink/internal/config"
+	"shortlink/internal/idgen"
+	"shortlink/internal/shortener"
+	"shortlink/internal/urlnorm"
+)
+
+const idgenUsage = `usage: shortlink idgen <command> [flags]
+
+commands:
+  code     print the deterministic short code for each URL (args or stdin, one per line)
+  analyze  sample a generator and report collisions, keyspace, uniformity and throughput
+`
+
+// RunIdgen 执行 shortlink idgen 子命令
+func RunIdgen(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, idgenUsage)
+		return errors.New("missing command")
+	}
+	switch args[0] {
+	case "code":
+		return runIdgenCode(args[1:], os.Stdin, stdout, stderr)
+	case "analyze":
+		return runIdgenAnalyze(args[1:], stdout, stderr)
+	default:
+		fmt.Fprint(stderr, idgenUsage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// runIdgenCode 离线计算确定性短码，CI 可据此预先生成短码
+// 默认值取自与服务进程相同的 SHORTLINK_* 环境变量，保证两边结果一致
+func runIdgenCode(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("idgen code", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	key := fs.String("key", cfg.IDGen.Key, "HMAC key (defaults to $SHORTLINK_IDGEN_KEY)")
+	length := fs.Int("length", cfg.IDGen.CodeLength, "short code length")
+	blocklistFile := fs.String("blocklist", cfg.IDGen.BlocklistFile, "extra blocklist file")
+	salt := fs.Int("salt", 0, "salt index, i.e. which collision-free candidate to print")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opts := cfg.IDGen.Options(nil)
+	opts.Mode = idgen.ModeDeterministic
+	opts.Key = *key
+	opts.CodeLength = *length
+	opts.BlocklistFile = *blocklistFile
+	opts.Logger = log.New(stderr, "", 0)
+	gen, err := idgen.New(opts)
+	if err != nil {
+		return err
+	}
+	salted, ok := gen.(idgen.SaltedGenerator)
+	if !ok {
+		return errors.New("generator does not support salts")
+	}
+
+	urls := fs.Args()
+	if len(urls) == 0 {
+		scanner := bufio.NewScanner(stdin)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				urls = append(urls, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("read urls: %w", err)
+		}
+	}
+
+	// 与服务创建链接时相同的规范化规则，短码哈希的是规范化后的 URL
+	normalizer := urlnorm.New(cfg.URL.Options())
+	ctx := context.Background()
+	for _, u := range urls {
+		longURL, err := shortener.NormalizeLongURL(normalizer, u)
+		if err != nil {
+			return fmt.Errorf("normalize %s: %w", u, err)
+		}
+		code, err := salted.GenerateSaltedShortCode(ctx, longURL, *salt)
+		if err != nil {
+			return fmt.Errorf("generate code for %s: %w", u, err)
+		}
+		fmt.Fprintf(stdout, "%s\t%s\n", code, u)
+	}
+	return nil
+}
+
+// runIdgenAnalyze 在调整字符集或长度之前，用真实数据评估当前生成器的短码空间
+func runIdgenAnalyze(args []string, stdout, stderr io.Writer) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("idgen analyze", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	mode := fs.String("mode", cfg.IDGen.Mode, "generator mode: random or deterministic")
+	key := fs.String("key", cfg.IDGen.Key, "HMAC key for deterministic mode")
+	length := fs.Int("length", cfg.IDGen.CodeLength, "code length for deterministic mode")
+	samples := fs.Int("n", 100000, "number of codes to generate")
+	volume := fs.Int64("volume", 10000000, "target link volume for the birthday-bound estimate")
+	alphabet := fs.String("alphabet", idgen.Base64URLAlphabet, "expected alphabet for the uniformity test")
+	format := fs.String("format", "text", "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	opts := cfg.IDGen.Options(nil)
+	opts.Mode = *mode
+	opts.Key = *key
+	opts.CodeLength = *length
+	opts.Logger = log.New(io.Discard, "", 0)
+	gen, err := idgen.New(opts)
+	if err != nil {
+		return err
+	}
+	report, err := idgen.Analyze(context.Background(), gen, idgen.AnalyzeOptions{
+		Samples:      *samples,
+		TargetVolume: *volume,
+		Alphabet:     *alphabet,
+	})
+	if err != nil {
+		return err
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	writeReportText(stdout, *mode, report)
+	return nil
+}
+
+func writeReportText(w io.Writer, mode string, r *idgen.Report) {
+	fmt.Fprintf(w, "generator:           %s\n", mode)
+	fmt.Fprintf(w, "samples:             %d (unique %d, collisions %d)\n", r.Samples, r.Unique, r.Collisions)
+	fmt.Fprintf(w, "code length:         %d\n", r.CodeLength)
+	fmt.Fprintf(w, "alphabet size:       %d\n", r.AlphabetSize)
+	fmt.Fprintf(w, "keyspace:            %.4g\n", r.Keyspace)
+	fmt.Fprintf(w, "target volume:       %d\n", r.TargetVolume)
+	fmt.Fprintf(w, "expected collisions: %.4g\n", r.ExpectedCollisions)
+	fmt.Fprintf(w, "P(any collision):    %.4g\n", r.CollisionProb)
+	fmt.Fprintf(w, "chi-square:          %.2f (df %d, p=%.4f)\n", r.ChiSquare, r.DegreesOfFreedom, r.UniformityPValue)
+	if r.UnexpectedChars > 0 {
+		fmt.Fprintf(w, "unexpected chars:    %d\n", r.UnexpectedChars)
+	}
+	fmt.Fprintf(w, "elapsed:             %s\n", r.Elapsed)
+	fmt.Fprintf(w, "throughput:          %.0f codes/s\n", r.ThroughputPerSecond)
+	fmt.Fprintln(w, "char distribution:")
+	for _, c := range r.CharCounts {
+		fmt.Fprintf(w, "  %s %d\n", c.Char, c.Count)
+	}
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"shortlink/internal/config"
+	"shortlink/internal/idgen"
+	"shortlink/internal/shortener"
+	"shortlink/internal/storage"
+	"shortlink/internal/urlnorm"
+)
+
+// TestRunIdgenCode_MatchesService 离线计算的短码必须与服务创建链接时分配的短码一致
+func TestRunIdgenCode_MatchesService(t *testing.T) {
+	t.Setenv("SHORTLINK_IDGEN_MODE", idgen.ModeDeterministic)
+	t.Setenv("SHORTLINK_IDGEN_KEY", "test-key")
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		longURL string
+	}{
+		{name: "plain", longURL: "https://example.com/a"},
+		{name: "IDN host with tracking parameter", longURL: "https://bücher.de/x?utm_source=news&id=1"},
+		{name: "uppercase host and default port", longURL: "HTTPS://Example.COM:443/b#top"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if err := RunIdgen([]string{"code", tt.longURL}, &stdout, &stderr); err != nil {
+				t.Fatalf("RunIdgen() error = %v, stderr: %s", err, stderr.String())
+			}
+			got, _, _ := strings.Cut(stdout.String(), "\t")
+
+			gen, err := idgen.New(cfg.IDGen.Options(nil))
+			if err != nil {
+				t.Fatalf("idgen.New() error = %v", err)
+			}
+			svc := shortener.NewService(shortener.Config{
+				Store:         storage.NewMemoryStore(),
+				Generator:     gen,
+				Logger:        log.New(io.Discard, "", 0),
+				URLNormalizer: urlnorm.New(cfg.URL.Options()),
+			})
+			link, err := svc.Create(context.Background(), shortener.CreateParams{LongURL: tt.longURL})
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			if got != link.ShortCode {
+				t.Errorf("idgen code = %q, service code = %q (stored %q)", got, link.ShortCode, link.LongURL)
+			}
+		})
+	}
+}
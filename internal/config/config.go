@@ -1,20 +1,493 @@
 package config
 
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"shortlink/internal/idgen"
+	"shortlink/internal/metrics"
+	"shortlink/internal/urlnorm"
+)
+
 type Config struct {
-	Server ServerConfig
+	Server    ServerConfig
+	IDGen     IDGenConfig
+	URL       URLConfig
+	Policy    PolicyConfig
+	Chain     ChainConfig
+	Access    AccessConfig
+	Targeting TargetingConfig
+	Tagging   TaggingConfig
+	Metadata  MetadataConfig
+	Health    HealthConfig
+	Batch     BatchConfig
+	Redirect  RedirectConfig
+	// IdempotencyTTL 按 Idempotency-Key 保存创建响应的时长
+	IdempotencyTTL time.Duration
 }
 
 type ServerConfig struct {
 	Port     string
 	LogLevel string
+	// BaseURL 对外的短链接前缀(如 https://sho.rt)，为空时响应中不返回 short_url
+	BaseURL string
+	// TrustedProxies 可信反向代理的网段(CIDR 或单个 IP)，来自它们的 X-Forwarded-For 用于识别客户端 IP
+	TrustedProxies []netip.Prefix
+	// AccessLog 记录访问日志的路由分组："api"、"redirect"、"ops"
+	AccessLog []string
+}
+
+// RedirectConfig 控制短链接跳转的状态码与缓存
+type RedirectConfig struct {
+	// Type 未单独设置跳转类型的链接使用的状态码：301、302、307 或 308
+	Type int
+	// PermanentMaxAge 永久跳转(301/308)允许浏览器与 CDN 缓存的时长
+	PermanentMaxAge time.Duration
+}
+
+type IDGenConfig struct {
+	// Mode 短码生成方式："random"(默认) 或 "deterministic"(同一 URL 在任何实例上得到同一短码)
+	Mode string
+	// Key deterministic 模式下 HMAC 使用的密钥，所有实例以及离线预计算必须一致
+	Key string
+	// CodeLength deterministic 模式下的短码长度
+	CodeLength int
+	// BlocklistFile 运营方提供的额外屏蔽词文件(如竞品品牌)，为空时只使用内置词表
+	BlocklistFile string
+	// MaxBlockedRetries 候选短码命中屏蔽词时最多重新生成的次数
+	MaxBlockedRetries int
+}
+
+// URLConfig 控制长链接的校验与规范化
+type URLConfig struct {
+	AllowedSchemes   []string
+	StripDefaultPort bool
+	StripFragment    bool
+	// TrackingParams 创建时剔除的查询参数，以 * 结尾表示前缀匹配
+	TrackingParams []string
+	MaxLength      int
+}
+
+// PolicyConfig 控制目的域名的允许/拒绝策略
+type PolicyConfig struct {
+	// File 策略文件路径，为空时不启用策略
+	File string
+	// ReloadInterval 检查策略文件变化的间隔
+	ReloadInterval time.Duration
+	// CheckOnRedirect 跳转时也评估策略
+	CheckOnRedirect bool
+}
+
+// ChainConfig 控制跳转回环与短链接套娃的检测
+type ChainConfig struct {
+	// PublicHosts 本服务对外的域名，设置了 BaseURL 时自动包含其主机
+	PublicHosts []string
+	// KnownShorteners 其他短链接服务的域名
+	KnownShorteners []string
+	// Resolve 创建时逐跳跟随目的地址的跳转
+	Resolve bool
+	MaxHops int
+	Timeout time.Duration
+	// StoreFinal 保存跳转链的最终目的地
+	StoreFinal bool
+}
+
+// AccessConfig 控制受密码保护链接的访问
+type AccessConfig struct {
+	// CookieSecret 签名解锁 cookie 的密钥，多实例部署时必须一致
+	CookieSecret string
+	// UnlockTTL 输入正确密码后免输入的时长
+	UnlockTTL time.Duration
+	// PasswordAttempts 每个短码、每个客户端在 PasswordWindow 内允许的密码尝试次数
+	PasswordAttempts int
+	PasswordWindow   time.Duration
+	// ExhaustedFallbackURL 达到访问上限的链接改为跳转到此地址，为空时返回 410
+	ExhaustedFallbackURL string
 }
 
+// TargetingConfig 控制定向跳转规则的求值
+type TargetingConfig struct {
+	// CountryHeader 携带访问者国家代码的请求头(如 CDN 设置的 CF-IPCountry)，为空时规则中的 country 总是空字符串
+	CountryHeader string
+	// Location 规则中 hour、weekday 所用的时区
+	Location *time.Location
+}
+
+// TaggingConfig 控制 UTM 打标策略
+type TaggingConfig struct {
+	// File 打标策略文件路径，为空时不打标
+	File string
+}
+
+// MetadataConfig 控制目的页面预览信息的抓取
+type MetadataConfig struct {
+	// Fetch 创建或修改目的地后在后台抓取标题、描述、图标与 og:image
+	Fetch   bool
+	Timeout time.Duration
+	// MaxBytes 最多读取的页面字节数
+	MaxBytes int64
+	// Concurrency 同时进行的抓取上限
+	Concurrency int
+}
+
+// BatchConfig 控制批量创建接口
+type BatchConfig struct {
+	// MaxItems 一次 JSON 请求最多包含的条目数
+	MaxItems int
+	// Concurrency 同时处理的条目数
+	Concurrency int
+}
+
+// HealthConfig 控制目的地址的定期健康检查
+type HealthConfig struct {
+	// Check 启用后台健康检查
+	Check bool
+	// Interval 健康链接两次检查的间隔
+	Interval time.Duration
+	// RetryDelay 第一次失败后的重试间隔，之后每次失败翻倍，最长 MaxBackoff
+	RetryDelay time.Duration
+	MaxBackoff time.Duration
+	Timeout    time.Duration
+	// FailureThreshold 连续失败多少次后标记为失效
+	FailureThreshold int
+	// PerHost 同一主机同时进行的探测数
+	PerHost int
+	// Interstitial 为 true 时失效链接先展示提示页而不是直接跳转
+	Interstitial bool
+}
+
+// Options 转换为 urlnorm.Options
+func (c URLConfig) Options() urlnorm.Options {
+	return urlnorm.Options{
+		AllowedSchemes:   c.AllowedSchemes,
+		StripDefaultPort: c.StripDefaultPort,
+		StripFragment:    c.StripFragment,
+		TrackingParams:   c.TrackingParams,
+		MaxLength:        c.MaxLength,
+	}
+}
+
+// Options 转换为 idgen.Options，blocked 用于统计被屏蔽词拦截的候选短码
+func (c IDGenConfig) Options(blocked *metrics.Counter) idgen.Options {
+	return idgen.Options{
+		Mode:              c.Mode,
+		Key:               c.Key,
+		CodeLength:        c.CodeLength,
+		BlocklistFile:     c.BlocklistFile,
+		MaxBlockedRetries: c.MaxBlockedRetries,
+		Blocked:           blocked,
+	}
+}
+
+// LoadConfig 返回默认配置，并使用 SHORTLINK_* 环境变量覆盖
 func LoadConfig() (Config, error) {
 	config := Config{
 		Server: ServerConfig{
-			Port:     "8080",
-			LogLevel: "info",
+			Port:      "8080",
+			LogLevel:  "info",
+			AccessLog: []string{"api", "redirect"},
+		},
+		IDGen: IDGenConfig{
+			Mode:              idgen.ModeRandom,
+			CodeLength:        7,
+			MaxBlockedRetries: 10,
+		},
+		URL: URLConfig{
+			AllowedSchemes:   []string{"http", "https"},
+			StripDefaultPort: true,
+			TrackingParams:   urlnorm.DefaultTrackingParams,
+			MaxLength:        2048,
+		},
+		Policy: PolicyConfig{
+			ReloadInterval: 5 * time.Second,
+		},
+		Chain: ChainConfig{
+			PublicHosts: []string{"localhost"},
+			KnownShorteners: []string{
+				"bit.ly", "bitly.com", "t.co", "tinyurl.com", "goo.gl", "ow.ly", "is.gd", "buff.ly",
+				"rebrand.ly", "cutt.ly", "shorturl.at", "tiny.cc", "bl.ink", "lnkd.in", "s.id", "t.ly", "rb.gy",
+			},
+			MaxHops: 5,
+			Timeout: 5 * time.Second,
+		},
+		Access: AccessConfig{
+			UnlockTTL:        15 * time.Minute,
+			PasswordAttempts: 5,
+			PasswordWindow:   15 * time.Minute,
 		},
+		Targeting: TargetingConfig{
+			Location: time.UTC,
+		},
+		Metadata: MetadataConfig{
+			Timeout:     5 * time.Second,
+			MaxBytes:    512 << 10,
+			Concurrency: 4,
+		},
+		Health: HealthConfig{
+			Interval:         time.Hour,
+			RetryDelay:       time.Minute,
+			MaxBackoff:       24 * time.Hour,
+			Timeout:          10 * time.Second,
+			FailureThreshold: 3,
+			PerHost:          2,
+		},
+		Batch: BatchConfig{
+			MaxItems:    500,
+			Concurrency: 8,
+		},
+		Redirect: RedirectConfig{
+			Type:            http.StatusFound,
+			PermanentMaxAge: 24 * time.Hour,
+		},
+		IdempotencyTTL: 24 * time.Hour,
+	}
+
+	if v := os.Getenv("SHORTLINK_PORT"); v != "" {
+		config.Server.Port = v
+	}
+	if v := os.Getenv("SHORTLINK_BASE_URL"); v != "" {
+		config.Server.BaseURL = v
+	}
+	var proxies []string
+	envList("SHORTLINK_TRUSTED_PROXIES", &proxies)
+	for _, p := range proxies {
+		prefix, err := parsePrefix(p)
+		if err != nil {
+			return Config{}, fmt.Errorf("config: invalid SHORTLINK_TRUSTED_PROXIES: %w", err)
+		}
+		config.Server.TrustedProxies = append(config.Server.TrustedProxies, prefix)
+	}
+	envList("SHORTLINK_ACCESS_LOG", &config.Server.AccessLog)
+	for _, g := range config.Server.AccessLog {
+		if g != "api" && g != "redirect" && g != "ops" {
+			return Config{}, fmt.Errorf("config: unknown route group %q in SHORTLINK_ACCESS_LOG", g)
+		}
+	}
+	if v := os.Getenv("SHORTLINK_IDGEN_MODE"); v != "" {
+		config.IDGen.Mode = v
+	}
+	if v := os.Getenv("SHORTLINK_IDGEN_KEY"); v != "" {
+		config.IDGen.Key = v
+	}
+	if err := envInt("SHORTLINK_IDGEN_CODE_LENGTH", &config.IDGen.CodeLength); err != nil {
+		return Config{}, err
+	}
+	if v := os.Getenv("SHORTLINK_BLOCKLIST_FILE"); v != "" {
+		config.IDGen.BlocklistFile = v
+	}
+	if err := envInt("SHORTLINK_BLOCKLIST_MAX_RETRIES", &config.IDGen.MaxBlockedRetries); err != nil {
+		return Config{}, err
+	}
+	envList("SHORTLINK_URL_ALLOWED_SCHEMES", &config.URL.AllowedSchemes)
+	envList("SHORTLINK_URL_TRACKING_PARAMS", &config.URL.TrackingParams)
+	if err := envBool("SHORTLINK_URL_STRIP_DEFAULT_PORT", &config.URL.StripDefaultPort); err != nil {
+		return Config{}, err
+	}
+	if err := envBool("SHORTLINK_URL_STRIP_FRAGMENT", &config.URL.StripFragment); err != nil {
+		return Config{}, err
+	}
+	if err := envInt("SHORTLINK_URL_MAX_LENGTH", &config.URL.MaxLength); err != nil {
+		return Config{}, err
+	}
+	if v := os.Getenv("SHORTLINK_POLICY_FILE"); v != "" {
+		config.Policy.File = v
+	}
+	if err := envDuration("SHORTLINK_POLICY_RELOAD_INTERVAL", &config.Policy.ReloadInterval); err != nil {
+		return Config{}, err
+	}
+	if err := envBool("SHORTLINK_POLICY_ON_REDIRECT", &config.Policy.CheckOnRedirect); err != nil {
+		return Config{}, err
+	}
+	envList("SHORTLINK_PUBLIC_HOSTS", &config.Chain.PublicHosts)
+	// 短链接本身就在 BaseURL 的主机上，指向它的长链接一定是回环
+	if config.Server.BaseURL != "" {
+		u, err := url.Parse(config.Server.BaseURL)
+		if err != nil || u.Hostname() == "" {
+			return Config{}, fmt.Errorf("config: invalid SHORTLINK_BASE_URL %q: want an absolute URL such as https://sho.rt", config.Server.BaseURL)
+		}
+		if !slices.Contains(config.Chain.PublicHosts, u.Hostname()) {
+			config.Chain.PublicHosts = append(config.Chain.PublicHosts, u.Hostname())
+		}
+	}
+	envList("SHORTLINK_KNOWN_SHORTENERS", &config.Chain.KnownShorteners)
+	if err := envBool("SHORTLINK_CHAIN_RESOLVE", &config.Chain.Resolve); err != nil {
+		return Config{}, err
+	}
+	if err := envInt("SHORTLINK_CHAIN_MAX_HOPS", &config.Chain.MaxHops); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration("SHORTLINK_CHAIN_TIMEOUT", &config.Chain.Timeout); err != nil {
+		return Config{}, err
+	}
+	if err := envBool("SHORTLINK_CHAIN_STORE_FINAL", &config.Chain.StoreFinal); err != nil {
+		return Config{}, err
+	}
+	if v := os.Getenv("SHORTLINK_COOKIE_SECRET"); v != "" {
+		config.Access.CookieSecret = v
+	}
+	if err := envDuration("SHORTLINK_UNLOCK_TTL", &config.Access.UnlockTTL); err != nil {
+		return Config{}, err
+	}
+	if err := envInt("SHORTLINK_PASSWORD_MAX_ATTEMPTS", &config.Access.PasswordAttempts); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration("SHORTLINK_PASSWORD_WINDOW", &config.Access.PasswordWindow); err != nil {
+		return Config{}, err
+	}
+	if v := os.Getenv("SHORTLINK_EXHAUSTED_FALLBACK_URL"); v != "" {
+		config.Access.ExhaustedFallbackURL = v
+	}
+	if v := os.Getenv("SHORTLINK_COUNTRY_HEADER"); v != "" {
+		config.Targeting.CountryHeader = v
+	}
+	if v := os.Getenv("SHORTLINK_TARGETING_TIMEZONE"); v != "" {
+		loc, err := time.LoadLocation(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("config: invalid SHORTLINK_TARGETING_TIMEZONE: %w", err)
+		}
+		config.Targeting.Location = loc
+	}
+	if v := os.Getenv("SHORTLINK_TAGGING_FILE"); v != "" {
+		config.Tagging.File = v
+	}
+	if err := envBool("SHORTLINK_METADATA_FETCH", &config.Metadata.Fetch); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration("SHORTLINK_METADATA_TIMEOUT", &config.Metadata.Timeout); err != nil {
+		return Config{}, err
+	}
+	maxBytes := int(config.Metadata.MaxBytes)
+	if err := envInt("SHORTLINK_METADATA_MAX_BYTES", &maxBytes); err != nil {
+		return Config{}, err
+	}
+	config.Metadata.MaxBytes = int64(maxBytes)
+	if err := envInt("SHORTLINK_METADATA_CONCURRENCY", &config.Metadata.Concurrency); err != nil {
+		return Config{}, err
+	}
+	if err := envBool("SHORTLINK_HEALTHCHECK", &config.Health.Check); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration("SHORTLINK_HEALTHCHECK_INTERVAL", &config.Health.Interval); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration("SHORTLINK_HEALTHCHECK_RETRY_DELAY", &config.Health.RetryDelay); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration("SHORTLINK_HEALTHCHECK_MAX_BACKOFF", &config.Health.MaxBackoff); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration("SHORTLINK_HEALTHCHECK_TIMEOUT", &config.Health.Timeout); err != nil {
+		return Config{}, err
+	}
+	if err := envInt("SHORTLINK_HEALTHCHECK_FAILURES", &config.Health.FailureThreshold); err != nil {
+		return Config{}, err
+	}
+	if err := envInt("SHORTLINK_HEALTHCHECK_PER_HOST", &config.Health.PerHost); err != nil {
+		return Config{}, err
+	}
+	if err := envBool("SHORTLINK_BROKEN_LINK_INTERSTITIAL", &config.Health.Interstitial); err != nil {
+		return Config{}, err
+	}
+	if err := envInt("SHORTLINK_BATCH_MAX_ITEMS", &config.Batch.MaxItems); err != nil {
+		return Config{}, err
+	}
+	if err := envInt("SHORTLINK_BATCH_CONCURRENCY", &config.Batch.Concurrency); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration("SHORTLINK_IDEMPOTENCY_TTL", &config.IdempotencyTTL); err != nil {
+		return Config{}, err
+	}
+	if err := envInt("SHORTLINK_REDIRECT_TYPE", &config.Redirect.Type); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration("SHORTLINK_PERMANENT_REDIRECT_MAX_AGE", &config.Redirect.PermanentMaxAge); err != nil {
+		return Config{}, err
+	}
+
+	switch config.Redirect.Type {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return Config{}, fmt.Errorf("config: SHORTLINK_REDIRECT_TYPE must be 301, 302, 307 or 308, got %d", config.Redirect.Type)
+	}
+	if config.IDGen.Mode != idgen.ModeRandom && config.IDGen.Mode != idgen.ModeDeterministic {
+		return Config{}, fmt.Errorf("config: unknown idgen mode %q", config.IDGen.Mode)
+	}
+	if config.IDGen.Mode == idgen.ModeDeterministic && config.IDGen.Key == "" {
+		return Config{}, fmt.Errorf("config: SHORTLINK_IDGEN_KEY is required in deterministic mode")
 	}
 	return config, nil
 }
+
+func envInt(key string, dst *int) error {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("config: invalid %s: %w", key, err)
+	}
+	*dst = n
+	return nil
+}
+
+func envDuration(key string, dst *time.Duration) error {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("config: invalid %s: %w", key, err)
+	}
+	*dst = d
+	return nil
+}
+
+func envBool(key string, dst *bool) error {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("config: invalid %s: %w", key, err)
+	}
+	*dst = b
+	return nil
+}
+
+// parsePrefix 解析 CIDR，单个 IP 视为只包含它自己的网段
+func parsePrefix(s string) (netip.Prefix, error) {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return prefix.Masked(), nil
+}
+
+// envList 读取逗号分隔的列表，变量存在时(即使为空)整体替换默认值
+func envList(key string, dst *[]string) {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return
+	}
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*dst = items
+}
@@ -0,0 +1,232 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/netip"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"shortlink/internal/storage"
+)
+
+const (
+	defaultInterval         = time.Hour
+	defaultRetryDelay       = time.Minute
+	defaultMaxBackoff       = 24 * time.Hour
+	defaultTimeout          = 10 * time.Second
+	defaultFailureThreshold = 3
+	defaultPerHost          = 2
+	defaultConcurrency      = 16
+	// maxScanInterval 扫描到期链接的最大间隔，Interval 更短时按 Interval 扫描
+	maxScanInterval = time.Minute
+)
+
+// ErrStoreRequired 表示创建 Checker 时没有提供存储
+var ErrStoreRequired = errors.New("healthcheck: storage is required")
+
+var errDestinationChanged = errors.New("healthcheck: destination changed")
+
+type Config struct {
+	Store  storage.Storer
+	Logger *log.Logger
+	// Interval 健康链接两次检查之间的间隔
+	Interval time.Duration
+	// RetryDelay 第一次失败后的重试间隔，之后每次失败翻倍，最长 MaxBackoff
+	RetryDelay time.Duration
+	MaxBackoff time.Duration
+	// Timeout 单次探测(含跳转)的超时时间
+	Timeout time.Duration
+	// FailureThreshold 连续失败多少次后标记为失效
+	FailureThreshold int
+	// PerHost 同一主机同时进行的探测数
+	PerHost int
+	// Concurrency 所有主机合计同时进行的探测数
+	Concurrency int
+	// AllowNets 例外放行的内网网段，默认不探测内网与保留地址，仅用于测试或内部部署
+	AllowNets []netip.Prefix
+	// Now 返回当前时间，为空时使用 time.Now
+	Now func() time.Time
+}
+
+// Checker 定期探测链接的目的地址，记录状态码、延迟与最近一次成功的时间，
+// 连续失败达到阈值的链接被标记为失效
+type Checker struct {
+	store            storage.Storer
+	logger           *log.Logger
+	prober           *prober
+	interval         time.Duration
+	retryDelay       time.Duration
+	maxBackoff       time.Duration
+	failureThreshold int
+	perHost          int
+	concurrency      int
+	now              func() time.Time
+}
+
+// New 按配置创建 Checker，未配置的参数使用默认值；Store 为空时返回 ErrStoreRequired
+func New(cfg Config) (*Checker, error) {
+	if cfg.Store == nil {
+		return nil, ErrStoreRequired
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = log.New(os.Stdout, "[healthcheck] ", log.LstdFlags|log.Lshortfile)
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = defaultRetryDelay
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = defaultFailureThreshold
+	}
+	if cfg.PerHost <= 0 {
+		cfg.PerHost = defaultPerHost
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = defaultConcurrency
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &Checker{
+		store:            cfg.Store,
+		logger:           cfg.Logger,
+		prober:           newProber(cfg.Timeout, cfg.AllowNets),
+		interval:         cfg.Interval,
+		retryDelay:       cfg.RetryDelay,
+		maxBackoff:       cfg.MaxBackoff,
+		failureThreshold: cfg.FailureThreshold,
+		perHost:          cfg.PerHost,
+		concurrency:      cfg.Concurrency,
+		now:              cfg.Now,
+	}, nil
+}
+
+// Run 立即检查一轮，之后定期检查到期的链接，直到 ctx 被取消
+func (c *Checker) Run(ctx context.Context) {
+	scan := min(c.interval, maxScanInterval)
+	ticker := time.NewTicker(scan)
+	defer ticker.Stop()
+	for {
+		c.CheckDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckDue 探测所有到期的链接并保存结果，返回本轮探测的链接数
+// 每个链接先占用所属主机的名额再占用全局名额，避免等待同一主机的探测占满全局并发
+func (c *Checker) CheckDue(ctx context.Context) int {
+	links, err := c.store.List(ctx)
+	if err != nil {
+		c.logger.Printf("ERROR: Failed to list links for health check: %v\n", err)
+		return 0
+	}
+	now := c.now()
+	global := make(chan struct{}, c.concurrency)
+	hosts := map[string]chan struct{}{}
+	var wg sync.WaitGroup
+	checked := 0
+	for i := range links {
+		link := &links[i]
+		if !c.due(link, now) {
+			continue
+		}
+		u, err := url.Parse(link.LongURL)
+		if err != nil {
+			continue
+		}
+		host, ok := hosts[u.Host]
+		if !ok {
+			host = make(chan struct{}, c.perHost)
+			hosts[u.Host] = host
+		}
+		checked++
+		wg.Add(1)
+		go func(shortCode, longURL string) {
+			defer wg.Done()
+			host <- struct{}{}
+			defer func() { <-host }()
+			global <- struct{}{}
+			defer func() { <-global }()
+			c.record(ctx, shortCode, longURL, c.prober.probe(ctx, longURL))
+		}(link.ShortCode, link.LongURL)
+	}
+	wg.Wait()
+	return checked
+}
+
+// due 判断链接是否需要检查：模板链接的目的地在跳转时才确定，已过期的链接不再被访问，两者都跳过
+func (c *Checker) due(link *storage.Link, now time.Time) bool {
+	if link.Template || (!link.NotAfter.IsZero() && !now.Before(link.NotAfter)) {
+		return false
+	}
+	return link.Health == nil || !now.Before(link.Health.NextCheckAt)
+}
+
+// record 保存一次探测结果；探测期间目的地被修改时丢弃结果
+func (c *Checker) record(ctx context.Context, shortCode, longURL string, res result) {
+	now := c.now().UTC()
+	var before storage.Health
+	link, err := c.store.Update(ctx, shortCode, func(link *storage.Link) error {
+		if link.LongURL != longURL {
+			return errDestinationChanged
+		}
+		h := storage.Health{}
+		if link.Health != nil {
+			h = *link.Health
+		}
+		before = h
+		h.StatusCode, h.Latency, h.CheckedAt = res.statusCode, res.latency, now
+		if res.ok() {
+			h.LastSuccess, h.Failures, h.Broken, h.Error = now, 0, false, ""
+			h.NextCheckAt = now.Add(c.interval)
+		} else {
+			h.Failures++
+			h.Error = res.describe()
+			h.Broken = h.Failures >= c.failureThreshold
+			h.NextCheckAt = now.Add(c.backoff(h.Failures))
+		}
+		link.Health = &h
+		return nil
+	})
+	switch {
+	case errors.Is(err, errDestinationChanged), errors.Is(err, storage.ErrNotFound):
+		return
+	case err != nil:
+		c.logger.Printf("ERROR: Failed to save health check result. ShortCode: %s, Error: %v\n", shortCode, err)
+		return
+	}
+	h := link.Health
+	switch {
+	case h.Broken && !before.Broken:
+		c.logger.Printf("WARN: Link flagged as broken after %d failures. ShortCode: %s, LongURL: %s, Error: %s\n", h.Failures, shortCode, longURL, h.Error)
+	case !h.Broken && before.Broken:
+		c.logger.Printf("INFO: Broken link recovered. ShortCode: %s, Status: %d\n", shortCode, h.StatusCode)
+	case h.Failures > 0:
+		c.logger.Printf("WARN: Health check failed. ShortCode: %s, Failures: %d, Error: %s, NextCheck: %s\n", shortCode, h.Failures, h.Error, h.NextCheckAt.Format(time.RFC3339))
+	}
+}
+
+// backoff 返回第 failures 次连续失败后的重试间隔：RetryDelay 逐次翻倍，最长 MaxBackoff
+func (c *Checker) backoff(failures int) time.Duration {
+	delay := c.retryDelay
+	for i := 1; i < failures && delay < c.maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, c.maxBackoff)
+}
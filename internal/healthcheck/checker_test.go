@@ -0,0 +1,237 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"shortlink/internal/netguard"
+	"shortlink/internal/storage"
+)
+
+var loopback = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}
+
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestChecker(t *testing.T, store storage.Storer, clk *clock, cfg Config) *Checker {
+	t.Helper()
+	cfg.Store = store
+	cfg.Logger = log.New(io.Discard, "", 0)
+	cfg.Now = clk.Now
+	if cfg.AllowNets == nil {
+		cfg.AllowNets = loopback
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = time.Second
+	}
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return c
+}
+
+func TestNew_RequiresStore(t *testing.T) {
+	if _, err := New(Config{}); !errors.Is(err, ErrStoreRequired) {
+		t.Errorf("New() error = %v, want %v", err, ErrStoreRequired)
+	}
+}
+
+func seed(t *testing.T, store storage.Storer, links ...storage.Link) {
+	t.Helper()
+	for _, link := range links {
+		if err := store.Save(context.Background(), link); err != nil {
+			t.Fatalf("seed data failed: %v", err)
+		}
+	}
+}
+
+func health(t *testing.T, store storage.Storer, code string) *storage.Health {
+	t.Helper()
+	link, err := store.FindByShortCode(context.Background(), code)
+	if err != nil {
+		t.Fatalf("FindByShortCode(%s) error = %v", code, err)
+	}
+	return link.Health
+}
+
+func TestChecker_CheckDue(t *testing.T) {
+	var flakyUp atomic.Bool
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/gone", http.NotFound)
+	mux.HandleFunc("/nohead", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/gone", http.StatusFound)
+	})
+	mux.HandleFunc("/flaky", func(w http.ResponseWriter, r *http.Request) {
+		if !flakyUp.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	store := storage.NewMemoryStore()
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := &clock{now: start}
+	seed(t, store,
+		storage.Link{ShortCode: "ok", LongURL: srv.URL + "/ok"},
+		storage.Link{ShortCode: "gone", LongURL: srv.URL + "/gone"},
+		storage.Link{ShortCode: "nohead", LongURL: srv.URL + "/nohead"},
+		storage.Link{ShortCode: "moved", LongURL: srv.URL + "/moved"},
+		storage.Link{ShortCode: "flaky", LongURL: srv.URL + "/flaky"},
+		storage.Link{ShortCode: "template", LongURL: srv.URL + "/{id}", Template: true},
+		storage.Link{ShortCode: "expired", LongURL: srv.URL + "/ok", NotAfter: start.Add(-time.Hour)},
+	)
+	c := newTestChecker(t, store, clk, Config{Interval: time.Hour, RetryDelay: time.Minute, MaxBackoff: 10 * time.Minute, FailureThreshold: 3})
+
+	if got := c.CheckDue(context.Background()); got != 5 {
+		t.Fatalf("CheckDue() checked %d links, want 5", got)
+	}
+	tests := []struct {
+		code       string
+		wantStatus int
+		wantFailed bool
+	}{
+		{code: "ok", wantStatus: http.StatusOK},
+		{code: "nohead", wantStatus: http.StatusOK},
+		{code: "gone", wantStatus: http.StatusNotFound, wantFailed: true},
+		{code: "moved", wantStatus: http.StatusNotFound, wantFailed: true},
+		{code: "flaky", wantStatus: http.StatusBadGateway, wantFailed: true},
+	}
+	for _, tt := range tests {
+		h := health(t, store, tt.code)
+		if h == nil || h.StatusCode != tt.wantStatus || h.CheckedAt != start {
+			t.Errorf("%s: Health = %+v, want status %d checked at %s", tt.code, h, tt.wantStatus, start)
+			continue
+		}
+		if tt.wantFailed {
+			if h.Failures != 1 || h.Broken || !h.LastSuccess.IsZero() || h.NextCheckAt != start.Add(time.Minute) || h.Error == "" {
+				t.Errorf("%s: Health after first failure = %+v", tt.code, h)
+			}
+		} else if h.Failures != 0 || h.LastSuccess != start || h.NextCheckAt != start.Add(time.Hour) {
+			t.Errorf("%s: Health after success = %+v", tt.code, h)
+		}
+	}
+	for _, code := range []string{"template", "expired"} {
+		if h := health(t, store, code); h != nil {
+			t.Errorf("%s: checked, Health = %+v", code, h)
+		}
+	}
+
+	// 未到期的链接不会被再次检查
+	if got := c.CheckDue(context.Background()); got != 0 {
+		t.Errorf("CheckDue() before retry delay checked %d links, want 0", got)
+	}
+
+	// 连续失败按指数退避重试，达到阈值后标记为失效
+	clk.Advance(time.Minute)
+	if got := c.CheckDue(context.Background()); got != 3 {
+		t.Errorf("second CheckDue() checked %d links, want 3", got)
+	}
+	if h := health(t, store, "flaky"); h.Failures != 2 || h.NextCheckAt != clk.Now().Add(2*time.Minute) {
+		t.Errorf("flaky: Health after second failure = %+v", h)
+	}
+	clk.Advance(2 * time.Minute)
+	c.CheckDue(context.Background())
+	if h := health(t, store, "flaky"); !h.Broken || h.Failures != 3 {
+		t.Errorf("flaky: Health after third failure = %+v, want broken", h)
+	}
+
+	// 恢复后清除失效标记
+	flakyUp.Store(true)
+	clk.Advance(4 * time.Minute)
+	c.CheckDue(context.Background())
+	if h := health(t, store, "flaky"); h.Broken || h.Failures != 0 || h.LastSuccess != clk.Now() {
+		t.Errorf("flaky: Health after recovery = %+v", h)
+	}
+	if h := health(t, store, "gone"); !h.Broken {
+		t.Errorf("gone: Health = %+v, want broken", h)
+	}
+}
+
+func TestChecker_PerHostLimit(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	store := storage.NewMemoryStore()
+	for i := range 8 {
+		seed(t, store, storage.Link{ShortCode: "code" + string(rune('a'+i)), LongURL: srv.URL + "/" + string(rune('a'+i))})
+	}
+	c := newTestChecker(t, store, &clock{now: time.Now()}, Config{PerHost: 2, Concurrency: 8})
+	if got := c.CheckDue(context.Background()); got != 8 {
+		t.Fatalf("CheckDue() checked %d links, want 8", got)
+	}
+	if p := peak.Load(); p > 2 {
+		t.Errorf("peak concurrent probes for one host = %d, want <= 2", p)
+	}
+}
+
+func TestChecker_BlocksPrivateAddresses(t *testing.T) {
+	store := storage.NewMemoryStore()
+	seed(t, store, storage.Link{ShortCode: "internal", LongURL: "http://10.0.0.1/admin"})
+	c := newTestChecker(t, store, &clock{now: time.Now()}, Config{AllowNets: []netip.Prefix{}})
+	c.CheckDue(context.Background())
+	if h := health(t, store, "internal"); h == nil || h.Failures != 1 || !strings.Contains(h.Error, netguard.ErrBlockedAddress.Error()) {
+		t.Errorf("Health = %+v, want a blocked address failure", h)
+	}
+}
+
+func TestChecker_Backoff(t *testing.T) {
+	c := newTestChecker(t, storage.NewMemoryStore(), &clock{}, Config{RetryDelay: time.Minute, MaxBackoff: 10 * time.Minute})
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 1, want: time.Minute},
+		{failures: 2, want: 2 * time.Minute},
+		{failures: 4, want: 8 * time.Minute},
+		{failures: 5, want: 10 * time.Minute},
+		{failures: 100, want: 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := c.backoff(tt.failures); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
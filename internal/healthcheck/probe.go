@@ -0,0 +1,90 @@
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"shortlink/internal/netguard"
+)
+
+const maxRedirects = 5
+
+// result 是一次探测的结果，err 非空表示请求本身失败(此时 statusCode 为 0)
+type result struct {
+	statusCode int
+	latency    time.Duration
+	err        error
+}
+
+func (r result) ok() bool {
+	return r.err == nil && r.statusCode < http.StatusBadRequest
+}
+
+func (r result) describe() string {
+	if r.err != nil {
+		return r.err.Error()
+	}
+	return fmt.Sprintf("status %d", r.statusCode)
+}
+
+// prober 先发送 HEAD，目的地不支持 HEAD 时退回 GET(只读响应头)，跟随跳转并以最终状态码为准
+type prober struct {
+	client  *http.Client
+	timeout time.Duration
+}
+
+func newProber(timeout time.Duration, allow []netip.Prefix) *prober {
+	transport := &http.Transport{
+		Proxy:                  nil,
+		DialContext:            netguard.Dialer(timeout, allow).DialContext,
+		TLSHandshakeTimeout:    timeout,
+		ResponseHeaderTimeout:  timeout,
+		MaxResponseHeaderBytes: 64 << 10,
+		MaxIdleConns:           16,
+		IdleConnTimeout:        30 * time.Second,
+	}
+	return &prober{
+		timeout: timeout,
+		client: &http.Client{
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) > maxRedirects {
+					return fmt.Errorf("healthcheck: more than %d redirects", maxRedirects)
+				}
+				return nil
+			},
+		},
+	}
+}
+
+func (p *prober) probe(ctx context.Context, rawURL string) result {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	start := time.Now()
+	status, err := p.do(ctx, http.MethodHead, rawURL)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = p.do(ctx, http.MethodGet, rawURL)
+	}
+	return result{statusCode: status, latency: time.Since(start), err: err}
+}
+
+func (p *prober) do(ctx context.Context, method, rawURL string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("healthcheck: build request: %w", err)
+	}
+	req.Header.Set("User-Agent", "shortlink-healthcheck/1.0")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	// 只需要状态码，少量读取响应体以便复用连接。状态码已经收到，读取或关闭失败只意味着连接不能复用，
+	// 不影响探测结果，因此忽略这两个错误
+	_, _ = io.CopyN(io.Discard, resp.Body, 4<<10)
+	_ = resp.Body.Close()
+	return resp.StatusCode, nil
+}
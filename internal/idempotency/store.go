@@ -0,0 +1,115 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrKeyReused 同一个 key 被用于内容不同的请求
+var ErrKeyReused = errors.New("idempotency: key reused with a different request")
+
+// Response 是保存下来用于重放的响应
+type Response struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// Store 在内存中按 Idempotency-Key 保存第一次请求的响应，保存期为 ttl
+// 同一个 key 的并发请求中只有第一个被执行，其余的等待它完成后重放其响应
+// 过期的记录在后续调用中被顺带清理，不需要额外的后台 goroutine
+type Store struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]*entry
+	lastSweep time.Time
+}
+
+type entry struct {
+	fingerprint string
+	// done 在第一个请求完成(保存或放弃)时关闭
+	done      chan struct{}
+	resp      *Response
+	expiresAt time.Time
+}
+
+// New 创建 Store，now 为空时使用 time.Now
+func New(ttl time.Duration, now func() time.Time) *Store {
+	if now == nil {
+		now = time.Now
+	}
+	return &Store{
+		ttl:     ttl,
+		now:     now,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Begin 登记一个请求，fingerprint 标识请求内容
+//   - key 已有保存的响应时返回该响应，调用方直接重放
+//   - key 属于内容不同的请求时返回 ErrKeyReused
+//   - 否则返回 finish，调用方执行请求后必须调用 finish：传入响应则保存，传入 nil 则放弃
+//     (key 被释放，等待中的请求中会有一个重新执行)
+//
+// 同一个 key 的请求正在执行时 Begin 会阻塞，直到它完成或 ctx 被取消
+func (s *Store) Begin(ctx context.Context, key, fingerprint string) (*Response, func(*Response), error) {
+	for {
+		s.mu.Lock()
+		now := s.now()
+		s.sweep(now)
+		e, ok := s.entries[key]
+		if ok && e.resp != nil && !now.Before(e.expiresAt) {
+			delete(s.entries, key)
+			ok = false
+		}
+		if !ok {
+			e = &entry{fingerprint: fingerprint, done: make(chan struct{})}
+			s.entries[key] = e
+			s.mu.Unlock()
+			return nil, func(resp *Response) { s.finish(key, e, resp) }, nil
+		}
+		s.mu.Unlock()
+
+		if e.fingerprint != fingerprint {
+			return nil, nil, ErrKeyReused
+		}
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		if e.resp != nil {
+			return e.resp, nil, nil
+		}
+		// 第一个请求放弃了 key，重新登记
+	}
+}
+
+func (s *Store) finish(key string, e *entry, resp *Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if resp == nil {
+		delete(s.entries, key)
+	} else {
+		e.resp = resp
+		e.expiresAt = s.now().Add(s.ttl)
+	}
+	close(e.done)
+}
+
+// sweep 清理已过期的记录，执行中的请求没有过期时间，不会被清理
+func (s *Store) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.ttl {
+		return
+	}
+	for key, e := range s.entries {
+		if e.resp != nil && !now.Before(e.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,123 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStore_Begin(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := New(time.Hour, func() time.Time { return now })
+	ctx := context.Background()
+
+	stored, finish, err := s.Begin(ctx, "k1", "body-a")
+	if err != nil || stored != nil || finish == nil {
+		t.Fatalf("first Begin() = %v, %v, want a new request", stored, err)
+	}
+	finish(&Response{Status: 201, ContentType: "application/json", Body: []byte(`{"short_code":"abc"}`)})
+
+	steps := []struct {
+		name        string
+		advance     time.Duration
+		key         string
+		fingerprint string
+		wantErr     error
+		wantReplay  bool
+	}{
+		{name: "replay", key: "k1", fingerprint: "body-a", wantReplay: true},
+		{name: "different body", key: "k1", fingerprint: "body-b", wantErr: ErrKeyReused},
+		{name: "other key", key: "k2", fingerprint: "body-a"},
+		{name: "still stored before ttl", advance: 59 * time.Minute, key: "k1", fingerprint: "body-a", wantReplay: true},
+		{name: "expired", advance: time.Minute, key: "k1", fingerprint: "body-b"},
+	}
+	for _, st := range steps {
+		now = now.Add(st.advance)
+		stored, finish, err := s.Begin(ctx, st.key, st.fingerprint)
+		if !errors.Is(err, st.wantErr) {
+			t.Fatalf("%s: Begin() error = %v, want %v", st.name, err, st.wantErr)
+		}
+		if st.wantReplay && (stored == nil || stored.Status != 201 || string(stored.Body) != `{"short_code":"abc"}`) {
+			t.Errorf("%s: Begin() = %+v, want the stored response", st.name, stored)
+		}
+		if finish != nil {
+			finish(&Response{Status: 201})
+		}
+	}
+}
+
+func TestStore_Begin_Concurrent(t *testing.T) {
+	s := New(time.Hour, nil)
+	ctx := context.Background()
+	_, finish, err := s.Begin(ctx, "k", "f")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 第一个请求完成前，同一个 key 的请求都在等待
+	var replayed atomic.Int32
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stored, finish, err := s.Begin(ctx, "k", "f")
+			if err != nil || finish != nil {
+				t.Errorf("waiting Begin() = %v, %v, want a replay", stored, err)
+				return
+			}
+			if stored.Status == 201 {
+				replayed.Add(1)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	if replayed.Load() != 0 {
+		t.Fatal("duplicates did not wait for the first request")
+	}
+	finish(&Response{Status: 201})
+	wg.Wait()
+	if replayed.Load() != 8 {
+		t.Errorf("replayed = %d, want 8", replayed.Load())
+	}
+
+	// 等待可以被取消
+	_, _, err = s.Begin(ctx, "slow", "f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := s.Begin(cctx, "slow", "f"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Begin() while pending error = %v, want deadline exceeded", err)
+	}
+}
+
+func TestStore_Begin_Released(t *testing.T) {
+	s := New(time.Hour, nil)
+	ctx := context.Background()
+	_, finish, _ := s.Begin(ctx, "k", "f")
+
+	done := make(chan func(*Response))
+	go func() {
+		_, next, err := s.Begin(ctx, "k", "f")
+		if err != nil {
+			t.Errorf("Begin() error = %v", err)
+		}
+		done <- next
+	}()
+	time.Sleep(10 * time.Millisecond)
+	// 第一个请求失败不保存响应，等待中的请求接手执行
+	finish(nil)
+	next := <-done
+	if next == nil {
+		t.Fatal("waiting request was not allowed to proceed after the key was released")
+	}
+	next(&Response{Status: 201})
+	if stored, _, _ := s.Begin(ctx, "k", "f"); stored == nil || stored.Status != 201 {
+		t.Errorf("Begin() = %+v, want the second response", stored)
+	}
+}
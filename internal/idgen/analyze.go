@@ -0,0 +1,166 @@
+package idgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Base64URLAlphabet 是 SimpleGenerator 与 DeterministicGenerator 输出使用的字符集
+const Base64URLAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
+type AnalyzeOptions struct {
+	// Samples 生成的样本数量
+	Samples int
+	// TargetVolume 计划承载的链接总量，用于估算生日碰撞概率
+	TargetVolume int64
+	// Alphabet 期望的字符集，卡方检验以此为均匀分布的基准，为空时使用 Base64URLAlphabet
+	Alphabet string
+	// Input 返回第 i 个样本的输入，为空时使用 https://example.com/sample/<i>
+	// 确定性生成器需要不同的输入才能产生不同的短码
+	Input func(i int) string
+}
+
+// Report 是一次短码空间分析的结果
+type Report struct {
+	Samples      int     `json:"samples"`
+	Unique       int     `json:"unique"`
+	Collisions   int     `json:"collisions"`
+	CodeLength   int     `json:"code_length"`
+	AlphabetSize int     `json:"alphabet_size"`
+	Keyspace     float64 `json:"keyspace"`
+	// TargetVolume 下至少发生一次碰撞的概率 1 - exp(-n(n-1)/2N)，以及期望碰撞次数 n(n-1)/2N
+	TargetVolume        int64         `json:"target_volume"`
+	CollisionProb       float64       `json:"collision_probability"`
+	ExpectedCollisions  float64       `json:"expected_collisions"`
+	ChiSquare           float64       `json:"chi_square"`
+	DegreesOfFreedom    int           `json:"degrees_of_freedom"`
+	UniformityPValue    float64       `json:"uniformity_p_value"`
+	UnexpectedChars     int           `json:"unexpected_chars"`
+	CharCounts          []CharCount   `json:"char_counts"`
+	Elapsed             time.Duration `json:"elapsed_ns"`
+	ThroughputPerSecond float64       `json:"throughput_per_second"`
+}
+
+type CharCount struct {
+	Char  string `json:"char"`
+	Count int    `json:"count"`
+}
+
+// Analyze 用 gen 生成 opts.Samples 个短码，统计碰撞、字符分布(卡方均匀性检验)与吞吐
+func Analyze(ctx context.Context, gen Generator, opts AnalyzeOptions) (*Report, error) {
+	if gen == nil {
+		return nil, errors.New("idgen: generator is required")
+	}
+	if opts.Samples <= 0 {
+		return nil, errors.New("idgen: samples must be positive")
+	}
+	if opts.Alphabet == "" {
+		opts.Alphabet = Base64URLAlphabet
+	}
+	if opts.Input == nil {
+		opts.Input = func(i int) string { return fmt.Sprintf("https://example.com/sample/%d", i) }
+	}
+
+	seen := make(map[string]struct{}, opts.Samples)
+	charCounts := make(map[rune]int, len(opts.Alphabet))
+	lengths := make(map[int]int)
+	collisions := 0
+	start := time.Now()
+	for i := range opts.Samples {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		code, err := gen.GenerateShortCode(ctx, opts.Input(i))
+		if err != nil {
+			return nil, fmt.Errorf("sample %d: %w", i, err)
+		}
+		if _, ok := seen[code]; ok {
+			collisions++
+		} else {
+			seen[code] = struct{}{}
+		}
+		lengths[len(code)]++
+		for _, c := range code {
+			charCounts[c]++
+		}
+	}
+	elapsed := time.Since(start)
+
+	report := &Report{
+		Samples:      opts.Samples,
+		Unique:       len(seen),
+		Collisions:   collisions,
+		CodeLength:   mostCommon(lengths),
+		AlphabetSize: len([]rune(opts.Alphabet)),
+		TargetVolume: opts.TargetVolume,
+		Elapsed:      elapsed,
+	}
+	if elapsed > 0 {
+		report.ThroughputPerSecond = float64(opts.Samples) / elapsed.Seconds()
+	}
+	report.Keyspace = math.Pow(float64(report.AlphabetSize), float64(report.CodeLength))
+	report.ExpectedCollisions, report.CollisionProb = birthdayBound(float64(opts.TargetVolume), report.Keyspace)
+
+	total := 0
+	for _, c := range opts.Alphabet {
+		total += charCounts[c]
+	}
+	expected := float64(total) / float64(report.AlphabetSize)
+	for _, c := range opts.Alphabet {
+		n := charCounts[c]
+		if expected > 0 {
+			d := float64(n) - expected
+			report.ChiSquare += d * d / expected
+		}
+		report.CharCounts = append(report.CharCounts, CharCount{Char: string(c), Count: n})
+	}
+	for c, n := range charCounts {
+		if !strings.ContainsRune(opts.Alphabet, c) {
+			report.UnexpectedChars += n
+		}
+	}
+	report.DegreesOfFreedom = report.AlphabetSize - 1
+	report.UniformityPValue = chiSquarePValue(report.ChiSquare, report.DegreesOfFreedom)
+	return report, nil
+}
+
+// birthdayBound 估算在大小为 keyspace 的空间中放入 n 个随机短码时的期望碰撞次数与至少一次碰撞的概率
+func birthdayBound(n, keyspace float64) (expected, prob float64) {
+	if n < 2 || keyspace <= 0 {
+		return 0, 0
+	}
+	expected = n * (n - 1) / (2 * keyspace)
+	// -expm1(-x) 在 x 很小时比 1-exp(-x) 精确
+	return expected, -math.Expm1(-expected)
+}
+
+// chiSquarePValue 使用 Wilson–Hilferty 近似计算卡方分布的上尾概率
+// 自由度较大(这里是字符集大小减一)时近似误差远小于抽样误差，足以判断分布是否明显偏离均匀
+func chiSquarePValue(x float64, df int) float64 {
+	if df <= 0 {
+		return 1
+	}
+	k := float64(df)
+	z := (math.Cbrt(x/k) - (1 - 2/(9*k))) / math.Sqrt(2/(9*k))
+	return 0.5 * math.Erfc(z/math.Sqrt2)
+}
+
+func mostCommon(counts map[int]int) int {
+	keys := make([]int, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	best, bestN := 0, -1
+	for _, k := range keys {
+		if counts[k] > bestN {
+			best, bestN = k, counts[k]
+		}
+	}
+	return best
+}
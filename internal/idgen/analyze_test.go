@@ -0,0 +1,110 @@
+package idgen
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name           string
+		codes          []string
+		samples        int
+		alphabet       string
+		wantUnique     int
+		wantCollisions int
+		wantChiSquare  float64
+		wantUnexpected int
+	}{
+		{
+			name:       "all distinct and perfectly uniform",
+			codes:      []string{"ab", "cd", "ba", "dc"},
+			samples:    4,
+			alphabet:   "abcd",
+			wantUnique: 4,
+		},
+		{
+			name:           "repeated codes are collisions",
+			codes:          []string{"ab", "ab", "cd"},
+			samples:        6,
+			alphabet:       "abcd",
+			wantUnique:     2,
+			wantCollisions: 4,
+			// a=4 b=4 c=2 d=2，期望各 3：(1+1+1+1)/3
+			wantChiSquare: 4.0 / 3,
+		},
+		{
+			name:           "chars outside the alphabet are reported",
+			codes:          []string{"a-", "b-"},
+			samples:        2,
+			alphabet:       "ab",
+			wantUnique:     2,
+			wantUnexpected: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Analyze(context.Background(), &sequenceGenerator{codes: tt.codes}, AnalyzeOptions{
+				Samples:  tt.samples,
+				Alphabet: tt.alphabet,
+			})
+			if err != nil {
+				t.Fatalf("Analyze() error = %v", err)
+			}
+			if report.Unique != tt.wantUnique || report.Collisions != tt.wantCollisions {
+				t.Errorf("Analyze() unique/collisions = %d/%d, want %d/%d",
+					report.Unique, report.Collisions, tt.wantUnique, tt.wantCollisions)
+			}
+			if math.Abs(report.ChiSquare-tt.wantChiSquare) > 1e-9 {
+				t.Errorf("Analyze() chi-square = %v, want %v", report.ChiSquare, tt.wantChiSquare)
+			}
+			if report.UnexpectedChars != tt.wantUnexpected {
+				t.Errorf("Analyze() unexpected chars = %d, want %d", report.UnexpectedChars, tt.wantUnexpected)
+			}
+			if report.CodeLength != 2 {
+				t.Errorf("Analyze() code length = %d, want 2", report.CodeLength)
+			}
+		})
+	}
+}
+
+func TestBirthdayBound(t *testing.T) {
+	tests := []struct {
+		name         string
+		n, keyspace  float64
+		wantExpected float64
+		wantProb     float64
+	}{
+		{name: "single item never collides", n: 1, keyspace: 100},
+		// 经典生日问题：23 人 365 天，近似概率约 0.5
+		{name: "birthday paradox", n: 23, keyspace: 365, wantExpected: 23 * 22 / 730.0, wantProb: 0.5000},
+		{name: "64^7 keyspace at one million links", n: 1e6, keyspace: math.Pow(64, 7), wantExpected: 1e6 * (1e6 - 1) / (2 * math.Pow(64, 7)), wantProb: 0.1076},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected, prob := birthdayBound(tt.n, tt.keyspace)
+			if math.Abs(expected-tt.wantExpected) > 1e-9 {
+				t.Errorf("birthdayBound() expected = %v, want %v", expected, tt.wantExpected)
+			}
+			if math.Abs(prob-tt.wantProb) > 1e-3 {
+				t.Errorf("birthdayBound() prob = %v, want %v", prob, tt.wantProb)
+			}
+		})
+	}
+}
+
+func TestAnalyze_SimpleGeneratorIsUniform(t *testing.T) {
+	report, err := Analyze(context.Background(), NewGenerator(), AnalyzeOptions{Samples: 20000})
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	if report.Collisions != 0 {
+		t.Errorf("Analyze() collisions = %d, want 0", report.Collisions)
+	}
+	// 显著性水平取得很低，避免随机输出让测试偶发失败
+	if report.UniformityPValue < 1e-6 {
+		t.Errorf("Analyze() p-value = %v, distribution looks non-uniform", report.UniformityPValue)
+	}
+}
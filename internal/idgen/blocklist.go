@@ -0,0 +1,236 @@
+package idgen
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"shortlink/internal/metrics"
+)
+
+//go:embed blocklist.txt
+var embeddedBlocklist string
+
+const defaultBlockedRetries = 10
+
+// ErrAllCandidatesBlocked 表示在最大重试次数内生成的候选短码全部命中屏蔽词
+var ErrAllCandidatesBlocked = errors.New("idgen: all generated candidates hit the blocklist")
+
+// leetFold 将常见的 leetspeak 替换及易混淆字符归一到同一个字母
+// 屏蔽词与候选短码使用同一套映射，因此 "sh1t"、"5hit"、"SHIT" 会折叠成相同的形式
+var leetFold = strings.NewReplacer(
+	"0", "o",
+	"1", "i",
+	"l", "i",
+	"|", "i",
+	"!", "i",
+	"3", "e",
+	"4", "a",
+	"@", "a",
+	"5", "s",
+	"$", "s",
+	"6", "g",
+	"9", "g",
+	"7", "t",
+	"+", "t",
+	"8", "b",
+	"2", "z",
+	// base64url 字符集中的分隔符会被用来拆开敏感词，例如 "f-u_ck"
+	"-", "",
+	"_", "",
+)
+
+func foldCode(s string) string {
+	return leetFold.Replace(strings.ToLower(s))
+}
+
+// minSubstringLen 是按子串匹配的最短词长(归一化后)，更短的词只在与整个短码相同时命中
+// 3 个字母的词在 7 位随机短码中作为子串出现得太频繁，叠加 leetspeak 折叠会丢弃大量无害的短码
+const minSubstringLen = 4
+
+// Blocklist 是一组不允许出现在短码中的词
+// 构建完成后只读，可在多个 goroutine 间共享
+type Blocklist struct {
+	words []blockedWord
+}
+
+type blockedWord struct {
+	folded   string
+	original string
+}
+
+// NewBlocklist 加载内置词表，并在 extraFile 非空时追加运营方提供的词表文件
+func NewBlocklist(extraFile string) (*Blocklist, error) {
+	words, err := ParseBlocklist(strings.NewReader(embeddedBlocklist))
+	if err != nil {
+		return nil, fmt.Errorf("idgen: parse embedded blocklist: %w", err)
+	}
+	if extraFile != "" {
+		f, err := os.Open(extraFile)
+		if err != nil {
+			return nil, fmt.Errorf("idgen: open blocklist file: %w", err)
+		}
+		defer f.Close()
+		extra, err := ParseBlocklist(f)
+		if err != nil {
+			return nil, fmt.Errorf("idgen: parse blocklist file %s: %w", extraFile, err)
+		}
+		words = append(words, extra...)
+	}
+	return NewBlocklistFromWords(words), nil
+}
+
+// NewBlocklistFromWords 直接使用给定的词构建 Blocklist，不包含内置词表
+// 词按归一化后的长度从长到短、再按字典序排列，同一短码命中多个词时总是报告同一个(最长的)词
+func NewBlocklistFromWords(words []string) *Blocklist {
+	seen := make(map[string]bool, len(words))
+	b := &Blocklist{}
+	for _, w := range words {
+		w = strings.TrimSpace(w)
+		folded := foldCode(w)
+		if folded == "" || seen[folded] {
+			continue
+		}
+		seen[folded] = true
+		b.words = append(b.words, blockedWord{folded: folded, original: w})
+	}
+	slices.SortFunc(b.words, func(x, y blockedWord) int {
+		if n := cmp.Compare(len(y.folded), len(x.folded)); n != 0 {
+			return n
+		}
+		return strings.Compare(x.folded, y.folded)
+	})
+	return b
+}
+
+// ParseBlocklist 按行读取词表，忽略空行和 # 开头的注释
+func ParseBlocklist(r io.Reader) ([]string, error) {
+	var words []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return words, nil
+}
+
+// Match 检查短码是否包含屏蔽词，命中时返回对应的原始词
+// 不足 minSubstringLen 的词只在与整个短码相同时命中
+func (b *Blocklist) Match(code string) (string, bool) {
+	folded := foldCode(code)
+	for _, w := range b.words {
+		if folded == w.folded || len(w.folded) >= minSubstringLen && strings.Contains(folded, w.folded) {
+			return w.original, true
+		}
+	}
+	return "", false
+}
+
+// Len 返回词表中的词数量
+func (b *Blocklist) Len() int {
+	return len(b.words)
+}
+
+type BlocklistConfig struct {
+	// Generator 实际产生候选短码的生成器
+	Generator Generator
+	Blocklist *Blocklist
+	// MaxAttempts 单次调用最多尝试的候选数量
+	MaxAttempts int
+	// Blocked 每拦截一个候选短码加一，可为 nil
+	Blocked *metrics.Counter
+	Logger  *log.Logger
+}
+
+// BlocklistGenerator 包装另一个 Generator，透明地丢弃命中屏蔽词的候选短码并重新生成
+type BlocklistGenerator struct {
+	next        Generator
+	blocklist   *Blocklist
+	maxAttempts int
+	blocked     *metrics.Counter
+	logger      *log.Logger
+}
+
+func NewBlocklistGenerator(cfg BlocklistConfig) (*BlocklistGenerator, error) {
+	if cfg.Generator == nil || cfg.Blocklist == nil {
+		return nil, errors.New("idgen: generator and blocklist are required")
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultBlockedRetries
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = log.New(os.Stdout, "[idgen] ", log.LstdFlags|log.Lshortfile)
+	}
+	return &BlocklistGenerator{
+		next:        cfg.Generator,
+		blocklist:   cfg.Blocklist,
+		maxAttempts: cfg.MaxAttempts,
+		blocked:     cfg.Blocked,
+		logger:      cfg.Logger,
+	}, nil
+}
+
+func (g *BlocklistGenerator) GenerateShortCode(ctx context.Context, input string) (string, error) {
+	if _, ok := g.next.(SaltedGenerator); ok {
+		return g.GenerateSaltedShortCode(ctx, input, 0)
+	}
+	for i := range g.maxAttempts {
+		code, err := g.next.GenerateShortCode(ctx, input)
+		if err != nil {
+			return "", err
+		}
+		if _, blocked := g.blocklist.Match(code); !blocked {
+			return code, nil
+		}
+		g.blocked.Inc()
+		g.logger.Printf("WARN: Generated short code hit blocklist, regenerating. Code: %s, Attempt: %d\n", code, i+1)
+	}
+	return "", fmt.Errorf("after %d attempts: %w", g.maxAttempts, ErrAllCandidatesBlocked)
+}
+
+// GenerateSaltedShortCode 返回底层生成器序列中第 salt 个未命中屏蔽词的候选短码
+// 跳过被屏蔽的候选后序列依旧是确定的，离线预计算只要使用相同的词表就能得到相同结果
+// 底层生成器不支持盐值时退化为 GenerateShortCode
+func (g *BlocklistGenerator) GenerateSaltedShortCode(ctx context.Context, input string, salt int) (string, error) {
+	salted, ok := g.next.(SaltedGenerator)
+	if !ok {
+		return g.GenerateShortCode(ctx, input)
+	}
+	accepted, blocked := 0, 0
+	for raw := 0; ; raw++ {
+		code, err := salted.GenerateSaltedShortCode(ctx, input, raw)
+		if err != nil {
+			return "", err
+		}
+		if _, hit := g.blocklist.Match(code); !hit {
+			if accepted == salt {
+				return code, nil
+			}
+			accepted++
+			continue
+		}
+		// 更小的 salt 之前已经走过这段序列，只统计本次调用新遇到的拦截，避免重复计数
+		if accepted == salt {
+			g.blocked.Inc()
+			g.logger.Printf("WARN: Generated short code hit blocklist, regenerating. Code: %s, Salt: %d\n", code, raw)
+		}
+		blocked++
+		if blocked >= g.maxAttempts {
+			return "", fmt.Errorf("after %d blocked candidates: %w", blocked, ErrAllCandidatesBlocked)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package idgen
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"shortlink/internal/metrics"
+)
+
+// sequenceGenerator 按顺序返回预设的短码，是一个真实可用的 Generator 实现
+type sequenceGenerator struct {
+	codes []string
+	next  int
+}
+
+func (g *sequenceGenerator) GenerateShortCode(ctx context.Context, input string) (string, error) {
+	code := g.codes[g.next%len(g.codes)]
+	g.next++
+	return code, nil
+}
+
+func TestBlocklist_Match(t *testing.T) {
+	bl := NewBlocklistFromWords([]string{"shit", "acme", "kill", "sex", "shitty"})
+
+	tests := []struct {
+		name     string
+		code     string
+		wantWord string
+		wantHit  bool
+	}{
+		{name: "plain word", code: "xshitx", wantWord: "shit", wantHit: true},
+		{name: "upper case", code: "aSHITb", wantWord: "shit", wantHit: true},
+		{name: "leetspeak digits", code: "5h1t99", wantWord: "shit", wantHit: true},
+		{name: "separator inside word", code: "sh-i_t", wantWord: "shit", wantHit: true},
+		{name: "brand with leetspeak", code: "ZZ4cm3", wantWord: "acme", wantHit: true},
+		{name: "l and 1 are interchangeable", code: "ki1L00", wantWord: "kill", wantHit: true},
+		{name: "several words report the longest", code: "kill5hitty", wantWord: "shitty", wantHit: true},
+		{name: "several words of equal length", code: "ki11shit", wantWord: "kill", wantHit: true},
+		{name: "short word inside code", code: "x5exyab", wantHit: false},
+		{name: "short word as whole code", code: "5EX", wantWord: "sex", wantHit: true},
+		{name: "clean code", code: "Ab3xYz9", wantHit: false},
+		{name: "empty code", code: "", wantHit: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 多次调用结果必须一致，不能依赖 map 的遍历顺序
+			for range 20 {
+				word, hit := bl.Match(tt.code)
+				if hit != tt.wantHit {
+					t.Fatalf("Match(%q) hit = %v, want %v", tt.code, hit, tt.wantHit)
+				}
+				if word != tt.wantWord {
+					t.Fatalf("Match(%q) word = %q, want %q", tt.code, word, tt.wantWord)
+				}
+			}
+		})
+	}
+}
+
+func TestNewBlocklist_ExtraFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "brands.txt")
+	content := "# competitor brands\n\nglobex\n  initech  \n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write blocklist file: %v", err)
+	}
+
+	bl, err := NewBlocklist(path)
+	if err != nil {
+		t.Fatalf("NewBlocklist() error = %v", err)
+	}
+
+	for _, code := range []string{"xGL0BEXx", "1nitech", "fuckab"} {
+		if _, hit := bl.Match(code); !hit {
+			t.Errorf("Match(%q) = false, want true", code)
+		}
+	}
+
+	if _, err := NewBlocklist(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("NewBlocklist() with missing file error = nil, want error")
+	}
+}
+
+func TestBlocklistGenerator_GenerateShortCode(t *testing.T) {
+	tests := []struct {
+		name        string
+		codes       []string
+		maxAttempts int
+		wantCode    string
+		wantBlocked int64
+		wantErr     error
+	}{
+		{
+			name:        "clean candidate is returned directly",
+			codes:       []string{"Ab3xYz9"},
+			maxAttempts: 3,
+			wantCode:    "Ab3xYz9",
+		},
+		{
+			name:        "blocked candidates are regenerated",
+			codes:       []string{"5H1Tabc", "xxPORNx", "Ab3xYz9"},
+			maxAttempts: 3,
+			wantCode:    "Ab3xYz9",
+			wantBlocked: 2,
+		},
+		{
+			name:        "gives up after max attempts",
+			codes:       []string{"5H1Tabc"},
+			maxAttempts: 4,
+			wantBlocked: 4,
+			wantErr:     ErrAllCandidatesBlocked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := metrics.NewRegistry()
+			counter := registry.Counter("idgen_blocked_total")
+			g, err := NewBlocklistGenerator(BlocklistConfig{
+				Generator:   &sequenceGenerator{codes: tt.codes},
+				Blocklist:   NewBlocklistFromWords([]string{"shit", "porn"}),
+				MaxAttempts: tt.maxAttempts,
+				Blocked:     counter,
+				Logger:      log.New(io.Discard, "", 0),
+			})
+			if err != nil {
+				t.Fatalf("NewBlocklistGenerator() error = %v", err)
+			}
+
+			code, err := g.GenerateShortCode(context.Background(), "https://example.com")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GenerateShortCode() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+			if code != tt.wantCode {
+				t.Errorf("GenerateShortCode() = %q, want %q", code, tt.wantCode)
+			}
+			if got := counter.Value(); got != tt.wantBlocked {
+				t.Errorf("blocked counter = %d, want %d", got, tt.wantBlocked)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package idgen
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// SaltedGenerator 是可按盐值索引产生一系列确定候选短码的生成器
+// 同一输入、同一 salt 必须总是得到同一个短码；调用方在短码冲突时递增 salt 取下一个候选
+type SaltedGenerator interface {
+	Generator
+	GenerateSaltedShortCode(ctx context.Context, input string, salt int) (string, error)
+}
+
+// DeterministicGenerator 基于内容寻址生成短码：code = base64url(HMAC-SHA256(key, canonical(url) + "\x00" + salt))[:length]
+// 不依赖时间和随机数，多个实例以及离线 CI 在相同 key 下总能为同一个 URL 算出同一个短码
+type DeterministicGenerator struct {
+	key    []byte
+	length int
+}
+
+// NewDeterministicGenerator 创建确定性生成器，key 不能为空，length <= 0 时使用默认长度
+func NewDeterministicGenerator(key []byte, length int) (*DeterministicGenerator, error) {
+	if len(key) == 0 {
+		return nil, errors.New("idgen: deterministic generator requires a non-empty key")
+	}
+	if length <= 0 {
+		length = defaultCodeLength
+	}
+	// SHA-256 输出 32 字节，base64 编码后最多 43 个字符
+	if length > 43 {
+		return nil, fmt.Errorf("idgen: code length %d exceeds hash size", length)
+	}
+	return &DeterministicGenerator{key: key, length: length}, nil
+}
+
+func (g *DeterministicGenerator) GenerateShortCode(ctx context.Context, longURL string) (string, error) {
+	return g.GenerateSaltedShortCode(ctx, longURL, 0)
+}
+
+func (g *DeterministicGenerator) GenerateSaltedShortCode(ctx context.Context, longURL string, salt int) (string, error) {
+	if strings.TrimSpace(longURL) == "" {
+		return "", fmt.Errorf("idgen: longURL cannot be empty for code generation")
+	}
+	if salt < 0 {
+		return "", fmt.Errorf("idgen: salt must not be negative, got %d", salt)
+	}
+	mac := hmac.New(sha256.New, g.key)
+	mac.Write([]byte(CanonicalURL(longURL)))
+	mac.Write([]byte{0})
+	mac.Write([]byte(strconv.Itoa(salt)))
+	encoded := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	return encoded[:g.length], nil
+}
+
+// CanonicalURL 返回用于哈希的 URL 规范形式：小写 scheme 和 host，去掉默认端口与片段，空路径视为 "/"
+// 无法解析的输入按去除首尾空白后的原样返回，保证函数总是确定的
+func CanonicalURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	switch {
+	case port != "":
+		u.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		u.Host = "[" + host + "]"
+	default:
+		u.Host = host
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String()
+}
@@ -0,0 +1,134 @@
+package idgen
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+)
+
+func TestDeterministicGenerator_GenerateSaltedShortCode(t *testing.T) {
+	ctx := context.Background()
+	newGen := func(t *testing.T, key string) *DeterministicGenerator {
+		t.Helper()
+		g, err := NewDeterministicGenerator([]byte(key), 7)
+		if err != nil {
+			t.Fatalf("NewDeterministicGenerator() error = %v", err)
+		}
+		return g
+	}
+
+	tests := []struct {
+		name      string
+		keyA      string
+		urlA      string
+		saltA     int
+		keyB      string
+		urlB      string
+		saltB     int
+		wantEqual bool
+	}{
+		{
+			name: "same input on separate instances", wantEqual: true,
+			keyA: "k1", urlA: "https://example.com/a",
+			keyB: "k1", urlB: "https://example.com/a",
+		},
+		{
+			name: "host case, default port and fragment are normalized", wantEqual: true,
+			keyA: "k1", urlA: "https://Example.COM:443/a#top",
+			keyB: "k1", urlB: "https://example.com/a",
+		},
+		{
+			name: "empty path equals root path", wantEqual: true,
+			keyA: "k1", urlA: "https://example.com",
+			keyB: "k1", urlB: "https://example.com/",
+		},
+		{
+			name: "path is case sensitive", wantEqual: false,
+			keyA: "k1", urlA: "https://example.com/A",
+			keyB: "k1", urlB: "https://example.com/a",
+		},
+		{
+			name: "different keys", wantEqual: false,
+			keyA: "k1", urlA: "https://example.com/a",
+			keyB: "k2", urlB: "https://example.com/a",
+		},
+		{
+			name: "different salts", wantEqual: false,
+			keyA: "k1", urlA: "https://example.com/a", saltA: 0,
+			keyB: "k1", urlB: "https://example.com/a", saltB: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := newGen(t, tt.keyA).GenerateSaltedShortCode(ctx, tt.urlA, tt.saltA)
+			if err != nil {
+				t.Fatalf("GenerateSaltedShortCode(A) error = %v", err)
+			}
+			b, err := newGen(t, tt.keyB).GenerateSaltedShortCode(ctx, tt.urlB, tt.saltB)
+			if err != nil {
+				t.Fatalf("GenerateSaltedShortCode(B) error = %v", err)
+			}
+			if len(a) != 7 {
+				t.Errorf("len(code) = %d, want 7", len(a))
+			}
+			if (a == b) != tt.wantEqual {
+				t.Errorf("codes %q and %q: equal = %v, want %v", a, b, a == b, tt.wantEqual)
+			}
+		})
+	}
+}
+
+func TestDeterministicGenerator_KnownVector(t *testing.T) {
+	// 固定向量：算法或规范化规则的任何变化都会导致已发布的预计算短码失效，必须显式更新此处
+	g, err := NewDeterministicGenerator([]byte("shortlink-test-key"), 7)
+	if err != nil {
+		t.Fatalf("NewDeterministicGenerator() error = %v", err)
+	}
+	code, err := g.GenerateShortCode(context.Background(), "https://example.com/")
+	if err != nil {
+		t.Fatalf("GenerateShortCode() error = %v", err)
+	}
+	const want = "ypmZIyG"
+	if code != want {
+		t.Errorf("GenerateShortCode() = %q, want %q", code, want)
+	}
+}
+
+func TestBlocklistGenerator_SaltedSequenceSkipsBlocked(t *testing.T) {
+	ctx := context.Background()
+	base, err := NewDeterministicGenerator([]byte("k1"), 7)
+	if err != nil {
+		t.Fatalf("NewDeterministicGenerator() error = %v", err)
+	}
+	const url = "https://example.com/a"
+	raw0, err := base.GenerateSaltedShortCode(ctx, url, 0)
+	if err != nil {
+		t.Fatalf("GenerateSaltedShortCode() error = %v", err)
+	}
+	raw1, err := base.GenerateSaltedShortCode(ctx, url, 1)
+	if err != nil {
+		t.Fatalf("GenerateSaltedShortCode() error = %v", err)
+	}
+
+	// 把 salt 0 的候选整体加入词表，包装后的第 0 个候选应顺延为 salt 1 的候选
+	g, err := NewBlocklistGenerator(BlocklistConfig{
+		Generator: base,
+		Blocklist: NewBlocklistFromWords([]string{raw0}),
+		Logger:    log.New(io.Discard, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("NewBlocklistGenerator() error = %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		got, err := g.GenerateShortCode(ctx, url)
+		if err != nil {
+			t.Fatalf("GenerateShortCode() error = %v", err)
+		}
+		if got != raw1 {
+			t.Errorf("GenerateShortCode() call %d = %q, want %q", i, got, raw1)
+		}
+	}
+}
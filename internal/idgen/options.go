@@ -0,0 +1,55 @@
+package idgen
+
+import (
+	"fmt"
+	"log"
+
+	"shortlink/internal/metrics"
+)
+
+const (
+	ModeRandom        = "random"
+	ModeDeterministic = "deterministic"
+)
+
+// Options 描述如何组装服务使用的生成器，服务进程与离线命令行共用，保证两边的短码一致
+type Options struct {
+	Mode string
+	// Key 与 CodeLength 仅在 ModeDeterministic 下使用
+	Key        string
+	CodeLength int
+	// BlocklistFile 追加到内置词表的运营方词表文件，可为空
+	BlocklistFile     string
+	MaxBlockedRetries int
+	Blocked           *metrics.Counter
+	Logger            *log.Logger
+}
+
+// New 按 Options 构建生成器，返回值总是包裹了屏蔽词检查
+func New(opts Options) (Generator, error) {
+	var base Generator
+	switch opts.Mode {
+	case "", ModeRandom:
+		base = NewGenerator()
+	case ModeDeterministic:
+		g, err := NewDeterministicGenerator([]byte(opts.Key), opts.CodeLength)
+		if err != nil {
+			return nil, err
+		}
+		base = g
+	default:
+		return nil, fmt.Errorf("idgen: unknown mode %q", opts.Mode)
+	}
+
+	blocklist, err := NewBlocklist(opts.BlocklistFile)
+	if err != nil {
+		return nil, err
+	}
+	return NewBlocklistGenerator(BlocklistConfig{
+		Generator:   base,
+		Blocklist:   blocklist,
+		MaxAttempts: opts.MaxBlockedRetries,
+		Blocked:     opts.Blocked,
+		Logger:      opts.Logger,
+	})
+}
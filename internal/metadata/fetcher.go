@@ -0,0 +1,128 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"shortlink/internal/netguard"
+
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	defaultTimeout      = 5 * time.Second
+	defaultMaxBytes     = 512 << 10
+	defaultMaxRedirects = 5
+	defaultUserAgent    = "shortlink-preview/1.0"
+)
+
+var (
+	// ErrBlockedAddress 目的地址属于内网或保留网段
+	ErrBlockedAddress = netguard.ErrBlockedAddress
+	ErrNotHTML        = errors.New("metadata: destination is not an HTML page")
+)
+
+// Options 控制抓取行为，零值字段使用默认值
+type Options struct {
+	// Timeout 单次抓取(含跳转与读取响应体)的总时长
+	Timeout time.Duration
+	// MaxBytes 最多读取的响应体字节数，超出部分直接丢弃
+	MaxBytes int64
+	// MaxRedirects 最多跟随的跳转次数
+	MaxRedirects int
+	UserAgent    string
+	// AllowNets 例外放行的网段，默认拒绝所有内网与保留地址，仅用于测试或内部部署
+	AllowNets []netip.Prefix
+}
+
+// Fetcher 抓取目的页面并提取预览信息，可并发使用
+// 连接建立前按实际解析出的 IP 检查地址，因此 DNS 重绑定与跳转到内网地址同样会被拦截
+type Fetcher struct {
+	client    *http.Client
+	timeout   time.Duration
+	maxBytes  int64
+	userAgent string
+}
+
+func NewFetcher(opts Options) *Fetcher {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = defaultMaxBytes
+	}
+	if opts.MaxRedirects <= 0 {
+		opts.MaxRedirects = defaultMaxRedirects
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = defaultUserAgent
+	}
+	f := &Fetcher{timeout: opts.Timeout, maxBytes: opts.MaxBytes, userAgent: opts.UserAgent}
+	dialer := netguard.Dialer(opts.Timeout, opts.AllowNets)
+	transport := &http.Transport{
+		// 不使用环境变量中的代理，否则地址检查只能看到代理本身
+		Proxy:                  nil,
+		DialContext:            dialer.DialContext,
+		TLSHandshakeTimeout:    opts.Timeout,
+		ResponseHeaderTimeout:  opts.Timeout,
+		MaxResponseHeaderBytes: 64 << 10,
+		MaxIdleConns:           16,
+		IdleConnTimeout:        30 * time.Second,
+	}
+	f.client = &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > opts.MaxRedirects {
+				return fmt.Errorf("metadata: more than %d redirects", opts.MaxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("metadata: redirect to unsupported scheme %q", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+	return f
+}
+
+// Fetch 请求 rawURL 并从 HTML 的 <head> 中提取标题、描述、图标与 og:image
+// 相对地址以跳转后的最终地址为基准解析；非 HTML 响应返回 ErrNotHTML
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (Page, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return Page{}, fmt.Errorf("metadata: build request: %w", err)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return Page{}, fmt.Errorf("metadata: unsupported scheme %q", req.URL.Scheme)
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.1")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return Page{}, fmt.Errorf("metadata: fetch %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return Page{}, fmt.Errorf("metadata: fetch %s: status %d", rawURL, resp.StatusCode)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return Page{}, fmt.Errorf("%w: content type %q", ErrNotHTML, contentType)
+	}
+
+	body := io.LimitReader(resp.Body, f.maxBytes)
+	r, err := charset.NewReader(body, contentType)
+	if err != nil {
+		// 无法识别的编码按 UTF-8 处理
+		r = body
+	}
+	return parse(r, resp.Request.URL), nil
+}
@@ -0,0 +1,116 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loopback 放行 httptest 服务器所在的回环地址，其余内网地址仍然被拦截
+var loopback = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128")}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	page := func(contentType, body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.Write([]byte(body))
+		}
+	}
+	mux.HandleFunc("/full", page("text/html; charset=utf-8", `<!DOCTYPE html><html><head>
+<title>  Plain
+  title </title>
+<meta name="description" content="Plain description">
+<meta property="og:title" content="OG title">
+<meta property="og:image" content="/img/cover.png">
+<link rel="shortcut icon" href="static/icon.ico">
+</head><body><meta property="og:description" content="ignored after body"></body></html>`))
+	mux.HandleFunc("/minimal", page("text/html", `<html><head><title>Only a title</title>
+<meta name="twitter:image" content="javascript:alert(1)"></head></html>`))
+	mux.HandleFunc("/latin1", page("text/html; charset=iso-8859-1", "<title>Caf\xe9</title>"))
+	mux.HandleFunc("/pdf", page("application/pdf", "%PDF-1.7"))
+	mux.HandleFunc("/missing", http.NotFound)
+	mux.HandleFunc("/huge", page("text/html", "<html><head>"+strings.Repeat("<!-- padding -->", 4096)+"<title>Too late</title></head></html>"))
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/docs/page", http.StatusFound)
+	})
+	mux.HandleFunc("/docs/page", page("text/html", `<head><title>Moved</title><link rel="icon" href="icon.svg"></head>`))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFetcher_Fetch(t *testing.T) {
+	srv := newTestServer(t)
+	f := NewFetcher(Options{Timeout: 500 * time.Millisecond, MaxBytes: 16 << 10, AllowNets: loopback})
+
+	tests := []struct {
+		name    string
+		path    string
+		want    Page
+		wantErr error
+	}{
+		{name: "open graph wins", path: "/full", want: Page{Title: "OG title", Description: "Plain description", Favicon: srv.URL + "/static/icon.ico", Image: srv.URL + "/img/cover.png"}},
+		{name: "defaults and unsafe image", path: "/minimal", want: Page{Title: "Only a title", Favicon: srv.URL + "/favicon.ico"}},
+		{name: "charset decoded", path: "/latin1", want: Page{Title: "Café", Favicon: srv.URL + "/favicon.ico"}},
+		{name: "relative to final URL", path: "/moved", want: Page{Title: "Moved", Favicon: srv.URL + "/docs/icon.svg"}},
+		{name: "size cap", path: "/huge", want: Page{Favicon: srv.URL + "/favicon.ico"}},
+		{name: "not html", path: "/pdf", wantErr: ErrNotHTML},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Fetch(context.Background(), srv.URL+tt.path)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Fetch() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Fetch() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetcher_Fetch_Failures(t *testing.T) {
+	srv := newTestServer(t)
+	allowed := NewFetcher(Options{Timeout: 200 * time.Millisecond, AllowNets: loopback})
+	guarded := NewFetcher(Options{Timeout: 200 * time.Millisecond})
+
+	tests := []struct {
+		name    string
+		fetcher *Fetcher
+		url     string
+		wantErr error
+	}{
+		{name: "loopback blocked", fetcher: guarded, url: srv.URL + "/full", wantErr: ErrBlockedAddress},
+		{name: "localhost name blocked", fetcher: guarded, url: strings.Replace(srv.URL, "127.0.0.1", "localhost", 1) + "/full", wantErr: ErrBlockedAddress},
+		{name: "private literal blocked", fetcher: guarded, url: "http://10.0.0.1/", wantErr: ErrBlockedAddress},
+		{name: "http error status", fetcher: allowed, url: srv.URL + "/missing"},
+		{name: "timeout", fetcher: allowed, url: srv.URL + "/slow", wantErr: context.DeadlineExceeded},
+		{name: "unsupported scheme", fetcher: allowed, url: "ftp://example.com/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.fetcher.Fetch(context.Background(), tt.url)
+			if err == nil {
+				t.Fatal("Fetch() error = nil, want an error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Fetch() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package metadata
+
+import (
+	"io"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	maxTitleLen       = 300
+	maxDescriptionLen = 1000
+	maxURLLen         = 2048
+)
+
+// Page 是从目的页面提取的预览信息，字段缺失时为空字符串
+type Page struct {
+	Title       string
+	Description string
+	// Favicon 页面声明的图标，未声明时为站点根目录的 /favicon.ico
+	Favicon string
+	// Image og:image(或 twitter:image)的绝对地址
+	Image string
+}
+
+// parse 用 html.Tokenizer 扫描 <head>，遇到 <body> 或 </head> 即停止
+// Open Graph 的标题与描述优先于 <title> 与 <meta name="description">
+func parse(r io.Reader, base *url.URL) Page {
+	var title, ogTitle, description, ogDescription, image, twitterImage, icon, touchIcon string
+	z := html.NewTokenizer(r)
+	inTitle := false
+scan:
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			break scan
+		case html.TextToken:
+			if inTitle && title == "" {
+				title = string(z.Text())
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				break scan
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "body":
+				break scan
+			case "title":
+				inTitle = true
+			case "meta":
+				attrs := attributes(z, hasAttr)
+				content := attrs["content"]
+				switch strings.ToLower(firstNonEmpty(attrs["property"], attrs["name"])) {
+				case "og:title":
+					ogTitle = firstNonEmpty(ogTitle, content)
+				case "description":
+					description = firstNonEmpty(description, content)
+				case "og:description":
+					ogDescription = firstNonEmpty(ogDescription, content)
+				case "og:image", "og:image:url", "og:image:secure_url":
+					image = firstNonEmpty(image, content)
+				case "twitter:image":
+					twitterImage = firstNonEmpty(twitterImage, content)
+				}
+			case "link":
+				attrs := attributes(z, hasAttr)
+				for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+					switch rel {
+					case "icon":
+						icon = firstNonEmpty(icon, attrs["href"])
+					case "apple-touch-icon":
+						touchIcon = firstNonEmpty(touchIcon, attrs["href"])
+					}
+				}
+			}
+		}
+	}
+
+	page := Page{
+		Title:       clean(firstNonEmpty(ogTitle, title), maxTitleLen),
+		Description: clean(firstNonEmpty(ogDescription, description), maxDescriptionLen),
+		Image:       absolute(base, firstNonEmpty(image, twitterImage)),
+		Favicon:     absolute(base, firstNonEmpty(icon, touchIcon)),
+	}
+	if page.Favicon == "" {
+		page.Favicon = absolute(base, "/favicon.ico")
+	}
+	return page
+}
+
+func attributes(z *html.Tokenizer, hasAttr bool) map[string]string {
+	attrs := map[string]string{}
+	for hasAttr {
+		var key, val []byte
+		key, val, hasAttr = z.TagAttr()
+		attrs[strings.ToLower(string(key))] = string(val)
+	}
+	return attrs
+}
+
+// absolute 以页面地址为基准解析 ref，只接受 http/https 结果，拒绝 javascript:、data: 等
+func absolute(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || base == nil {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	if s := u.String(); len(s) <= maxURLLen {
+		return s
+	}
+	return ""
+}
+
+// clean 合并空白并按字符截断
+func clean(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:max])) + "…"
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// Counter 是一个并发安全的单调计数器
+// nil Counter 上的所有操作都是空操作，调用方在未接入指标时无需判空
+type Counter struct {
+	v atomic.Int64
+}
+
+// Inc 计数加一
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add 计数增加 n
+func (c *Counter) Add(n int64) {
+	if c == nil {
+		return
+	}
+	c.v.Add(n)
+}
+
+// Value 返回当前计数值
+func (c *Counter) Value() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.v.Load()
+}
+
+// Registry 按名称管理一组计数器，并以 JSON 形式对外暴露
+type Registry struct {
+	mu       sync.RWMutex
+	counters map[string]*Counter
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		counters: make(map[string]*Counter),
+	}
+}
+
+// Counter 返回名为 name 的计数器，不存在时创建
+func (r *Registry) Counter(name string) *Counter {
+	r.mu.RLock()
+	c, ok := r.counters[name]
+	r.mu.RUnlock()
+	if ok {
+		return c
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.counters[name]; ok {
+		return c
+	}
+	c = &Counter{}
+	r.counters[name] = c
+	return c
+}
+
+// Snapshot 返回所有计数器当前值的拷贝
+func (r *Registry) Snapshot() map[string]int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make(map[string]int64, len(r.counters))
+	for name, c := range r.counters {
+		out[name] = c.Value()
+	}
+	return out
+}
+
+// ServeHTTP 以 JSON 对象输出所有计数器
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	body, err := json.Marshal(r.Snapshot())
+	if err != nil {
+		http.Error(w, "Failed to encode metrics", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
@@ -0,0 +1,68 @@
+package netguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedAddress 表示连接目标属于内网或保留地址
+var ErrBlockedAddress = errors.New("netguard: destination address is not allowed")
+
+// blockedNets 除 netip.Addr 自带判断(回环、私有、链路本地、组播等)之外需要拒绝的网段
+var blockedNets = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // 运营商级 NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"), // 基准测试
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64，可能映射到内网 IPv4
+	netip.MustParsePrefix("2002::/16"),    // 6to4，同上
+}
+
+// Blocked 判断地址是否属于内网、回环、链路本地、组播或其他保留网段
+func Blocked(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return true
+	}
+	for _, prefix := range blockedNets {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Dialer 返回只允许连接公网地址的 net.Dialer，allow 中的网段例外放行(仅用于测试或内部部署)
+// 检查发生在 DNS 解析之后、建立连接之前，因此 DNS 重绑定与跳转到内网地址同样会被拦截
+func Dialer(timeout time.Duration, allow []netip.Prefix) *net.Dialer {
+	return &net.Dialer{Timeout: timeout, Control: control(allow)}
+}
+
+func control(allow []netip.Prefix) func(network, address string, _ syscall.RawConn) error {
+	return func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+		}
+		addr, err := netip.ParseAddr(host)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+		}
+		addr = addr.Unmap()
+		for _, prefix := range allow {
+			if prefix.Contains(addr) {
+				return nil
+			}
+		}
+		if Blocked(addr) {
+			return fmt.Errorf("%w: %s", ErrBlockedAddress, addr)
+		}
+		return nil
+	}
+}
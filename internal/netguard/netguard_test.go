@@ -0,0 +1,36 @@
+package netguard
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestBlocked(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "127.0.0.1", want: true},
+		{addr: "10.1.2.3", want: true},
+		{addr: "172.16.0.1", want: true},
+		{addr: "192.168.1.1", want: true},
+		{addr: "169.254.169.254", want: true},
+		{addr: "100.64.0.1", want: true},
+		{addr: "0.0.0.0", want: true},
+		{addr: "::1", want: true},
+		{addr: "fd00::1", want: true},
+		{addr: "fe80::1", want: true},
+		{addr: "::ffff:10.0.0.1", want: true},
+		{addr: "64:ff9b::a00:1", want: true},
+		{addr: "93.184.216.34", want: false},
+		{addr: "2606:2800:220:1::1", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := Blocked(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Errorf("Blocked(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package policy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"path"
+	"strings"
+
+	"shortlink/internal/urlnorm"
+)
+
+// Action 是规则命中后的处理方式
+type Action string
+
+const (
+	Allow Action = "allow"
+	Deny  Action = "deny"
+)
+
+type ruleKind int
+
+const (
+	kindExact ruleKind = iota
+	kindSuffix
+	kindWildcard
+	kindCIDR
+)
+
+// Rule 是策略文件中的一行规则
+type Rule struct {
+	Action  Action
+	Pattern string
+	Line    int
+	kind    ruleKind
+	labels  []string
+	prefix  netip.Prefix
+}
+
+// String 返回可以直接写入日志、定位到策略文件的规则描述
+func (r Rule) String() string {
+	return fmt.Sprintf("%s %s (line %d)", r.Action, r.Pattern, r.Line)
+}
+
+// Decision 是一次策略评估的结果，Rule 为空表示没有规则命中、采用了默认动作
+type Decision struct {
+	Allowed bool
+	Host    string
+	Rule    string
+}
+
+// Policy 是一组按文件顺序评估的目的域名规则，首个命中的规则生效
+// 构建后只读，可并发使用
+type Policy struct {
+	rules         []Rule
+	defaultAction Action
+}
+
+// Parse 解析策略文件，格式为每行一条规则：
+//
+//	# 注释
+//	default deny              # 无规则命中时的动作，缺省为 allow
+//	deny  evil.com            # 精确匹配
+//	deny  .phish.example      # 后缀匹配：域名本身及其所有子域名
+//	allow login-*.corp.com    # 通配符：* 匹配单个标签内的任意字符
+//	deny  10.0.0.0/8          # CIDR：仅作用于 IP 字面量 URL
+//	deny  203.0.113.7         # 单个 IP
+func Parse(r io.Reader) (*Policy, error) {
+	p := &Policy{defaultAction: Allow}
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("policy: line %d: want \"<allow|deny|default> <pattern>\", got %q", lineNo, line)
+		}
+		if fields[0] == "default" {
+			action := Action(strings.ToLower(fields[1]))
+			if action != Allow && action != Deny {
+				return nil, fmt.Errorf("policy: line %d: unknown default action %q", lineNo, fields[1])
+			}
+			p.defaultAction = action
+			continue
+		}
+		rule, err := parseRule(Action(strings.ToLower(fields[0])), fields[1], lineNo)
+		if err != nil {
+			return nil, err
+		}
+		p.rules = append(p.rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("policy: read rules: %w", err)
+	}
+	return p, nil
+}
+
+func parseRule(action Action, pattern string, lineNo int) (Rule, error) {
+	if action != Allow && action != Deny {
+		return Rule{}, fmt.Errorf("policy: line %d: unknown action %q", lineNo, action)
+	}
+	rule := Rule{Action: action, Pattern: pattern, Line: lineNo}
+	pattern = strings.ToLower(pattern)
+
+	if strings.Contains(pattern, "/") {
+		prefix, err := netip.ParsePrefix(pattern)
+		if err != nil {
+			return Rule{}, fmt.Errorf("policy: line %d: invalid CIDR %q: %w", lineNo, pattern, err)
+		}
+		rule.kind, rule.prefix = kindCIDR, prefix.Masked()
+		return rule, nil
+	}
+	if addr, err := netip.ParseAddr(pattern); err == nil {
+		rule.kind, rule.prefix = kindCIDR, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		return rule, nil
+	}
+	switch {
+	case strings.HasPrefix(pattern, "."):
+		if len(pattern) == 1 {
+			return Rule{}, fmt.Errorf("policy: line %d: empty suffix", lineNo)
+		}
+		rule.kind, rule.labels = kindSuffix, []string{pattern[1:]}
+	case strings.Contains(pattern, "*"):
+		rule.kind, rule.labels = kindWildcard, strings.Split(pattern, ".")
+		for _, label := range rule.labels {
+			if _, err := path.Match(label, ""); err != nil {
+				return Rule{}, fmt.Errorf("policy: line %d: invalid wildcard %q: %w", lineNo, pattern, err)
+			}
+		}
+	default:
+		rule.kind, rule.labels = kindExact, []string{pattern}
+	}
+	return rule, nil
+}
+
+// Evaluate 判断主机名(或 IP 字面量)是否允许作为目的地址
+// 整数、十六进制与简写形式的 IPv4 先转换为点分十进制，与浏览器实际访问的地址一致，CIDR 规则才能匹配
+func (p *Policy) Evaluate(host string) Decision {
+	host = strings.ToLower(strings.TrimSuffix(strings.Trim(host, "[]"), "."))
+	if v4, ok, err := urlnorm.ParseIPv4(host); ok && err == nil {
+		host = v4.String()
+	}
+	addr, addrErr := netip.ParseAddr(host)
+	for _, rule := range p.rules {
+		if rule.matches(host, addr, addrErr == nil) {
+			return Decision{Allowed: rule.Action == Allow, Host: host, Rule: rule.String()}
+		}
+	}
+	return Decision{Allowed: p.defaultAction == Allow, Host: host}
+}
+
+func (r Rule) matches(host string, addr netip.Addr, isIP bool) bool {
+	switch r.kind {
+	case kindCIDR:
+		return isIP && r.prefix.Contains(addr.Unmap())
+	case kindExact:
+		return !isIP && host == r.labels[0]
+	case kindSuffix:
+		suffix := r.labels[0]
+		return !isIP && (host == suffix || strings.HasSuffix(host, "."+suffix))
+	case kindWildcard:
+		if isIP {
+			return false
+		}
+		labels := strings.Split(host, ".")
+		if len(labels) != len(r.labels) {
+			return false
+		}
+		for i, pattern := range r.labels {
+			// 模式在 parseRule 中已校验过，path.Match 不会再返回 ErrBadPattern
+			if ok, _ := path.Match(pattern, labels[i]); !ok {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package policy
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRules = `# 例外必须写在更宽泛的拒绝规则之前
+allow safe.phish.example
+deny  .phish.example
+deny  evil.com
+deny  login-*.corp.com
+deny  10.0.0.0/8
+deny  2001:db8::/32
+deny  203.0.113.7
+deny  127.0.0.0/8
+`
+
+func TestPolicy_Evaluate(t *testing.T) {
+	p, err := Parse(strings.NewReader(testRules))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		host        string
+		wantAllowed bool
+		wantRule    string
+	}{
+		{name: "exact match", host: "evil.com", wantAllowed: false, wantRule: "deny evil.com (line 4)"},
+		{name: "exact does not match subdomain", host: "www.evil.com", wantAllowed: true},
+		{name: "exact is case insensitive", host: "EVIL.com.", wantAllowed: false, wantRule: "deny evil.com (line 4)"},
+		{name: "suffix matches apex", host: "phish.example", wantAllowed: false, wantRule: "deny .phish.example (line 3)"},
+		{name: "suffix matches deep subdomain", host: "a.b.phish.example", wantAllowed: false, wantRule: "deny .phish.example (line 3)"},
+		{name: "suffix does not match lookalike", host: "notphish.example", wantAllowed: true},
+		{name: "earlier allow wins", host: "safe.phish.example", wantAllowed: true, wantRule: "allow safe.phish.example (line 2)"},
+		{name: "wildcard single label", host: "login-sso.corp.com", wantAllowed: false, wantRule: "deny login-*.corp.com (line 5)"},
+		{name: "wildcard needs same label count", host: "x.login-sso.corp.com", wantAllowed: true},
+		{name: "cidr v4", host: "10.1.2.3", wantAllowed: false, wantRule: "deny 10.0.0.0/8 (line 6)"},
+		{name: "cidr v6 bracketed", host: "[2001:db8::1]", wantAllowed: false, wantRule: "deny 2001:db8::/32 (line 7)"},
+		{name: "single ip", host: "203.0.113.7", wantAllowed: false, wantRule: "deny 203.0.113.7 (line 8)"},
+		{name: "other ip", host: "203.0.113.8", wantAllowed: true},
+		{name: "domain rules ignore ip", host: "8.8.8.8", wantAllowed: true},
+		{name: "ipv4 as one number", host: "167772161", wantAllowed: false, wantRule: "deny 10.0.0.0/8 (line 6)"},
+		{name: "ipv4 in hex", host: "0x0a000001", wantAllowed: false, wantRule: "deny 10.0.0.0/8 (line 6)"},
+		{name: "ipv4 short form", host: "10.1", wantAllowed: false, wantRule: "deny 10.0.0.0/8 (line 6)"},
+		{name: "ipv4 hex short form", host: "0x7f.1", wantAllowed: false, wantRule: "deny 127.0.0.0/8 (line 9)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := p.Evaluate(tt.host)
+			if got.Allowed != tt.wantAllowed || got.Rule != tt.wantRule {
+				t.Errorf("Evaluate(%q) = {Allowed: %v, Rule: %q}, want {Allowed: %v, Rule: %q}",
+					tt.host, got.Allowed, got.Rule, tt.wantAllowed, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestParse_DefaultDenyAndErrors(t *testing.T) {
+	p, err := Parse(strings.NewReader("default deny\nallow .example.com\n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if d := p.Evaluate("www.example.com"); !d.Allowed {
+		t.Errorf("Evaluate(www.example.com) allowed = false, want true")
+	}
+	if d := p.Evaluate("other.org"); d.Allowed || d.Rule != "" {
+		t.Errorf("Evaluate(other.org) = %+v, want default deny", d)
+	}
+
+	for _, bad := range []string{"block evil.com", "deny", "deny a b", "deny 10.0.0.0/99", "default maybe"} {
+		if _, err := Parse(strings.NewReader(bad)); err == nil {
+			t.Errorf("Parse(%q) error = nil, want error", bad)
+		}
+	}
+}
+
+func TestWatcher_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.txt")
+	write := func(content string, mtime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("write policy: %v", err)
+		}
+		// 显式设置修改时间，避免文件系统时间精度导致变化检测不到
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+	base := time.Now().Add(-time.Hour)
+
+	write("deny evil.com\n", base)
+	w, err := NewWatcher(path, time.Second, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+	if w.Evaluate("evil.com").Allowed {
+		t.Fatal("evil.com allowed after initial load")
+	}
+
+	if changed, err := w.Reload(); err != nil || changed {
+		t.Errorf("Reload() without change = (%v, %v), want (false, nil)", changed, err)
+	}
+
+	write("deny other.com\n", base.Add(time.Minute))
+	if changed, err := w.Reload(); err != nil || !changed {
+		t.Fatalf("Reload() after change = (%v, %v), want (true, nil)", changed, err)
+	}
+	if !w.Evaluate("evil.com").Allowed || w.Evaluate("other.com").Allowed {
+		t.Error("reloaded policy not in effect")
+	}
+
+	write("garbage line here\n", base.Add(2*time.Minute))
+	if _, err := w.Reload(); err == nil {
+		t.Error("Reload() with invalid file error = nil, want error")
+	}
+	if w.Evaluate("other.com").Allowed {
+		t.Error("invalid reload replaced the previous policy")
+	}
+}
@@ -0,0 +1,85 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const defaultReloadInterval = 5 * time.Second
+
+// Watcher 从文件加载策略，并在文件变化时热加载
+// 采用轮询文件修改时间与大小的方式，避免为此引入 fsnotify 依赖
+// 新内容解析失败时保留上一份有效策略，只记录错误
+type Watcher struct {
+	path     string
+	interval time.Duration
+	logger   *log.Logger
+
+	current atomic.Pointer[Policy]
+	modTime time.Time
+	size    int64
+}
+
+// NewWatcher 立即加载一次策略文件，初次加载失败直接返回错误
+func NewWatcher(path string, interval time.Duration, logger *log.Logger) (*Watcher, error) {
+	if interval <= 0 {
+		interval = defaultReloadInterval
+	}
+	if logger == nil {
+		logger = log.New(os.Stdout, "[policy] ", log.LstdFlags|log.Lshortfile)
+	}
+	w := &Watcher{path: path, interval: interval, logger: logger}
+	if _, err := w.Reload(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Evaluate 使用当前生效的策略评估主机名
+func (w *Watcher) Evaluate(host string) Decision {
+	return w.current.Load().Evaluate(host)
+}
+
+// Reload 在文件有变化时重新加载，返回是否发生了替换
+func (w *Watcher) Reload() (bool, error) {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false, fmt.Errorf("policy: stat %s: %w", w.path, err)
+	}
+	if w.current.Load() != nil && info.ModTime().Equal(w.modTime) && info.Size() == w.size {
+		return false, nil
+	}
+	f, err := os.Open(w.path)
+	if err != nil {
+		return false, fmt.Errorf("policy: open %s: %w", w.path, err)
+	}
+	defer f.Close()
+	p, err := Parse(f)
+	if err != nil {
+		return false, fmt.Errorf("policy: load %s: %w", w.path, err)
+	}
+	w.current.Store(p)
+	w.modTime, w.size = info.ModTime(), info.Size()
+	w.logger.Printf("INFO: Loaded destination policy from %s, Rules: %d, Default: %s\n", w.path, len(p.rules), p.defaultAction)
+	return true, nil
+}
+
+// Run 按间隔检查文件变化，直到 ctx 结束
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := w.Reload(); err != nil {
+				w.logger.Printf("ERROR: Failed to reload destination policy, keeping previous rules: %v\n", err)
+			}
+		}
+	}
+}
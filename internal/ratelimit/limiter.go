@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter 是按 key 计数的固定窗口限流器：每个 key 在一个窗口内最多允许 limit 次
+// 过期的窗口在后续调用中被顺带清理，不需要额外的后台 goroutine
+type Limiter struct {
+	limit  int
+	window time.Duration
+	now    func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]*entry
+	lastSweep time.Time
+}
+
+type entry struct {
+	count   int
+	resetAt time.Time
+}
+
+// New 创建限流器，now 为空时使用 time.Now
+func New(limit int, window time.Duration, now func() time.Time) *Limiter {
+	if now == nil {
+		now = time.Now
+	}
+	return &Limiter{
+		limit:   limit,
+		window:  window,
+		now:     now,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Allow 记录一次尝试；超过限额时返回 false 以及距离窗口重置的时间
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	e, ok := l.entries[key]
+	if !ok || !now.Before(e.resetAt) {
+		e = &entry{resetAt: now.Add(l.window)}
+		l.entries[key] = e
+	}
+	if e.count >= l.limit {
+		return false, e.resetAt.Sub(now)
+	}
+	e.count++
+	return true, 0
+}
+
+// Reset 清除 key 的计数，例如在验证成功之后
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, key)
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	for key, e := range l.entries {
+		if !now.Before(e.resetAt) {
+			delete(l.entries, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,43 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New(2, time.Minute, func() time.Time { return now })
+
+	steps := []struct {
+		name      string
+		advance   time.Duration
+		key       string
+		reset     bool
+		wantAllow bool
+	}{
+		{name: "first attempt", key: "a", wantAllow: true},
+		{name: "second attempt", key: "a", wantAllow: true},
+		{name: "third attempt is limited", key: "a", wantAllow: false},
+		{name: "other key is independent", key: "b", wantAllow: true},
+		{name: "still limited before window ends", advance: 59 * time.Second, key: "a", wantAllow: false},
+		{name: "new window", advance: time.Second, key: "a", wantAllow: true},
+		{name: "reset clears the count", key: "a", reset: true, wantAllow: true},
+		{name: "count restarts after reset", key: "a", wantAllow: true},
+		{name: "limited again", key: "a", wantAllow: false},
+	}
+
+	for _, st := range steps {
+		now = now.Add(st.advance)
+		if st.reset {
+			l.Reset(st.key)
+		}
+		ok, retryAfter := l.Allow(st.key)
+		if ok != st.wantAllow {
+			t.Fatalf("%s: Allow(%q) = %v, want %v", st.name, st.key, ok, st.wantAllow)
+		}
+		if !ok && retryAfter <= 0 {
+			t.Errorf("%s: retryAfter = %v, want > 0", st.name, retryAfter)
+		}
+	}
+}
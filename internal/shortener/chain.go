@@ -0,0 +1,232 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"shortlink/internal/netguard"
+)
+
+const (
+	defaultMaxHops      = 5
+	defaultChainTimeout = 5 * time.Second
+)
+
+// ChainOptions 控制跳转链解析，零值字段使用默认值
+type ChainOptions struct {
+	// Timeout 每一跳请求的超时
+	Timeout time.Duration
+	// MaxHops 最多跟随的跳数
+	MaxHops int
+	// AllowNets 例外放行的内网网段，默认不连接内网与保留地址，仅用于测试或内部部署
+	AllowNets []netip.Prefix
+}
+
+// ChainResolver 通过逐跳发送 HEAD 请求跟随重定向，得到链路上的每一个 URL
+// 不使用 http.Client 的自动跳转，以便在每一跳上检查回环与跳数。
+// 请求的地址由用户提交，与元数据抓取、健康检查一样只允许连接公网地址，跳转到内网的一跳返回 netguard.ErrBlockedAddress
+type ChainResolver struct {
+	client  *http.Client
+	maxHops int
+}
+
+// NewChainResolver 创建解析器
+func NewChainResolver(opts ChainOptions) *ChainResolver {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultChainTimeout
+	}
+	if opts.MaxHops <= 0 {
+		opts.MaxHops = defaultMaxHops
+	}
+	transport := &http.Transport{
+		// 不使用环境变量中的代理，否则地址检查只能看到代理本身
+		Proxy:                  nil,
+		DialContext:            netguard.Dialer(opts.Timeout, opts.AllowNets).DialContext,
+		TLSHandshakeTimeout:    opts.Timeout,
+		ResponseHeaderTimeout:  opts.Timeout,
+		MaxResponseHeaderBytes: 64 << 10,
+		MaxIdleConns:           16,
+		IdleConnTimeout:        30 * time.Second,
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   opts.Timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return &ChainResolver{client: client, maxHops: opts.MaxHops}
+}
+
+// Resolve 返回从 rawURL 开始的跳转链，第一个元素是 rawURL 本身，最后一个是最终目的地
+// 链路中出现重复 URL 时返回 ErrRedirectLoop，超过跳数上限时返回 ErrRedirectChain
+// visit 在每一跳请求之前调用，返回错误时立即终止(用于识别指回本服务的跳转)
+func (c *ChainResolver) Resolve(ctx context.Context, rawURL string, visit func(*url.URL) error) ([]string, error) {
+	current, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidLongURL, err)
+	}
+	chain := []string{current.String()}
+	seen := map[string]bool{current.String(): true}
+	for hop := 0; ; hop++ {
+		if err := visit(current); err != nil {
+			return chain, err
+		}
+		next, err := c.nextHop(ctx, current)
+		if err != nil {
+			return chain, err
+		}
+		if next == nil {
+			return chain, nil
+		}
+		if hop+1 > c.maxHops {
+			return chain, fmt.Errorf("%w: more than %d hops", ErrRedirectChain, c.maxHops)
+		}
+		if seen[next.String()] {
+			return chain, fmt.Errorf("%w: %s redirects back to %s", ErrRedirectLoop, current, next)
+		}
+		seen[next.String()] = true
+		chain = append(chain, next.String())
+		current = next
+	}
+}
+
+// nextHop 请求 u 并返回 Location 指向的下一跳，非跳转响应返回 nil
+func (c *ChainResolver) nextHop(ctx context.Context, u *url.URL) (*url.URL, error) {
+	resp, err := c.do(ctx, http.MethodHead, u)
+	if err != nil {
+		return nil, err
+	}
+	// 部分服务不支持 HEAD，退回 GET 但不读取响应体
+	if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+		if resp, err = c.do(ctx, http.MethodGet, u); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 {
+		return nil, nil
+	}
+	loc := resp.Header.Get("Location")
+	if loc == "" {
+		return nil, nil
+	}
+	next, err := u.Parse(loc)
+	if err != nil {
+		return nil, fmt.Errorf("shortener: invalid redirect location %q from %s: %w", loc, u, err)
+	}
+	// 只跟随 http(s)，其他协议(file:、javascript: 等)既无法解析也不能作为最终目的地保存
+	if next.Scheme != "http" && next.Scheme != "https" {
+		return nil, fmt.Errorf("%w: %s redirects to unsupported scheme %q", ErrRedirectChain, u, next.Scheme)
+	}
+	next.Fragment, next.RawFragment = "", ""
+	return next, nil
+}
+
+func (c *ChainResolver) do(ctx context.Context, method string, u *url.URL) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("shortener: build %s request for %s: %w", method, u, err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("shortener: %s %s: %w", method, u, err)
+	}
+	// 只关心状态码与 Location，丢弃少量响应体以便连接复用。两者在响应头中已经收到，
+	// 读取或关闭失败只意味着连接不能复用，不影响这一跳的结果，因此忽略这两个错误
+	_, _ = io.CopyN(io.Discard, resp.Body, 4096)
+	_ = resp.Body.Close()
+	return resp, nil
+}
+
+// hostSet 是不区分大小写的主机名集合，matchSubdomains 为 true 时子域名也视为命中
+type hostSet struct {
+	hosts           map[string]bool
+	matchSubdomains bool
+}
+
+func newHostSet(hosts []string, matchSubdomains bool) hostSet {
+	set := hostSet{hosts: make(map[string]bool, len(hosts)), matchSubdomains: matchSubdomains}
+	for _, h := range hosts {
+		h = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(h), "."))
+		if h != "" {
+			set.hosts[h] = true
+		}
+	}
+	return set
+}
+
+func (s hostSet) contains(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if s.hosts[host] {
+		return true
+	}
+	if !s.matchSubdomains {
+		return false
+	}
+	for i := strings.IndexByte(host, '.'); i >= 0; i = strings.IndexByte(host, '.') {
+		host = host[i+1:]
+		if s.hosts[host] {
+			return true
+		}
+	}
+	return false
+}
+
+// checkChain 在创建时识别回环与短链接套娃，返回实际应保存的目的地址
+//   - 指向本服务公开域名的 URL 一律拒绝
+//   - 未启用链路解析时，指向已知短链接服务的 URL 被拒绝，因为无法得知其真实目的地
+//   - 启用链路解析时逐跳跟随，链路中出现本服务域名、重复 URL 或超过跳数都会被拒绝；
+//     storeFinal 为 true 时返回最终目的地，由调用方重新规范化并执行策略检查
+func (s *Service) checkChain(ctx context.Context, longURL string) (string, error) {
+	u, err := url.Parse(longURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidLongURL, err)
+	}
+	if s.publicHosts.contains(u.Hostname()) {
+		return "", fmt.Errorf("%w: %s points at this service", ErrRedirectLoop, u.Host)
+	}
+	isShortener := s.knownShorteners.contains(u.Hostname())
+	if s.chainResolver == nil {
+		if isShortener {
+			return "", fmt.Errorf("%w: %s is a known URL shortener", ErrRedirectChain, u.Hostname())
+		}
+		return longURL, nil
+	}
+
+	chain, err := s.chainResolver.Resolve(ctx, longURL, func(hop *url.URL) error {
+		if s.publicHosts.contains(hop.Hostname()) {
+			return fmt.Errorf("%w: %s redirects to this service", ErrRedirectLoop, longURL)
+		}
+		return nil
+	})
+	if errors.Is(err, ErrRedirectLoop) || errors.Is(err, ErrRedirectChain) {
+		return "", err
+	}
+	// 提交的地址本身是否允许由目的地策略决定；公网地址跳转进内网则是把短链接变成通往内网的开放跳转
+	if errors.Is(err, netguard.ErrBlockedAddress) && len(chain) > 1 {
+		return "", fmt.Errorf("%w: %s redirects to an internal address: %w", ErrRedirectChain, longURL, err)
+	}
+	if err != nil {
+		// 目的站点暂时不可达不应阻止创建普通链接；但短链接服务的真实去向无法确认时只能拒绝
+		if isShortener {
+			return "", fmt.Errorf("%w: cannot resolve %s: %w", ErrRedirectChain, longURL, err)
+		}
+		s.logger.Printf("WARN: Failed to resolve redirect chain, keeping original destination. LongURL: %s, Error: %v\n", longURL, err)
+		return longURL, nil
+	}
+	final := chain[len(chain)-1]
+	if len(chain) > 1 {
+		s.logger.Printf("INFO: Resolved redirect chain. Hops: %d, LongURL: %s, Final: %s\n", len(chain)-1, longURL, final)
+	}
+	if s.storeFinalDestination {
+		return final, nil
+	}
+	return longURL, nil
+}
@@ -0,0 +1,152 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"shortlink/internal/idgen"
+	"shortlink/internal/netguard"
+	"shortlink/internal/storage"
+)
+
+// testServerNet 是 httptest 服务监听的地址，解析器默认拒绝连接回环地址，测试中单独放行
+var testServerNet = []netip.Prefix{netip.MustParsePrefix("127.0.0.1/32")}
+
+// newRedirectServer 启动一个真实的本地 HTTP 服务，模拟各种跳转链
+func newRedirectServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/final", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/hop1", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/hop2", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/hop2", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/final?x=1", http.StatusFound)
+	})
+	mux.HandleFunc("/loop-a", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop-b", http.StatusFound)
+	})
+	mux.HandleFunc("/loop-b", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop-a", http.StatusFound)
+	})
+	mux.HandleFunc("/to-self", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://short.example/abc123", http.StatusFound)
+	})
+	mux.HandleFunc("/no-head", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		http.Redirect(w, r, "/final", http.StatusFound)
+	})
+	mux.HandleFunc("/to-internal", func(w http.ResponseWriter, r *http.Request) {
+		// 127.0.0.2 同样是回环地址，但不在测试放行的网段内
+		_, port, _ := net.SplitHostPort(r.Host)
+		http.Redirect(w, r, "http://127.0.0.2:"+port+"/admin", http.StatusFound)
+	})
+	mux.HandleFunc("/to-file", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+	})
+	mux.HandleFunc("/chain/", func(w http.ResponseWriter, r *http.Request) {
+		n, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/chain/"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/chain/%d", n+1), http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestService_CreateShortLink_RedirectChains(t *testing.T) {
+	srv := newRedirectServer(t)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closedURL := closed.URL
+	closed.Close()
+
+	tests := []struct {
+		name       string
+		longURL    string
+		resolve    bool
+		storeFinal bool
+		shortener  string // 额外视为已知短链接服务的主机
+		wantStored string
+		wantErr    error
+	}{
+		{name: "own public host", longURL: "https://Short.Example/abc123", wantErr: ErrRedirectLoop},
+		{name: "known shortener without resolving", longURL: "https://bit.ly/xyz", wantErr: ErrRedirectChain},
+		{name: "subdomain of known shortener", longURL: "https://www.bit.ly/xyz", wantErr: ErrRedirectChain},
+		{name: "ordinary URL without resolving", longURL: srv.URL + "/hop1", wantStored: srv.URL + "/hop1"},
+		{name: "resolve keeps original", longURL: srv.URL + "/hop1", resolve: true, wantStored: srv.URL + "/hop1"},
+		{name: "resolve stores final destination", longURL: srv.URL + "/hop1", resolve: true, storeFinal: true, wantStored: srv.URL + "/final?x=1"},
+		{name: "HEAD not allowed falls back to GET", longURL: srv.URL + "/no-head", resolve: true, storeFinal: true, wantStored: srv.URL + "/final"},
+		{name: "cycle between hops", longURL: srv.URL + "/loop-a", resolve: true, wantErr: ErrRedirectLoop},
+		{name: "chain ends at this service", longURL: srv.URL + "/to-self", resolve: true, wantErr: ErrRedirectLoop},
+		{name: "too many hops", longURL: srv.URL + "/chain/0", resolve: true, wantErr: ErrRedirectChain},
+		{name: "unreachable ordinary destination is kept", longURL: closedURL + "/page", resolve: true, storeFinal: true, wantStored: closedURL + "/page"},
+		{name: "hop into internal network", longURL: srv.URL + "/to-internal", resolve: true, storeFinal: true, wantErr: ErrRedirectChain},
+		{name: "hop to non-http scheme", longURL: srv.URL + "/to-file", resolve: true, storeFinal: true, wantErr: ErrRedirectChain},
+		{name: "unreachable shortener is rejected", longURL: closedURL + "/page", resolve: true, shortener: "127.0.0.1", wantErr: ErrRedirectChain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := storage.NewMemoryStore()
+			cfg := Config{
+				Store:                 store,
+				Generator:             idgen.NewGenerator(),
+				Logger:                log.New(io.Discard, "", 0),
+				PublicHosts:           []string{"short.example"},
+				KnownShorteners:       []string{"bit.ly"},
+				StoreFinalDestination: tt.storeFinal,
+			}
+			if tt.shortener != "" {
+				cfg.KnownShorteners = append(cfg.KnownShorteners, tt.shortener)
+			}
+			if tt.resolve {
+				cfg.ChainResolver = NewChainResolver(ChainOptions{MaxHops: 3, AllowNets: testServerNet})
+			}
+			svc := NewService(cfg)
+
+			code, err := svc.CreateShortLink(ctx, tt.longURL)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateShortLink(%q) error = %v, wantErr = %v", tt.longURL, err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			link, err := store.FindByShortCode(ctx, code)
+			if err != nil {
+				t.Fatalf("FindByShortCode(%q) error = %v", code, err)
+			}
+			if link.LongURL != tt.wantStored {
+				t.Errorf("stored LongURL = %q, want %q", link.LongURL, tt.wantStored)
+			}
+		})
+	}
+}
+
+// TestChainResolver_RefusesInternalAddresses 默认配置的解析器不连接回环等内网地址
+func TestChainResolver_RefusesInternalAddresses(t *testing.T) {
+	srv := newRedirectServer(t)
+	_, err := NewChainResolver(ChainOptions{}).Resolve(context.Background(), srv.URL+"/final", func(*url.URL) error { return nil })
+	if !errors.Is(err, netguard.ErrBlockedAddress) {
+		t.Errorf("Resolve(%s) error = %v, want %v", srv.URL, err, netguard.ErrBlockedAddress)
+	}
+}
@@ -0,0 +1,98 @@
+package shortener
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kind 是领域错误的类别，调用方据此决定如何响应(例如 HTTP 状态码)，而不必逐个判断哨兵错误
+type Kind int
+
+const (
+	// KindInternal 未归类的内部错误
+	KindInternal Kind = iota
+	// KindInvalid 请求的参数不合法
+	KindInvalid
+	// KindUnauthorized 需要密码或密码不正确
+	KindUnauthorized
+	// KindNotFound 短链接或其下的资源不存在(含尚未生效的链接)
+	KindNotFound
+	// KindConflict 与现有状态冲突，例如别名已被占用
+	KindConflict
+	// KindGone 短链接曾经可用但已失效：达到访问上限、过期或目的地被策略拒绝
+	KindGone
+	// KindRateLimited 尝试过于频繁
+	KindRateLimited
+	// KindUnavailable 依赖(存储、短码生成)暂时不可用，稍后重试可能成功
+	KindUnavailable
+)
+
+// Error 是 shortener 返回的领域错误：Code 是稳定的机器可读标识，Message 面向人，
+// Retryable 表示原样重试可能成功，Err 是底层原因
+// 包级哨兵错误都是 *Error，errors.Is 按 Code 比较，因此携带不同原因的副本仍然与哨兵相等
+type Error struct {
+	Code      string
+	Kind      Kind
+	Message   string
+	Retryable bool
+	Err       error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return "shortener: " + e.Message + "."
+	}
+	return fmt.Sprintf("shortener: %s: %v", e.Message, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// withCause 返回携带底层原因的副本
+func (e *Error) withCause(err error) *Error {
+	c := *e
+	c.Err = err
+	return &c
+}
+
+// AsError 取出 err 链中最外层的 *Error，没有时把 err 视为 KindInternal
+func AsError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return &Error{Code: "internal", Kind: KindInternal, Message: "internal error", Err: err}
+}
+
+func newError(kind Kind, code, message string) *Error {
+	return &Error{Code: code, Kind: kind, Message: message}
+}
+
+// storageError 包装存储层的意外错误，这类错误通常是暂时的
+func storageError(err error) error {
+	return ErrStorageUnavailable.withCause(err)
+}
+
+// FieldError 标记校验失败的请求字段，Field 使用 API 中的字段名，例如 long_url、rules[1].condition
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+func fieldError(field string, err error) error {
+	return &FieldError{Field: field, Err: err}
+}
@@ -0,0 +1,42 @@
+package shortener
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"shortlink/internal/policy"
+)
+
+func TestError_Is(t *testing.T) {
+	cause := errors.New("disk full")
+	tests := []struct {
+		name     string
+		err      error
+		target   error
+		wantIs   bool
+		wantCode string
+		wantKind Kind
+	}{
+		{name: "wrapped sentinel", err: fmt.Errorf("for code 'x': %w", ErrLinkNotFound), target: ErrLinkNotFound, wantIs: true, wantCode: "link_not_found", wantKind: KindNotFound},
+		{name: "copy with cause", err: storageError(cause), target: ErrStorageUnavailable, wantIs: true, wantCode: "storage_unavailable", wantKind: KindUnavailable},
+		{name: "cause still reachable", err: storageError(cause), target: cause, wantIs: true, wantCode: "storage_unavailable", wantKind: KindUnavailable},
+		{name: "different sentinel", err: ErrLinkExpired, target: ErrLinkExhausted, wantCode: "link_expired", wantKind: KindGone},
+		{name: "invalid url", err: &InvalidURLError{Reason: "missing_host", Err: cause}, target: ErrInvalidLongURL, wantIs: true, wantCode: "invalid_url", wantKind: KindInvalid},
+		{name: "invalid rule", err: &InvalidRuleError{Index: 1, Err: cause}, target: ErrInvalidRule, wantIs: true, wantCode: "invalid_rule", wantKind: KindInvalid},
+		{name: "template value", err: &TemplateValueError{Name: "id", Reason: "missing"}, target: ErrTemplateValue, wantIs: true, wantCode: "invalid_template_value", wantKind: KindInvalid},
+		{name: "blocked at create", err: &BlockedDestinationError{Decision: policy.Decision{Host: "evil.test"}}, target: ErrDestinationBlocked, wantIs: true, wantCode: "destination_blocked", wantKind: KindInvalid},
+		{name: "blocked at redirect", err: revoked(&BlockedDestinationError{Decision: policy.Decision{Host: "evil.test"}}), target: ErrDestinationBlocked, wantIs: true, wantCode: "destination_blocked", wantKind: KindGone},
+		{name: "unclassified", err: cause, target: ErrLinkNotFound, wantCode: "internal", wantKind: KindInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errors.Is(tt.err, tt.target); got != tt.wantIs {
+				t.Errorf("errors.Is(%v, %v) = %v, want %v", tt.err, tt.target, got, tt.wantIs)
+			}
+			if e := AsError(tt.err); e.Code != tt.wantCode || e.Kind != tt.wantKind {
+				t.Errorf("AsError() = %s/%d, want %s/%d", e.Code, e.Kind, tt.wantCode, tt.wantKind)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package shortener
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+
+	"shortlink/internal/idgen"
+	"shortlink/internal/storage"
+)
+
+func TestService_Resolve_Broken(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	svc := NewService(Config{Store: store, Generator: idgen.NewGenerator(), Logger: log.New(io.Discard, "", 0)})
+	link, err := svc.Create(ctx, CreateParams{LongURL: "https://example.com/old"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := store.Update(ctx, link.ShortCode, func(l *storage.Link) error {
+		l.Health = &storage.Health{StatusCode: 404, Failures: 3, Broken: true}
+		return nil
+	}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	redirect, err := svc.Resolve(ctx, Visit{ShortCode: link.ShortCode})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if !redirect.Broken || redirect.URL != "https://example.com/old" {
+		t.Errorf("Resolve() = %+v, want broken redirect to the destination", redirect)
+	}
+
+	// 修改目的地址后重新开始检查
+	if _, err := svc.UpdateDestination(ctx, link.ShortCode, "https://example.com/new", "tester"); err != nil {
+		t.Fatalf("UpdateDestination() error = %v", err)
+	}
+	if h, err := svc.Health(ctx, link.ShortCode); err != nil || h != nil {
+		t.Errorf("Health() after edit = %+v, %v, want nil", h, err)
+	}
+	redirect, err = svc.Resolve(ctx, Visit{ShortCode: link.ShortCode})
+	if err != nil || redirect.Broken {
+		t.Errorf("Resolve() after edit = %+v, %v, want a healthy redirect", redirect, err)
+	}
+}
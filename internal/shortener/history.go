@@ -0,0 +1,147 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"shortlink/internal/storage"
+	"shortlink/internal/tagging"
+	"strings"
+	"time"
+)
+
+var ErrVersionNotFound = newError(KindNotFound, "version_not_found", "link version not found")
+
+// Version 是链接目的地的一个版本，编号从 1 开始，最新版本即当前目的地
+type Version struct {
+	Number  int       `json:"version"`
+	LongURL string    `json:"long_url"`
+	SetAt   time.Time `json:"set_at"`
+	SetBy   string    `json:"set_by,omitempty"`
+	Current bool      `json:"current"`
+}
+
+// UpdateDestination 把短链接改为指向 longURL，旧目的地追加到历史记录中
+// longURL 与创建时一样需要通过校验、策略与跳转链检查(模板链接按模板校验)；目的地未变化时不产生新版本
+func (s *Service) UpdateDestination(ctx context.Context, shortCode, longURL, actor string) (*storage.Link, error) {
+	link, err := s.findLink(ctx, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	destination, err := s.newDestination(ctx, link, longURL)
+	if err != nil {
+		return nil, err
+	}
+	return s.setDestination(ctx, shortCode, destination, actor)
+}
+
+// newDestination 校验 link 的新目的地并返回应当保存的地址
+func (s *Service) newDestination(ctx context.Context, link *storage.Link, longURL string) (string, error) {
+	var destination string
+	var err error
+	if link.Template {
+		if err := s.validateTemplate(longURL); err != nil {
+			return "", fieldError("long_url", err)
+		}
+		destination = strings.TrimSpace(longURL)
+	} else if destination, err = s.resolveDestination(ctx, longURL); err != nil {
+		return "", fieldError("long_url", err)
+	}
+	// 新目的地与创建时一样应用创建阶段的打标策略；策略已从配置中移除时拒绝修改，不保存未打标的地址
+	policy, err := s.taggingPolicy(link.Group, link.TaggingPolicy)
+	if err != nil {
+		return "", fmt.Errorf("for code '%s': %w", link.ShortCode, err)
+	}
+	if policy != nil && policy.Stage == tagging.AtCreate {
+		destination = policy.Tag(destination)
+	}
+	return destination, nil
+}
+
+// Versions 按时间顺序返回链接的全部目的地版本，最后一个为当前版本
+func (s *Service) Versions(ctx context.Context, shortCode string) ([]Version, error) {
+	link, err := s.findLink(ctx, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	return versionsOf(link), nil
+}
+
+// Rollback 把目的地恢复为指定版本，回滚本身也作为一个新版本记录，历史不会被改写
+func (s *Service) Rollback(ctx context.Context, shortCode string, version int, actor string) (*storage.Link, error) {
+	link, err := s.findLink(ctx, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	versions := versionsOf(link)
+	if version < 1 || version > len(versions) {
+		return nil, fmt.Errorf("for code '%s': version %d: %w", shortCode, version, ErrVersionNotFound)
+	}
+	// 历史目的地在当初保存时已经通过校验，但策略可能已经收紧
+	target := versions[version-1].LongURL
+	if err := s.checkDestination(target, "rollback"); err != nil {
+		return nil, err
+	}
+	return s.setDestination(ctx, shortCode, target, actor)
+}
+
+func (s *Service) setDestination(ctx context.Context, shortCode, destination, actor string) (*storage.Link, error) {
+	now := s.now().UTC()
+	changed := false
+	link, err := s.store.Update(ctx, shortCode, func(link *storage.Link) error {
+		changed = replaceDestination(link, destination, actor, now)
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("for code '%s': %w", shortCode, ErrLinkNotFound)
+		}
+		return nil, fmt.Errorf("for code '%s': failed to update link: %w", shortCode, storageError(err))
+	}
+	s.logger.Printf("INFO: Destination updated. ShortCode: %s, Version: %d, Actor: %q, LongURL: %s\n", shortCode, len(link.History)+1, actor, destination)
+	if changed {
+		s.fetchMetadataAsync(link)
+	}
+	return link, nil
+}
+
+// replaceDestination 把 link 的目的地改为 destination 并把旧目的地追加到历史中，目的地未变化时返回 false
+func replaceDestination(link *storage.Link, destination, actor string, now time.Time) bool {
+	if link.LongURL == destination {
+		return false
+	}
+	link.History = append(link.History, storage.Revision{
+		LongURL: link.LongURL,
+		SetAt:   setAt(link),
+		SetBy:   link.UpdatedBy,
+	})
+	link.LongURL = destination
+	link.UpdatedAt = now
+	link.UpdatedBy = actor
+	// 旧目的地的预览信息与健康状态不再适用，新目的地在下一轮健康检查中立即检查
+	link.Metadata = nil
+	link.Health = nil
+	return true
+}
+
+func versionsOf(link *storage.Link) []Version {
+	versions := make([]Version, 0, len(link.History)+1)
+	for i, rev := range link.History {
+		versions = append(versions, Version{Number: i + 1, LongURL: rev.LongURL, SetAt: rev.SetAt, SetBy: rev.SetBy})
+	}
+	return append(versions, Version{
+		Number:  len(link.History) + 1,
+		LongURL: link.LongURL,
+		SetAt:   setAt(link),
+		SetBy:   link.UpdatedBy,
+		Current: true,
+	})
+}
+
+// setAt 返回当前目的地的设置时间，从未修改过的链接即创建时间
+func setAt(link *storage.Link) time.Time {
+	if link.UpdatedAt.IsZero() {
+		return link.CreatedAt
+	}
+	return link.UpdatedAt
+}
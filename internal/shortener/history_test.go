@@ -0,0 +1,171 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"shortlink/internal/idgen"
+	"shortlink/internal/storage"
+)
+
+func TestService_UpdateDestinationAndRollback(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, storage.NewMemoryStore(), idgen.NewGenerator())
+	link, err := svc.Create(ctx, CreateParams{LongURL: "https://example.com/v1"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	code := link.ShortCode
+
+	if _, err := svc.UpdateDestination(ctx, code, "https://example.com/v2", "alice"); err != nil {
+		t.Fatalf("UpdateDestination(v2) error = %v", err)
+	}
+	// 目的地未变化时不产生新版本
+	if _, err := svc.UpdateDestination(ctx, code, "https://example.com/v2", "alice"); err != nil {
+		t.Fatalf("UpdateDestination(v2 again) error = %v", err)
+	}
+	if _, err := svc.UpdateDestination(ctx, code, "https://example.com/v3", "bob"); err != nil {
+		t.Fatalf("UpdateDestination(v3) error = %v", err)
+	}
+	rolledBack, err := svc.Rollback(ctx, code, 1, "carol")
+	if err != nil {
+		t.Fatalf("Rollback(1) error = %v", err)
+	}
+	if rolledBack.LongURL != "https://example.com/v1" {
+		t.Errorf("Rollback(1).LongURL = %q, want v1", rolledBack.LongURL)
+	}
+
+	versions, err := svc.Versions(ctx, code)
+	if err != nil {
+		t.Fatalf("Versions() error = %v", err)
+	}
+	want := []struct {
+		url, by string
+	}{
+		{"https://example.com/v1", ""},
+		{"https://example.com/v2", "alice"},
+		{"https://example.com/v3", "bob"},
+		{"https://example.com/v1", "carol"},
+	}
+	if len(versions) != len(want) {
+		t.Fatalf("Versions() = %d entries, want %d: %+v", len(versions), len(want), versions)
+	}
+	for i, w := range want {
+		v := versions[i]
+		if v.Number != i+1 || v.LongURL != w.url || v.SetBy != w.by || v.Current != (i == len(want)-1) {
+			t.Errorf("Versions()[%d] = %+v, want number %d url %q by %q", i, v, i+1, w.url, w.by)
+		}
+		if v.SetAt.IsZero() {
+			t.Errorf("Versions()[%d].SetAt is zero", i)
+		}
+	}
+
+	redirect, err := svc.Resolve(ctx, Visit{ShortCode: code})
+	if err != nil || redirect.URL != "https://example.com/v1" {
+		t.Errorf("Resolve() after rollback = %v, %v; want v1", redirect, err)
+	}
+}
+
+func TestService_UpdateDestination_Errors(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, storage.NewMemoryStore(), idgen.NewGenerator())
+	link, err := svc.Create(ctx, CreateParams{LongURL: "https://example.com/"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// 链接引用的打标策略已从配置中移除
+	if err := svc.store.Save(ctx, storage.Link{ShortCode: "untagged", LongURL: "https://example.com/", TaggingPolicy: "removed"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		run     func() error
+		wantErr error
+	}{
+		{
+			name: "tagging policy removed",
+			run: func() error {
+				_, err := svc.UpdateDestination(ctx, "untagged", "https://example.com/x", "")
+				return err
+			},
+			wantErr: ErrInvalidOption,
+		},
+		{
+			name: "invalid destination",
+			run: func() error {
+				_, err := svc.UpdateDestination(ctx, link.ShortCode, "javascript:alert(1)", "")
+				return err
+			},
+			wantErr: ErrInvalidLongURL,
+		},
+		{
+			name: "unknown code",
+			run: func() error {
+				_, err := svc.UpdateDestination(ctx, "missing", "https://example.com/x", "")
+				return err
+			},
+			wantErr: ErrLinkNotFound,
+		},
+		{
+			name: "unknown version",
+			run: func() error {
+				_, err := svc.Rollback(ctx, link.ShortCode, 5, "")
+				return err
+			},
+			wantErr: ErrVersionNotFound,
+		},
+		{
+			name: "version zero",
+			run: func() error {
+				_, err := svc.Rollback(ctx, link.ShortCode, 0, "")
+				return err
+			},
+			wantErr: ErrVersionNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestService_UpdateDestination_UsesClock 版本的设置时间取自注入的时钟，与生效窗口的判断一致
+func TestService_UpdateDestination_UsesClock(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	svc := NewService(Config{
+		Store:     storage.NewMemoryStore(),
+		Generator: idgen.NewGenerator(),
+		Logger:    log.New(io.Discard, "", 0),
+		Now:       func() time.Time { return now },
+	})
+	link, err := svc.Create(ctx, CreateParams{LongURL: "https://example.com/v1"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	now = now.Add(time.Hour)
+	updated, err := svc.UpdateDestination(ctx, link.ShortCode, "https://example.com/v2", "alice")
+	if err != nil {
+		t.Fatalf("UpdateDestination() error = %v", err)
+	}
+	if !updated.UpdatedAt.Equal(now) {
+		t.Errorf("UpdatedAt = %v, want %v", updated.UpdatedAt, now)
+	}
+	versions, err := svc.Versions(ctx, link.ShortCode)
+	if err != nil {
+		t.Fatalf("Versions() error = %v", err)
+	}
+	if got := versions[len(versions)-1].SetAt; !got.Equal(now) {
+		t.Errorf("current version SetAt = %v, want %v", got, now)
+	}
+}
@@ -0,0 +1,141 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"shortlink/internal/storage"
+	"time"
+)
+
+// LinkPatch 描述对链接的部分修改，nil 字段保持不变
+type LinkPatch struct {
+	// LongURL 新的目的地，与 UpdateDestination 一样校验并记录历史版本
+	LongURL *string
+	// Password 设置新的访问密码，空字符串取消密码保护
+	Password *string
+	// MaxVisits 新的访问次数上限，0 取消限制
+	MaxVisits *int64
+	// NotBefore/NotAfter 新的生效时间窗口，零值取消该侧的限制
+	NotBefore *time.Time
+	NotAfter  *time.Time
+	// TeaserURL 新的预告页，空字符串取消预告页
+	TeaserURL *string
+	QueryMode *string
+	// RedirectType 新的跳转类型，0 恢复为服务的默认类型
+	RedirectType *int
+	// Actor 修改目的地时记录到历史版本中的操作人
+	Actor string
+}
+
+// Get 返回短码对应的链接，不记录访问
+func (s *Service) Get(ctx context.Context, shortCode string) (*storage.Link, error) {
+	return s.findLink(ctx, shortCode)
+}
+
+// UpdateLink 对链接做部分修改，全部字段校验通过后在同一个原子操作中生效
+// 字段的校验规则与 Create 相同，涉及多个字段的约束(如 not_after 晚于 not_before)按修改后的结果检查
+func (s *Service) UpdateLink(ctx context.Context, shortCode string, patch LinkPatch) (*storage.Link, error) {
+	link, err := s.findLink(ctx, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	var destination string
+	if patch.LongURL != nil {
+		if destination, err = s.newDestination(ctx, link, *patch.LongURL); err != nil {
+			return nil, err
+		}
+	}
+	if patch.MaxVisits != nil && *patch.MaxVisits < 0 {
+		return nil, fieldError("max_visits", fmt.Errorf("%w: max visits must not be negative", ErrInvalidOption))
+	}
+	if patch.QueryMode != nil && !validQueryMode(*patch.QueryMode) {
+		return nil, fieldError("query_mode", fmt.Errorf("%w: unknown query mode %q", ErrInvalidOption, *patch.QueryMode))
+	}
+	if patch.RedirectType != nil {
+		if err := validateRedirectType(*patch.RedirectType); err != nil {
+			return nil, err
+		}
+	}
+	var teaserURL string
+	if patch.TeaserURL != nil && *patch.TeaserURL != "" {
+		if teaserURL, err = s.normalizeLongURL(*patch.TeaserURL); err != nil {
+			return nil, fieldError("teaser_url", err)
+		}
+		if err := s.checkDestination(teaserURL, "update"); err != nil {
+			return nil, fieldError("teaser_url", err)
+		}
+	}
+	var passwordHash string
+	if patch.Password != nil && *patch.Password != "" {
+		if passwordHash, err = s.hashPassword(*patch.Password); err != nil {
+			return nil, fieldError("password", err)
+		}
+	}
+
+	now := s.now().UTC()
+	changed := false
+	updated, err := s.store.Update(ctx, shortCode, func(link *storage.Link) error {
+		if patch.Password != nil {
+			link.PasswordHash = passwordHash
+		}
+		if patch.MaxVisits != nil {
+			link.MaxVisits = *patch.MaxVisits
+		}
+		if patch.NotBefore != nil {
+			link.NotBefore = *patch.NotBefore
+		}
+		if patch.NotAfter != nil {
+			link.NotAfter = *patch.NotAfter
+		}
+		if patch.TeaserURL != nil {
+			link.TeaserURL = teaserURL
+		}
+		if patch.QueryMode != nil {
+			link.QueryMode = *patch.QueryMode
+		}
+		if patch.RedirectType != nil {
+			link.RedirectType = *patch.RedirectType
+		}
+		if !link.NotBefore.IsZero() && !link.NotAfter.IsZero() && !link.NotAfter.After(link.NotBefore) {
+			return fieldError("not_after", fmt.Errorf("%w: not_after must be after not_before", ErrInvalidOption))
+		}
+		if link.TeaserURL != "" && link.NotBefore.IsZero() {
+			return fieldError("teaser_url", fmt.Errorf("%w: teaser URL requires not_before", ErrInvalidOption))
+		}
+		if patch.LongURL != nil {
+			changed = replaceDestination(link, destination, patch.Actor, now)
+		}
+		return nil
+	})
+	if err != nil {
+		var fe *FieldError
+		switch {
+		case errors.As(err, &fe):
+			return nil, err
+		case errors.Is(err, storage.ErrNotFound):
+			return nil, fmt.Errorf("for code '%s': %w", shortCode, ErrLinkNotFound)
+		}
+		return nil, fmt.Errorf("for code '%s': failed to update link: %w", shortCode, storageError(err))
+	}
+	s.logger.Printf("INFO: Link updated. ShortCode: %s, Version: %d, Actor: %q\n", shortCode, len(updated.History)+1, patch.Actor)
+	if changed {
+		s.fetchMetadataAsync(updated)
+	}
+	return updated, nil
+}
+
+// Delete 删除短链接，之后该短码不再跳转并且可以被重新使用
+func (s *Service) Delete(ctx context.Context, shortCode string) error {
+	if shortCode == "" {
+		return ErrShortCodeTooShort
+	}
+	if err := s.store.Delete(ctx, shortCode); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("for code '%s': %w", shortCode, ErrLinkNotFound)
+		}
+		return fmt.Errorf("for code '%s': failed to delete link: %w", shortCode, storageError(err))
+	}
+	s.logger.Printf("INFO: Link deleted. ShortCode: %s\n", shortCode)
+	return nil
+}
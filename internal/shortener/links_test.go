@@ -0,0 +1,188 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"shortlink/internal/idgen"
+	"shortlink/internal/storage"
+)
+
+func TestService_Get_DoesNotTrackVisit(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	svc := newTestService(t, store, idgen.NewGenerator())
+	created, err := svc.Create(ctx, CreateParams{LongURL: "https://example.com/", MaxVisits: 1})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	for range 3 {
+		if _, err := svc.Get(ctx, created.ShortCode); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+	}
+	stored, _ := store.FindByShortCode(ctx, created.ShortCode)
+	if stored.VisitCount != 0 {
+		t.Errorf("VisitCount after Get() = %d, want 0", stored.VisitCount)
+	}
+	if _, err := svc.Get(ctx, "missing"); !errors.Is(err, ErrLinkNotFound) {
+		t.Errorf("Get(missing) error = %v, want %v", err, ErrLinkNotFound)
+	}
+}
+
+func TestService_UpdateLink(t *testing.T) {
+	str := func(s string) *string { return &s }
+	num := func(n int64) *int64 { return &n }
+	at := func(s string) *time.Time {
+		v, _ := time.Parse(time.RFC3339, s)
+		return &v
+	}
+
+	tests := []struct {
+		name    string
+		create  CreateParams
+		patch   LinkPatch
+		wantErr error
+		check   func(t *testing.T, link *storage.Link)
+	}{
+		{
+			name:   "options only keep destination",
+			create: CreateParams{LongURL: "https://example.com/"},
+			patch:  LinkPatch{MaxVisits: num(3), QueryMode: str(QueryMerge)},
+			check: func(t *testing.T, link *storage.Link) {
+				if link.MaxVisits != 3 || link.QueryMode != QueryMerge {
+					t.Errorf("MaxVisits, QueryMode = %d, %q, want 3, %q", link.MaxVisits, link.QueryMode, QueryMerge)
+				}
+				if link.LongURL != "https://example.com/" || len(link.History) != 0 {
+					t.Errorf("destination changed: %q, history %d", link.LongURL, len(link.History))
+				}
+			},
+		},
+		{
+			name:   "destination records version",
+			create: CreateParams{LongURL: "https://example.com/"},
+			patch:  LinkPatch{LongURL: str("https://example.com/new"), Actor: "ops"},
+			check: func(t *testing.T, link *storage.Link) {
+				if link.LongURL != "https://example.com/new" || len(link.History) != 1 || link.UpdatedBy != "ops" {
+					t.Errorf("link = %q, history %d, by %q", link.LongURL, len(link.History), link.UpdatedBy)
+				}
+			},
+		},
+		{
+			name:   "clear password and limit",
+			create: CreateParams{LongURL: "https://example.com/", Password: "secret", MaxVisits: 5},
+			patch:  LinkPatch{Password: str(""), MaxVisits: num(0)},
+			check: func(t *testing.T, link *storage.Link) {
+				if link.PasswordHash != "" || link.MaxVisits != 0 {
+					t.Errorf("PasswordHash, MaxVisits = %q, %d, want cleared", link.PasswordHash, link.MaxVisits)
+				}
+			},
+		},
+		{
+			name:   "set password",
+			create: CreateParams{LongURL: "https://example.com/"},
+			patch:  LinkPatch{Password: str("secret")},
+			check: func(t *testing.T, link *storage.Link) {
+				if link.PasswordHash == "" || link.PasswordHash == "secret" {
+					t.Errorf("PasswordHash = %q, want a bcrypt hash", link.PasswordHash)
+				}
+			},
+		},
+		{
+			name:    "window checked against stored value",
+			create:  CreateParams{LongURL: "https://example.com/", NotBefore: *at("2030-01-02T00:00:00Z")},
+			patch:   LinkPatch{NotAfter: at("2030-01-01T00:00:00Z")},
+			wantErr: ErrInvalidOption,
+		},
+		{
+			name:    "clearing not_before keeps teaser invalid",
+			create:  CreateParams{LongURL: "https://example.com/", NotBefore: *at("2030-01-02T00:00:00Z"), TeaserURL: "https://example.com/soon"},
+			patch:   LinkPatch{NotBefore: &time.Time{}},
+			wantErr: ErrInvalidOption,
+		},
+		{
+			name:    "negative max visits",
+			create:  CreateParams{LongURL: "https://example.com/"},
+			patch:   LinkPatch{MaxVisits: num(-1)},
+			wantErr: ErrInvalidOption,
+		},
+		{
+			name:    "invalid destination",
+			create:  CreateParams{LongURL: "https://example.com/"},
+			patch:   LinkPatch{LongURL: str("javascript:alert(1)"), MaxVisits: num(2)},
+			wantErr: ErrInvalidLongURL,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := storage.NewMemoryStore()
+			svc := newTestService(t, store, idgen.NewGenerator())
+			created, err := svc.Create(ctx, tt.create)
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			link, err := svc.UpdateLink(ctx, created.ShortCode, tt.patch)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateLink() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				// 校验失败时任何字段都不生效
+				stored, _ := store.FindByShortCode(ctx, created.ShortCode)
+				if stored.MaxVisits != created.MaxVisits || !stored.NotAfter.Equal(created.NotAfter) || !stored.NotBefore.Equal(created.NotBefore) {
+					t.Errorf("rejected patch was partially applied: %+v", stored)
+				}
+				return
+			}
+			tt.check(t, link)
+		})
+	}
+}
+
+// TestService_UpdateLink_UsesClock 修改目的地的时间取自注入的时钟
+func TestService_UpdateLink_UsesClock(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	svc := NewService(Config{
+		Store:     storage.NewMemoryStore(),
+		Generator: idgen.NewGenerator(),
+		Logger:    log.New(io.Discard, "", 0),
+		Now:       func() time.Time { return now },
+	})
+	link, err := svc.Create(ctx, CreateParams{LongURL: "https://example.com/"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	now = now.Add(time.Hour)
+	dest := "https://example.com/new"
+	updated, err := svc.UpdateLink(ctx, link.ShortCode, LinkPatch{LongURL: &dest})
+	if err != nil {
+		t.Fatalf("UpdateLink() error = %v", err)
+	}
+	if !updated.UpdatedAt.Equal(now) || !updated.CreatedAt.Equal(now.Add(-time.Hour)) {
+		t.Errorf("CreatedAt, UpdatedAt = %v, %v, want %v, %v", updated.CreatedAt, updated.UpdatedAt, now.Add(-time.Hour), now)
+	}
+}
+
+func TestService_Delete(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, storage.NewMemoryStore(), idgen.NewGenerator())
+	created, err := svc.Create(ctx, CreateParams{LongURL: "https://example.com/", Alias: "promo"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := svc.Delete(ctx, created.ShortCode); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := svc.Resolve(ctx, Visit{ShortCode: created.ShortCode}); !errors.Is(err, ErrLinkNotFound) {
+		t.Errorf("Resolve() after Delete() error = %v, want %v", err, ErrLinkNotFound)
+	}
+	if err := svc.Delete(ctx, created.ShortCode); !errors.Is(err, ErrLinkNotFound) {
+		t.Errorf("second Delete() error = %v, want %v", err, ErrLinkNotFound)
+	}
+}
@@ -0,0 +1,85 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+
+	"shortlink/internal/metadata"
+	"shortlink/internal/storage"
+)
+
+// MetadataFetcher 抓取目的页面的预览信息，metadata.Fetcher 实现了它
+type MetadataFetcher interface {
+	Fetch(ctx context.Context, rawURL string) (metadata.Page, error)
+}
+
+// Metadata 返回链接目的页面的预览信息，尚未抓取(或未启用抓取)时返回 nil
+func (s *Service) Metadata(ctx context.Context, shortCode string) (*storage.Metadata, error) {
+	link, err := s.findLink(ctx, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	return link.Metadata, nil
+}
+
+// fetchMetadataAsync 在后台抓取 link 当前目的地的预览信息，不阻塞创建请求
+// 同时进行的抓取数受 metadataSlots 限制，批量导入时其余的排队等待；服务的后台 context 取消后不再发起新的抓取
+// 模板链接的目的地在跳转时才确定，不抓取
+func (s *Service) fetchMetadataAsync(link *storage.Link) {
+	if s.metadataFetcher == nil || link.Template {
+		return
+	}
+	shortCode, longURL := link.ShortCode, link.LongURL
+	go func() {
+		select {
+		case s.metadataSlots <- struct{}{}:
+			defer func() { <-s.metadataSlots }()
+		case <-s.background.Done():
+		}
+		// 取消与空出名额同时发生时 select 随机选择，这里再检查一次，退出后不再发起抓取
+		if s.background.Err() != nil {
+			s.logger.Printf("INFO: Shutting down, metadata fetch skipped. ShortCode: %s\n", shortCode)
+			return
+		}
+		s.fetchMetadata(s.background, shortCode, longURL)
+	}()
+}
+
+// fetchMetadata 抓取并保存预览信息；抓取失败同样记录下来，便于排查
+// 抓取期间目的地已被修改时丢弃结果，由修改触发的那次抓取负责
+func (s *Service) fetchMetadata(ctx context.Context, shortCode, longURL string) {
+	page, err := s.metadataFetcher.Fetch(ctx, longURL)
+	if ctx.Err() != nil {
+		// 服务正在退出，中止的抓取不是目的页面的问题，不记录为抓取失败
+		s.logger.Printf("INFO: Shutting down, metadata fetch aborted. ShortCode: %s\n", shortCode)
+		return
+	}
+	md := &storage.Metadata{
+		Title:       page.Title,
+		Description: page.Description,
+		Favicon:     page.Favicon,
+		Image:       page.Image,
+		FetchedAt:   s.now().UTC(),
+	}
+	if err != nil {
+		s.logger.Printf("WARN: Failed to fetch destination metadata. ShortCode: %s, LongURL: %s, Error: %v\n", shortCode, longURL, err)
+		md = &storage.Metadata{FetchedAt: md.FetchedAt, Error: err.Error()}
+	}
+	_, err = s.store.Update(ctx, shortCode, func(link *storage.Link) error {
+		if link.LongURL != longURL {
+			return errDestinationChanged
+		}
+		link.Metadata = md
+		return nil
+	})
+	switch {
+	case errors.Is(err, errDestinationChanged):
+		s.logger.Printf("INFO: Destination changed while fetching metadata, discarded. ShortCode: %s\n", shortCode)
+	case err != nil:
+		s.logger.Printf("ERROR: Failed to save destination metadata. ShortCode: %s, Error: %v\n", shortCode, err)
+	default:
+		s.logger.Printf("INFO: Destination metadata saved. ShortCode: %s, Title: %q\n", shortCode, md.Title)
+	}
+}
+
+var errDestinationChanged = errors.New("shortener: destination changed")
@@ -0,0 +1,169 @@
+package shortener
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"shortlink/internal/idgen"
+	"shortlink/internal/metadata"
+	"shortlink/internal/storage"
+)
+
+// waitMetadata 轮询直到后台抓取写入预览信息
+func waitMetadata(t *testing.T, svc *Service, shortCode string, done func(*storage.Metadata) bool) *storage.Metadata {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		md, err := svc.Metadata(context.Background(), shortCode)
+		if err != nil {
+			t.Fatalf("Metadata() error = %v", err)
+		}
+		if md != nil && done(md) {
+			return md
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("metadata for %s was not fetched in time", shortCode)
+	return nil
+}
+
+func TestService_Create_FetchesMetadata(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<head><title>Page A</title><meta property="og:image" content="/a.png"></head>`)
+	})
+	mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<head><title>Page B</title></head>`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx := context.Background()
+	svc := NewService(Config{
+		Store:     storage.NewMemoryStore(),
+		Generator: idgen.NewGenerator(),
+		Logger:    log.New(io.Discard, "", 0),
+		MetadataFetcher: metadata.NewFetcher(metadata.Options{
+			Timeout:   time.Second,
+			AllowNets: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")},
+		}),
+	})
+
+	link, err := svc.Create(ctx, CreateParams{LongURL: srv.URL + "/a"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	md := waitMetadata(t, svc, link.ShortCode, func(*storage.Metadata) bool { return true })
+	if md.Title != "Page A" || md.Image != srv.URL+"/a.png" || md.Error != "" || md.FetchedAt.IsZero() {
+		t.Errorf("Metadata() = %+v", md)
+	}
+
+	if _, err := svc.UpdateDestination(ctx, link.ShortCode, srv.URL+"/b", "alice"); err != nil {
+		t.Fatalf("UpdateDestination() error = %v", err)
+	}
+	md = waitMetadata(t, svc, link.ShortCode, func(md *storage.Metadata) bool { return md.Title == "Page B" })
+	if md.Image != "" {
+		t.Errorf("Metadata() after update = %+v, want image cleared", md)
+	}
+
+	missing, err := svc.Create(ctx, CreateParams{LongURL: srv.URL + "/missing"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	md = waitMetadata(t, svc, missing.ShortCode, func(*storage.Metadata) bool { return true })
+	if md.Error == "" || md.Title != "" {
+		t.Errorf("Metadata() for 404 page = %+v, want an error", md)
+	}
+}
+
+// blockingFetcher 记录同时进行的抓取数，每次抓取阻塞到 ctx 取消
+type blockingFetcher struct {
+	mu       sync.Mutex
+	started  int
+	inFlight int
+	peak     int
+}
+
+func (f *blockingFetcher) Fetch(ctx context.Context, rawURL string) (metadata.Page, error) {
+	f.mu.Lock()
+	f.started++
+	f.inFlight++
+	f.peak = max(f.peak, f.inFlight)
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.inFlight--
+		f.mu.Unlock()
+	}()
+	<-ctx.Done()
+	return metadata.Page{}, ctx.Err()
+}
+
+func (f *blockingFetcher) counts() (started, inFlight, peak int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.started, f.inFlight, f.peak
+}
+
+// TestService_FetchMetadata_BoundedAndCancelled 批量创建时同时进行的抓取不超过上限，服务的 context 取消后排队的抓取不再发起
+func TestService_FetchMetadata_BoundedAndCancelled(t *testing.T) {
+	bg, stop := context.WithCancel(context.Background())
+	defer stop()
+	fetcher := &blockingFetcher{}
+	svc := NewService(Config{
+		Store:               storage.NewMemoryStore(),
+		Generator:           idgen.NewGenerator(),
+		Logger:              log.New(io.Discard, "", 0),
+		MetadataFetcher:     fetcher,
+		MetadataConcurrency: 2,
+		Context:             bg,
+	})
+	var codes []string
+	for i := 0; i < 10; i++ {
+		link, err := svc.Create(context.Background(), CreateParams{LongURL: fmt.Sprintf("https://example.com/%d", i)})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		codes = append(codes, link.ShortCode)
+	}
+
+	waitFor := func(what string, cond func(started, inFlight, peak int) bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if cond(fetcher.counts()) {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		started, inFlight, peak := fetcher.counts()
+		t.Fatalf("timed out waiting for %s: started %d, in flight %d, peak %d", what, started, inFlight, peak)
+	}
+	waitFor("two fetches", func(started, _, _ int) bool { return started == 2 })
+	time.Sleep(20 * time.Millisecond)
+	if started, _, peak := fetcher.counts(); started != 2 || peak != 2 {
+		t.Fatalf("started %d fetches with peak %d, want 2 and 2", started, peak)
+	}
+
+	stop()
+	waitFor("fetches to abort", func(_, inFlight, _ int) bool { return inFlight == 0 })
+	time.Sleep(20 * time.Millisecond)
+	if started, _, _ := fetcher.counts(); started != 2 {
+		t.Errorf("started %d fetches after shutdown, want the queued ones skipped", started)
+	}
+	for _, code := range codes {
+		if md, err := svc.Metadata(context.Background(), code); err != nil || md != nil {
+			t.Errorf("Metadata(%s) = %+v, %v; want nothing recorded for aborted fetches", code, md, err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcrypt 只使用输入的前 72 字节，更长的密码会被静默截断，因此直接拒绝
+const maxPasswordBytes = 72
+
+func (s *Service) hashPassword(password string) (string, error) {
+	if len(password) > maxPasswordBytes {
+		return "", fmt.Errorf("%w: longer than %d bytes", ErrInvalidPassword, maxPasswordBytes)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.passwordCost)
+	if err != nil {
+		return "", fmt.Errorf("shortener: hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// VerifyPassword 校验受保护链接的访问密码
+// 密码错误返回 ErrPasswordMismatch，链接未设置密码时返回 ErrLinkNotProtected
+func (s *Service) VerifyPassword(ctx context.Context, shortCode, password string) error {
+	link, err := s.findLink(ctx, shortCode)
+	if err != nil {
+		return err
+	}
+	if link.PasswordHash == "" {
+		return fmt.Errorf("for code '%s': %w", shortCode, ErrLinkNotProtected)
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return fmt.Errorf("for code '%s': %w", shortCode, ErrPasswordMismatch)
+	}
+	if err != nil {
+		return fmt.Errorf("for code '%s': compare password: %w", shortCode, err)
+	}
+	return nil
+}
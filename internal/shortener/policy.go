@@ -0,0 +1,61 @@
+package shortener
+
+import (
+	"fmt"
+	"net/url"
+
+	"shortlink/internal/policy"
+)
+
+// DestinationPolicy 决定一个目的主机是否允许被缩短或跳转，policy.Policy 与 policy.Watcher 均实现了它
+type DestinationPolicy interface {
+	Evaluate(host string) policy.Decision
+}
+
+// BlockedDestinationError 表示目的地址被策略拒绝，errors.Is(err, ErrDestinationBlocked) 对其成立
+type BlockedDestinationError struct {
+	Decision policy.Decision
+}
+
+func (e *BlockedDestinationError) Error() string {
+	if e.Decision.Rule == "" {
+		return fmt.Sprintf("shortener: destination %s blocked by default policy", e.Decision.Host)
+	}
+	return fmt.Sprintf("shortener: destination %s blocked by rule %s", e.Decision.Host, e.Decision.Rule)
+}
+
+func (e *BlockedDestinationError) Unwrap() error {
+	return ErrDestinationBlocked
+}
+
+// revoked 把跳转时的策略拒绝归为 KindGone：链接创建时是允许的，之后才被新规则拒绝
+func revoked(err error) error {
+	e := AsError(err)
+	if e.Code != ErrDestinationBlocked.Code {
+		return err
+	}
+	gone := *e
+	gone.Kind = KindGone
+	gone.Err = err
+	return &gone
+}
+
+// checkDestination 对已规范化的长链接执行目的地策略，每次判定连同命中的规则写入日志
+func (s *Service) checkDestination(longURL, stage string) error {
+	if s.policy == nil {
+		return nil
+	}
+	u, err := url.Parse(longURL)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidLongURL, err)
+	}
+	decision := s.policy.Evaluate(u.Hostname())
+	if !decision.Allowed {
+		s.logger.Printf("WARN: Destination denied by policy. Stage: %s, Host: %s, Rule: %q\n", stage, decision.Host, decision.Rule)
+		return &BlockedDestinationError{Decision: decision}
+	}
+	if decision.Rule != "" {
+		s.logger.Printf("INFO: Destination allowed by policy. Stage: %s, Host: %s, Rule: %q\n", stage, decision.Host, decision.Rule)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"shortlink/internal/storage"
+	"strings"
+)
+
+const (
+	// maxAliasSegments 自定义短码最多包含的路径段数，也是前缀匹配时最多尝试的候选数
+	maxAliasSegments = 4
+	maxAliasLen      = 64
+)
+
+var ErrInvalidPath = newError(KindInvalid, "invalid_path", "invalid path after short code")
+
+var aliasSegmentPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// reservedAliases 与服务自身路由冲突的首个路径段
+var reservedAliases = map[string]bool{"api": true, "healthz": true, "metrics": true}
+
+// validateAlias 校验自定义短码：由 / 分隔的 1-4 段字母、数字、'-'、'_' 组成，首段不能是保留路由
+func validateAlias(alias string) error {
+	if len(alias) > maxAliasLen {
+		return fmt.Errorf("%w: alias longer than %d characters", ErrInvalidOption, maxAliasLen)
+	}
+	segments := strings.Split(alias, "/")
+	if len(segments) > maxAliasSegments {
+		return fmt.Errorf("%w: alias has more than %d path segments", ErrInvalidOption, maxAliasSegments)
+	}
+	for _, seg := range segments {
+		if !aliasSegmentPattern.MatchString(seg) {
+			return fmt.Errorf("%w: alias %q may only contain letters, digits, '-', '_' and '/'", ErrInvalidOption, alias)
+		}
+	}
+	if reservedAliases[strings.ToLower(segments[0])] {
+		return fmt.Errorf("%w: alias %q is reserved", ErrInvalidOption, alias)
+	}
+	return nil
+}
+
+// Locate 把请求路径(不含开头的 /)拆分为短码与其后的路径段
+// 短码可以包含 /，因此按最长匹配优先逐个尝试；只有前缀链接或模板链接能接收多余的路径段，
+// 普通链接只在完整匹配时命中。没有任何候选命中时返回第一个路径段，由 Resolve 报告未找到
+func (s *Service) Locate(ctx context.Context, path string) (string, []string, error) {
+	var segments []string
+	for _, seg := range strings.Split(path, "/") {
+		if seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+	if len(segments) == 0 {
+		return "", nil, nil
+	}
+	if len(segments) == 1 {
+		return segments[0], nil, nil
+	}
+	for n := min(len(segments), maxAliasSegments); n >= 1; n-- {
+		code := strings.Join(segments[:n], "/")
+		rest := segments[n:]
+		link, err := s.store.FindByShortCode(ctx, code)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("for path '%s': failed to find link: %w", path, storageError(err))
+		}
+		if len(rest) == 0 || link.Prefix || link.Template {
+			return code, rest, nil
+		}
+	}
+	return segments[0], segments[1:], nil
+}
+
+// appendPath 把前缀链接之后的路径段逐段转义后拼接到目的地址的路径末尾，保留目的地址的查询与片段
+func appendPath(destination string, segments []string) (string, error) {
+	if len(segments) == 0 {
+		return destination, nil
+	}
+	escaped := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		if seg == "." || seg == ".." {
+			return "", fmt.Errorf("%w: dot segments are not allowed", ErrInvalidPath)
+		}
+		escaped = append(escaped, url.PathEscape(seg))
+	}
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", fmt.Errorf("shortener: parse destination %q: %w", destination, err)
+	}
+	rawPath := strings.TrimSuffix(u.EscapedPath(), "/") + "/" + strings.Join(escaped, "/")
+	if u.Path, err = url.PathUnescape(rawPath); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidPath, err)
+	}
+	u.RawPath = rawPath
+	return u.String(), nil
+}
@@ -0,0 +1,134 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"shortlink/internal/idgen"
+	"shortlink/internal/storage"
+)
+
+func TestValidateAlias(t *testing.T) {
+	tests := []struct {
+		alias   string
+		wantErr bool
+	}{
+		{alias: "docs"},
+		{alias: "docs/api"},
+		{alias: "team_a/q3-report"},
+		{alias: "a/b/c/d"},
+		{alias: "a/b/c/d/e", wantErr: true},
+		{alias: "docs/", wantErr: true},
+		{alias: "docs//api", wantErr: true},
+		{alias: "docs/../api", wantErr: true},
+		{alias: "API/links", wantErr: true},
+		{alias: "healthz", wantErr: true},
+		{alias: "héllo", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alias, func(t *testing.T) {
+			err := validateAlias(tt.alias)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateAlias(%q) error = %v, wantErr %v", tt.alias, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidOption) {
+				t.Errorf("validateAlias(%q) error = %v, want %v", tt.alias, err, ErrInvalidOption)
+			}
+		})
+	}
+}
+
+func TestService_Locate(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, storage.NewMemoryStore(), idgen.NewGenerator())
+	for _, params := range []CreateParams{
+		{LongURL: "https://docs.example.com/", Alias: "docs", Prefix: true},
+		{LongURL: "https://api.example.com/", Alias: "docs/api"},
+		{LongURL: "https://example.com/v2/", Alias: "docs/api/v2", Prefix: true},
+	} {
+		if _, err := svc.Create(ctx, params); err != nil {
+			t.Fatalf("Create(%q) error = %v", params.Alias, err)
+		}
+	}
+
+	tests := []struct {
+		path     string
+		wantCode string
+		wantRest []string
+	}{
+		{path: "docs", wantCode: "docs"},
+		{path: "docs/", wantCode: "docs"},
+		{path: "docs/guide", wantCode: "docs", wantRest: []string{"guide"}},
+		{path: "docs/api", wantCode: "docs/api"},
+		{path: "docs/api/x", wantCode: "docs", wantRest: []string{"api", "x"}},
+		{path: "docs/api/v2/users/1", wantCode: "docs/api/v2", wantRest: []string{"users", "1"}},
+		{path: "other/a", wantCode: "other", wantRest: []string{"a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			code, rest, err := svc.Locate(ctx, tt.path)
+			if err != nil {
+				t.Fatalf("Locate(%q) error = %v", tt.path, err)
+			}
+			if code != tt.wantCode || !slices.Equal(rest, tt.wantRest) {
+				t.Errorf("Locate(%q) = %q, %q, want %q, %q", tt.path, code, rest, tt.wantCode, tt.wantRest)
+			}
+		})
+	}
+}
+
+func TestService_Resolve_Prefix(t *testing.T) {
+	tests := []struct {
+		name     string
+		longURL  string
+		segments []string
+		want     string
+		wantErr  error
+	}{
+		{name: "no segments", longURL: "https://docs.example.com/", want: "https://docs.example.com/"},
+		{name: "segments appended", longURL: "https://docs.example.com/", segments: []string{"anything", "here"}, want: "https://docs.example.com/anything/here"},
+		{name: "base path kept", longURL: "https://docs.example.com/v1?lang=en", segments: []string{"intro"}, want: "https://docs.example.com/v1/intro?lang=en"},
+		{name: "segment is escaped", longURL: "https://docs.example.com/", segments: []string{"a b?c"}, want: "https://docs.example.com/a%20b%3Fc"},
+		{name: "dot segment", longURL: "https://docs.example.com/", segments: []string{".."}, wantErr: ErrInvalidPath},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc := newTestService(t, storage.NewMemoryStore(), idgen.NewGenerator())
+			link, err := svc.Create(ctx, CreateParams{LongURL: tt.longURL, Alias: "docs", Prefix: true})
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			redirect, err := svc.Resolve(ctx, Visit{ShortCode: link.ShortCode, PathSegments: tt.segments})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Resolve() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && redirect.URL != tt.want {
+				t.Errorf("Resolve() URL = %q, want %q", redirect.URL, tt.want)
+			}
+		})
+	}
+}
+
+func TestService_Create_Alias(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, storage.NewMemoryStore(), idgen.NewGenerator())
+	if _, err := svc.Create(ctx, CreateParams{LongURL: "https://example.com/", Alias: "go"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := svc.Create(ctx, CreateParams{LongURL: "https://example.org/", Alias: "go"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Create(duplicate alias) error = %v, want %v", err, ErrConflict)
+	}
+	if _, err := svc.Create(ctx, CreateParams{LongURL: "https://example.com/{x}", Alias: "t", Template: true, Prefix: true}); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Create(prefix template) error = %v, want %v", err, ErrInvalidOption)
+	}
+	redirect, err := svc.Resolve(ctx, Visit{ShortCode: "go"})
+	if err != nil || redirect.URL != "https://example.com/" {
+		t.Errorf("Resolve(go) = %v, %v", redirect, err)
+	}
+}
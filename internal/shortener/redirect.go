@@ -0,0 +1,97 @@
+package shortener
+
+import (
+	"fmt"
+	"net/http"
+
+	"shortlink/internal/storage"
+)
+
+// 链接可选的跳转类型，取值即跳转使用的 HTTP 状态码
+const (
+	// RedirectMovedPermanently 301，永久迁移，搜索引擎把权重转移到目的地址；浏览器可能把 POST 改为 GET
+	RedirectMovedPermanently = http.StatusMovedPermanently
+	// RedirectFound 302，临时跳转，默认类型
+	RedirectFound = http.StatusFound
+	// RedirectTemporary 307，临时跳转并保留请求方法与请求体
+	RedirectTemporary = http.StatusTemporaryRedirect
+	// RedirectPermanent 308，永久迁移并保留请求方法与请求体
+	RedirectPermanent = http.StatusPermanentRedirect
+)
+
+// ValidRedirectType 判断 t 是否为支持的跳转类型
+func ValidRedirectType(t int) bool {
+	return t == RedirectMovedPermanently || t == RedirectFound || t == RedirectTemporary || t == RedirectPermanent
+}
+
+// PermanentRedirect 判断跳转类型是否表示永久迁移
+func PermanentRedirect(t int) bool {
+	return t == RedirectMovedPermanently || t == RedirectPermanent
+}
+
+// PreservesMethod 判断跳转类型是否要求客户端以原请求方法与请求体访问目的地址
+func PreservesMethod(t int) bool {
+	return t == RedirectTemporary || t == RedirectPermanent
+}
+
+func validateRedirectType(t int) error {
+	if t != 0 && !ValidRedirectType(t) {
+		return fieldError("redirect_type", fmt.Errorf("%w: redirect type must be 301, 302, 307 or 308, got %d", ErrInvalidOption, t))
+	}
+	return nil
+}
+
+// RedirectType 返回链接实际使用的跳转类型，未单独设置时为服务的默认类型
+func (s *Service) RedirectType(link *storage.Link) int {
+	if link.RedirectType != 0 {
+		return link.RedirectType
+	}
+	return s.redirectTypeDefault
+}
+
+// TemporaryRedirectType 返回与 t 对应的临时跳转类型：301 对应 302，308 对应 307(同样保留请求方法)，其他类型原样返回
+func TemporaryRedirectType(t int) int {
+	switch t {
+	case RedirectMovedPermanently:
+		return RedirectFound
+	case RedirectPermanent:
+		return RedirectTemporary
+	}
+	return t
+}
+
+// checkedOnVisit 判断链接是否需要服务端在每次访问时处理：访问上限、生效时间窗口、密码、A/B 变体、
+// 定向规则(可能按时段选择目的地)、跳转时打标签，以及开启了跳转时的策略检查。
+// 浏览器会长期缓存永久跳转，之后的访问不再经过服务端，这些处理都会被绕过
+func (s *Service) checkedOnVisit(link *storage.Link) bool {
+	return link.MaxVisits > 0 || !link.NotBefore.IsZero() || !link.NotAfter.IsZero() ||
+		link.PasswordHash != "" || len(link.Variants) > 0 || len(link.Rules) > 0 ||
+		s.policyOnRedirect || s.tagsAtRedirect(link)
+}
+
+// redirectTo 按链接的跳转类型构造跳转结果并决定能否缓存
+// 只有永久跳转可以缓存；需要逐次检查的链接即使设置了永久跳转也降级为对应的临时跳转，不可缓存
+func (s *Service) redirectTo(link *storage.Link, destination, variant string) *Redirect {
+	redirect := &Redirect{
+		URL:      destination,
+		Variant:  variant,
+		Broken:   broken(link),
+		Status:   s.RedirectType(link),
+		Targeted: len(link.Rules) > 0,
+		Personal: link.PasswordHash != "" || len(link.Variants) > 0,
+	}
+	if !PermanentRedirect(redirect.Status) {
+		return redirect
+	}
+	if s.checkedOnVisit(link) {
+		redirect.Status = TemporaryRedirectType(redirect.Status)
+		return redirect
+	}
+	redirect.MaxAge = s.permanentMaxAge
+	return redirect
+}
+
+// temporaryRedirect 是不属于链接本身目的地的跳转(预告页、兜底地址)，总是临时且不可缓存
+func temporaryRedirect(url string) *Redirect {
+	return &Redirect{URL: url, Status: RedirectFound}
+}
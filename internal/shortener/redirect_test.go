@@ -0,0 +1,90 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"shortlink/internal/idgen"
+	"shortlink/internal/storage"
+	"shortlink/internal/tagging"
+)
+
+func TestService_Resolve_RedirectType(t *testing.T) {
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name             string
+		defaultType      int
+		policyOnRedirect bool
+		params           CreateParams
+		wantStatus       int
+		wantMaxAge       time.Duration
+		wantErr          error
+	}{
+		{name: "service default", params: CreateParams{LongURL: "https://example.com/"}, wantStatus: RedirectFound},
+		{name: "configured default", defaultType: RedirectPermanent, params: CreateParams{LongURL: "https://example.com/"},
+			wantStatus: RedirectPermanent, wantMaxAge: time.Hour},
+		{name: "per-link permanent", params: CreateParams{LongURL: "https://example.com/", RedirectType: RedirectMovedPermanently},
+			wantStatus: RedirectMovedPermanently, wantMaxAge: time.Hour},
+		{name: "per-link temporary overrides default", defaultType: RedirectMovedPermanently,
+			params: CreateParams{LongURL: "https://example.com/", RedirectType: RedirectTemporary}, wantStatus: RedirectTemporary},
+		{name: "expiring link downgraded",
+			params:     CreateParams{LongURL: "https://example.com/", RedirectType: RedirectPermanent, NotAfter: now.Add(10 * time.Minute)},
+			wantStatus: RedirectTemporary},
+		{name: "activation window downgraded",
+			params:     CreateParams{LongURL: "https://example.com/", RedirectType: RedirectMovedPermanently, NotBefore: now.Add(-time.Minute)},
+			wantStatus: RedirectFound},
+		{name: "visit limit downgraded", params: CreateParams{LongURL: "https://example.com/", RedirectType: RedirectPermanent, MaxVisits: 5},
+			wantStatus: RedirectTemporary},
+		{name: "password downgraded", params: CreateParams{LongURL: "https://example.com/", RedirectType: RedirectMovedPermanently, Password: "secret"},
+			wantStatus: RedirectFound},
+		{name: "policy on redirect downgraded", policyOnRedirect: true, params: CreateParams{LongURL: "https://example.com/", RedirectType: RedirectPermanent},
+			wantStatus: RedirectTemporary},
+		{name: "time rule downgraded", params: CreateParams{LongURL: "https://example.com/", RedirectType: RedirectPermanent,
+			Rules: []storage.RedirectRule{{Condition: "hour >= 9 && hour < 17", LongURL: "https://example.com/office"}}},
+			wantStatus: RedirectTemporary},
+		{name: "redirect-time tagging downgraded", params: CreateParams{LongURL: "https://example.com/", RedirectType: RedirectMovedPermanently, Group: "partners"},
+			wantStatus: RedirectFound},
+		{name: "create-time tagging cached", params: CreateParams{LongURL: "https://example.com/", RedirectType: RedirectMovedPermanently, Group: "growth"},
+			wantStatus: RedirectMovedPermanently, wantMaxAge: time.Hour},
+		{name: "unsupported type", params: CreateParams{LongURL: "https://example.com/", RedirectType: 303}, wantErr: ErrInvalidOption},
+	}
+
+	set, err := tagging.Parse(strings.NewReader(testTaggingPolicies))
+	if err != nil {
+		t.Fatalf("tagging.Parse() error = %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc := NewService(Config{
+				Store:                   storage.NewMemoryStore(),
+				Generator:               idgen.NewGenerator(),
+				Logger:                  log.New(io.Discard, "", 0),
+				DefaultRedirectType:     tt.defaultType,
+				CheckPolicyOnRedirect:   tt.policyOnRedirect,
+				PermanentRedirectMaxAge: time.Hour,
+				Now:                     func() time.Time { return now },
+				Tagging:                 set,
+			})
+			link, err := svc.Create(ctx, tt.params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Create() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			redirect, err := svc.Resolve(ctx, Visit{ShortCode: link.ShortCode, Unlocked: true})
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if redirect.Status != tt.wantStatus || redirect.MaxAge != tt.wantMaxAge {
+				t.Errorf("Resolve() status, max age = %d, %v, want %d, %v", redirect.Status, redirect.MaxAge, tt.wantStatus, tt.wantMaxAge)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package shortener
+
+import (
+	"context"
+	"fmt"
+	"shortlink/internal/storage"
+	"shortlink/internal/targeting"
+)
+
+// MaxRules 单个链接允许的定向规则数量上限
+const MaxRules = 32
+
+var ErrInvalidRule = newError(KindInvalid, "invalid_rule", "invalid targeting rule")
+
+// InvalidRuleError 描述第 Index 条(从 0 开始)定向规则不合法的原因，errors.Is(err, ErrInvalidRule) 对其成立
+type InvalidRuleError struct {
+	Index int
+	Err   error
+}
+
+func (e *InvalidRuleError) Error() string {
+	return fmt.Sprintf("shortener: invalid rule %d: %v", e.Index, e.Err)
+}
+
+// Unwrap 同时返回哨兵与原因，errors.As 可以从中取出 *Error
+func (e *InvalidRuleError) Unwrap() []error {
+	return []error{ErrInvalidRule, e.Err}
+}
+
+// RuleMatch 是定向规则对一次请求的求值结果
+type RuleMatch struct {
+	// Index 命中规则的序号，-1 表示没有规则命中、使用默认目的地
+	Index     int
+	Condition string
+	LongURL   string
+}
+
+// TestRules 用一个模拟请求对链接的定向规则求值，不会记录访问
+// req.Time 为零值时使用服务的当前时间
+func (s *Service) TestRules(ctx context.Context, shortCode string, req targeting.Request) (*RuleMatch, error) {
+	link, err := s.findLink(ctx, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	return s.matchRules(link, req), nil
+}
+
+// validateRules 编译每条规则的条件并校验其目的地，返回规范化后的规则
+func (s *Service) validateRules(ctx context.Context, rules []storage.RedirectRule) ([]storage.RedirectRule, error) {
+	if len(rules) > MaxRules {
+		return nil, fieldError("rules", fmt.Errorf("%w: at most %d rules are allowed", ErrInvalidOption, MaxRules))
+	}
+	validated := make([]storage.RedirectRule, 0, len(rules))
+	for i, rule := range rules {
+		if _, err := targeting.Compile(rule.Condition); err != nil {
+			return nil, fieldError(fmt.Sprintf("rules[%d].condition", i), &InvalidRuleError{Index: i, Err: err})
+		}
+		destination, err := s.resolveDestination(ctx, rule.LongURL)
+		if err != nil {
+			return nil, fieldError(fmt.Sprintf("rules[%d].long_url", i), &InvalidRuleError{Index: i, Err: err})
+		}
+		validated = append(validated, storage.RedirectRule{Condition: rule.Condition, LongURL: destination})
+	}
+	return validated, nil
+}
+
+// matchRules 按顺序求值，返回第一条命中的规则，都不命中时返回默认目的地
+func (s *Service) matchRules(link *storage.Link, req targeting.Request) *RuleMatch {
+	if len(link.Rules) == 0 {
+		return &RuleMatch{Index: -1, LongURL: link.LongURL}
+	}
+	if req.Time.IsZero() {
+		req.Time = s.now()
+	}
+	req.Time = req.Time.In(s.location)
+	for i, rule := range link.Rules {
+		expr, err := targeting.Compile(rule.Condition)
+		if err != nil {
+			// 规则在保存前已经校验过，这里只可能是语法升级导致的不兼容
+			s.logger.Printf("ERROR: Skipping invalid stored rule. ShortCode: %s, Rule: %d, Error: %v\n", link.ShortCode, i, err)
+			continue
+		}
+		if expr.Match(req) {
+			return &RuleMatch{Index: i, Condition: rule.Condition, LongURL: rule.LongURL}
+		}
+	}
+	return &RuleMatch{Index: -1, LongURL: link.LongURL}
+}
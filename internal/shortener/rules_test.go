@@ -0,0 +1,100 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"shortlink/internal/idgen"
+	"shortlink/internal/storage"
+	"shortlink/internal/targeting"
+)
+
+func TestService_Resolve_Rules(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, storage.NewMemoryStore(), idgen.NewGenerator())
+	link, err := svc.Create(ctx, CreateParams{
+		LongURL: "https://example.com/",
+		Rules: []storage.RedirectRule{
+			{Condition: `platform == "ios"`, LongURL: "https://apps.apple.com/app/id1"},
+			{Condition: `platform == "android"`, LongURL: "https://play.google.com/store/apps/details?id=x"},
+			{Condition: `lang == "de"`, LongURL: "https://example.com/de"},
+			{Condition: `country == "JP" && hour >= 9 && hour < 18`, LongURL: "https://example.com/jp-support"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	noon := time.Date(2030, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		target  targeting.Request
+		wantURL string
+	}{
+		{name: "ios wins over language", target: targeting.Request{Platform: "ios", Language: "de"}, wantURL: "https://apps.apple.com/app/id1"},
+		{name: "android", target: targeting.Request{Platform: "android"}, wantURL: "https://play.google.com/store/apps/details?id=x"},
+		{name: "german desktop", target: targeting.Request{Platform: "windows", Language: "de-ch"}, wantURL: "https://example.com/de"},
+		{name: "japan office hours", target: targeting.Request{Country: "JP", Time: noon}, wantURL: "https://example.com/jp-support"},
+		{name: "japan at night", target: targeting.Request{Country: "JP", Time: noon.Add(10 * time.Hour)}, wantURL: "https://example.com/"},
+		{name: "default", target: targeting.Request{Platform: "linux", Language: "en"}, wantURL: "https://example.com/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redirect, err := svc.Resolve(ctx, Visit{ShortCode: link.ShortCode, Target: tt.target})
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if redirect.URL != tt.wantURL {
+				t.Errorf("Resolve() URL = %q, want %q", redirect.URL, tt.wantURL)
+			}
+			match, err := svc.TestRules(ctx, link.ShortCode, tt.target)
+			if err != nil {
+				t.Fatalf("TestRules() error = %v", err)
+			}
+			if match.LongURL != tt.wantURL {
+				t.Errorf("TestRules() URL = %q, want %q", match.LongURL, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestService_Create_InvalidRules(t *testing.T) {
+	tests := []struct {
+		name      string
+		rules     []storage.RedirectRule
+		wantIndex int
+		wantErr   error
+	}{
+		{
+			name:      "syntax error",
+			rules:     []storage.RedirectRule{{Condition: `platform == "ios"`, LongURL: "https://example.com/a"}, {Condition: `os == "ios"`, LongURL: "https://example.com/b"}},
+			wantIndex: 1,
+		},
+		{
+			name:      "invalid destination",
+			rules:     []storage.RedirectRule{{Condition: `platform == "ios"`, LongURL: "javascript:alert(1)"}},
+			wantIndex: 0,
+			wantErr:   ErrInvalidLongURL,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, storage.NewMemoryStore(), idgen.NewGenerator())
+			_, err := svc.Create(context.Background(), CreateParams{LongURL: "https://example.com/", Rules: tt.rules})
+			var rerr *InvalidRuleError
+			if !errors.As(err, &rerr) || !errors.Is(err, ErrInvalidRule) {
+				t.Fatalf("Create() error = %v, want *InvalidRuleError", err)
+			}
+			if rerr.Index != tt.wantIndex {
+				t.Errorf("InvalidRuleError.Index = %d, want %d", rerr.Index, tt.wantIndex)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Create() error = %v, want it to wrap %v", err, tt.wantErr)
+			}
+		})
+	}
+}
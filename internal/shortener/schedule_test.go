@@ -0,0 +1,117 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"shortlink/internal/idgen"
+	"shortlink/internal/storage"
+)
+
+func TestService_Resolve_ActivationWindow(t *testing.T) {
+	launch := time.Date(2030, 3, 1, 9, 0, 0, 0, time.UTC)
+	end := launch.Add(48 * time.Hour)
+
+	tests := []struct {
+		name       string
+		params     CreateParams
+		now        time.Time
+		wantURL    string
+		wantErr    error
+		wantVisits int64
+	}{
+		{
+			name:    "before launch without teaser",
+			params:  CreateParams{NotBefore: launch, NotAfter: end},
+			now:     launch.Add(-time.Minute),
+			wantErr: ErrLinkNotYetActive,
+		},
+		{
+			name:    "before launch with teaser",
+			params:  CreateParams{NotBefore: launch, TeaserURL: "https://example.com/coming-soon"},
+			now:     launch.Add(-time.Hour),
+			wantURL: "https://example.com/coming-soon",
+		},
+		{
+			name:       "at launch",
+			params:     CreateParams{NotBefore: launch, NotAfter: end, TeaserURL: "https://example.com/coming-soon"},
+			now:        launch,
+			wantURL:    "https://example.com/launch",
+			wantVisits: 1,
+		},
+		{
+			name:    "at end",
+			params:  CreateParams{NotBefore: launch, NotAfter: end},
+			now:     end,
+			wantErr: ErrLinkExpired,
+		},
+		{
+			name:       "only end, before it",
+			params:     CreateParams{NotAfter: end},
+			now:        launch,
+			wantURL:    "https://example.com/launch",
+			wantVisits: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			now := tt.now
+			store := storage.NewMemoryStore()
+			svc := NewService(Config{
+				Store:     store,
+				Generator: idgen.NewGenerator(),
+				Logger:    log.New(io.Discard, "", 0),
+				Now:       func() time.Time { return now },
+			})
+			tt.params.LongURL = "https://example.com/launch"
+			// 限次链接同步计数，便于断言访问次数
+			tt.params.MaxVisits = 100
+			link, err := svc.Create(ctx, tt.params)
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			redirect, err := svc.Resolve(ctx, Visit{ShortCode: link.ShortCode})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Resolve() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && redirect.URL != tt.wantURL {
+				t.Errorf("Resolve() URL = %q, want %q", redirect.URL, tt.wantURL)
+			}
+			stored, _ := store.FindByShortCode(ctx, link.ShortCode)
+			if stored.VisitCount != tt.wantVisits {
+				t.Errorf("VisitCount = %d, want %d", stored.VisitCount, tt.wantVisits)
+			}
+		})
+	}
+}
+
+func TestService_Create_ActivationWindowValidation(t *testing.T) {
+	launch := time.Date(2030, 3, 1, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		params  CreateParams
+		wantErr error
+	}{
+		{name: "end before start", params: CreateParams{NotBefore: launch, NotAfter: launch.Add(-time.Hour)}, wantErr: ErrInvalidOption},
+		{name: "end equals start", params: CreateParams{NotBefore: launch, NotAfter: launch}, wantErr: ErrInvalidOption},
+		{name: "teaser without start", params: CreateParams{TeaserURL: "https://example.com/soon"}, wantErr: ErrInvalidOption},
+		{name: "invalid teaser", params: CreateParams{NotBefore: launch, TeaserURL: "javascript:alert(1)"}, wantErr: ErrInvalidLongURL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, storage.NewMemoryStore(), idgen.NewGenerator())
+			tt.params.LongURL = "https://example.com/launch"
+			if _, err := svc.Create(context.Background(), tt.params); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Create() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
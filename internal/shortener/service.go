@@ -5,10 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net/url"
+	"os"
 	"shortlink/internal/idgen"
 	"shortlink/internal/storage"
+	"shortlink/internal/tagging"
+	"shortlink/internal/targeting"
+	"shortlink/internal/urlnorm"
 	"strings"
 	"time"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 // 核心业务逻辑
@@ -20,27 +27,107 @@ import (
 // DIP(依赖倒置原则)：
 
 var (
-	ErrInvalidLongURL            = errors.New("shortener: long URL is invalid or empty.")
-	ErrShortCodeTooShort         = errors.New("shortener: short code is too short or invalid.")
-	ErrShortCodeGenerationFailed = errors.New("shortener: failed to generate short code.")
-	ErrLinkNotFound              = errors.New("shortener: link not found.")
-	ErrConflict                  = errors.New("shortener: conflict,possibly short code exists or generation failed after retries.")
+	ErrInvalidLongURL            = newError(KindInvalid, "invalid_url", "long URL is invalid or empty")
+	ErrShortCodeTooShort         = newError(KindNotFound, "short_code_invalid", "short code is too short or invalid")
+	ErrShortCodeGenerationFailed = &Error{Code: "generation_failed", Kind: KindUnavailable, Message: "failed to generate short code", Retryable: true}
+	ErrLinkNotFound              = newError(KindNotFound, "link_not_found", "link not found")
+	ErrConflict                  = newError(KindConflict, "conflict", "short code already exists")
+	ErrDestinationBlocked        = newError(KindInvalid, "destination_blocked", "destination is not allowed by policy")
+	ErrRedirectLoop              = newError(KindInvalid, "redirect_loop", "destination redirects back to this service")
+	ErrRedirectChain             = newError(KindInvalid, "redirect_chain", "destination is an opaque redirect chain")
+	ErrInvalidPassword           = newError(KindInvalid, "invalid_password", "password is invalid")
+	ErrPasswordRequired          = newError(KindUnauthorized, "password_required", "link is password protected")
+	ErrPasswordMismatch          = newError(KindUnauthorized, "password_mismatch", "password does not match")
+	ErrLinkNotProtected          = newError(KindConflict, "link_not_protected", "link is not password protected")
+	ErrTooManyAttempts           = &Error{Code: "too_many_attempts", Kind: KindRateLimited, Message: "too many attempts", Retryable: true}
+	ErrInvalidOption             = newError(KindInvalid, "invalid_option", "invalid link option")
+	ErrLinkExhausted             = newError(KindGone, "link_exhausted", "link has reached its visit limit")
+	ErrLinkNotYetActive          = newError(KindNotFound, "link_not_yet_active", "link is not yet active")
+	ErrLinkExpired               = newError(KindGone, "link_expired", "link has expired")
+	ErrStorageUnavailable        = &Error{Code: "storage_unavailable", Kind: KindUnavailable, Message: "storage is unavailable", Retryable: true}
 )
 
+// InvalidURLError 描述长链接未通过校验的具体原因，errors.Is(err, ErrInvalidLongURL) 对其成立
+type InvalidURLError struct {
+	// Reason 取值见 urlnorm.Reason* 常量
+	Reason string
+	Err    error
+}
+
+func (e *InvalidURLError) Error() string {
+	return fmt.Sprintf("shortener: invalid long URL (%s): %v", e.Reason, e.Err)
+}
+
+// Unwrap 同时返回哨兵与原因，errors.As 可以从中取出 *Error
+func (e *InvalidURLError) Unwrap() []error {
+	return []error{ErrInvalidLongURL, e.Err}
+}
+
 type Config struct {
-	Store           storage.Storer
-	Generator       idgen.Generator
-	Logger          *log.Logger
-	MaxGenAttemps   int
-	MinShortCodeLen int
+	Store     storage.Storer
+	Generator idgen.Generator
+	Logger    *log.Logger
+	// URLNormalizer 校验并规范化长链接，为空时使用 urlnorm 的默认规则(仅 http/https)
+	URLNormalizer *urlnorm.Normalizer
+	// Policy 目的域名策略，为空时不做限制
+	Policy DestinationPolicy
+	// CheckPolicyOnRedirect 为 true 时每次跳转都重新评估策略，使新加入的拒绝规则对存量链接立即生效
+	CheckPolicyOnRedirect bool
+	// PublicHosts 本服务对外的域名，指向它们的长链接会形成跳转回环
+	PublicHosts []string
+	// KnownShorteners 其他短链接服务的域名(含子域名)，用于识别不透明的跳转链
+	KnownShorteners []string
+	// ChainResolver 非空时在创建阶段跟随目的地址的跳转链，为空时直接拒绝已知短链接服务
+	ChainResolver *ChainResolver
+	// StoreFinalDestination 为 true 时保存跳转链的最终目的地而不是用户提交的 URL
+	StoreFinalDestination bool
+	// PasswordHashCost bcrypt 的计算代价，<= 0 时使用 bcrypt.DefaultCost
+	PasswordHashCost int
+	// ExhaustedFallbackURL 非空时，达到访问上限的链接改为跳转到此地址而不是返回 ErrLinkExhausted
+	ExhaustedFallbackURL string
+	// Now 返回当前时间，用于判断链接的生效窗口，为空时使用 time.Now
+	Now func() time.Time
+	// Location 定向规则中 hour、weekday 所用的时区，为空时使用 UTC
+	Location *time.Location
+	// Tagging 命名的 UTM 打标策略及分组默认策略，为空时不打标
+	Tagging *tagging.Set
+	// MetadataFetcher 非空时在创建或修改目的地后异步抓取目的页面的预览信息
+	MetadataFetcher MetadataFetcher
+	// MetadataConcurrency 同时进行的预览信息抓取上限，<= 0 时使用 4；批量导入时超出的抓取排队等待
+	MetadataConcurrency int
+	// Context 后台任务(预览信息抓取)的生命周期，取消后排队的抓取不再发起、进行中的抓取随之中止；为空时使用 context.Background()
+	Context context.Context
+	// DefaultRedirectType 未单独设置跳转类型的链接使用的状态码(301/302/307/308)，为 0 时使用 302
+	DefaultRedirectType int
+	// PermanentRedirectMaxAge 永久跳转允许浏览器与 CDN 缓存的时长，<= 0 时使用 24 小时
+	PermanentRedirectMaxAge time.Duration
+	MaxGenAttemps           int
+	MinShortCodeLen         int
 }
 
 type Service struct {
-	store           storage.Storer
-	generator       idgen.Generator
-	logger          *log.Logger
-	maxGenAttempts  int
-	minShortCodeLen int
+	store                 storage.Storer
+	generator             idgen.Generator
+	logger                *log.Logger
+	normalizer            *urlnorm.Normalizer
+	policy                DestinationPolicy
+	policyOnRedirect      bool
+	publicHosts           hostSet
+	knownShorteners       hostSet
+	chainResolver         *ChainResolver
+	storeFinalDestination bool
+	passwordCost          int
+	exhaustedFallbackURL  string
+	now                   func() time.Time
+	location              *time.Location
+	tagging               *tagging.Set
+	metadataFetcher       MetadataFetcher
+	metadataSlots         chan struct{}
+	background            context.Context
+	redirectTypeDefault   int
+	permanentMaxAge       time.Duration
+	maxGenAttempts        int
+	minShortCodeLen       int
 }
 
 func NewService(cfg Config) *Service {
@@ -53,69 +140,382 @@ func NewService(cfg Config) *Service {
 	if cfg.MinShortCodeLen <= 0 {
 		cfg.MinShortCodeLen = 5
 	}
+	if cfg.Logger == nil {
+		cfg.Logger = log.New(os.Stdout, "[shortener] ", log.LstdFlags|log.Lshortfile)
+	}
+
+	if cfg.PasswordHashCost <= 0 {
+		cfg.PasswordHashCost = bcrypt.DefaultCost
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	if cfg.Location == nil {
+		cfg.Location = time.UTC
+	}
+	if cfg.URLNormalizer == nil {
+		cfg.URLNormalizer = urlnorm.New(urlnorm.Options{StripDefaultPort: true})
+	}
+	if cfg.DefaultRedirectType == 0 {
+		cfg.DefaultRedirectType = RedirectFound
+	}
+	if !ValidRedirectType(cfg.DefaultRedirectType) {
+		log.Fatalf("invalid default redirect type %d", cfg.DefaultRedirectType)
+	}
+	if cfg.PermanentRedirectMaxAge <= 0 {
+		cfg.PermanentRedirectMaxAge = 24 * time.Hour
+	}
+	if cfg.MetadataConcurrency <= 0 {
+		cfg.MetadataConcurrency = 4
+	}
+	if cfg.Context == nil {
+		cfg.Context = context.Background()
+	}
 
 	return &Service{
-		store:           cfg.Store,
-		generator:       cfg.Generator,
-		logger:          cfg.Logger,
-		maxGenAttempts:  cfg.MaxGenAttemps,
-		minShortCodeLen: cfg.MinShortCodeLen,
+		store:                 cfg.Store,
+		generator:             cfg.Generator,
+		logger:                cfg.Logger,
+		normalizer:            cfg.URLNormalizer,
+		policy:                cfg.Policy,
+		policyOnRedirect:      cfg.CheckPolicyOnRedirect,
+		publicHosts:           newHostSet(cfg.PublicHosts, false),
+		knownShorteners:       newHostSet(cfg.KnownShorteners, true),
+		chainResolver:         cfg.ChainResolver,
+		storeFinalDestination: cfg.StoreFinalDestination,
+		passwordCost:          cfg.PasswordHashCost,
+		exhaustedFallbackURL:  cfg.ExhaustedFallbackURL,
+		now:                   cfg.Now,
+		location:              cfg.Location,
+		tagging:               cfg.Tagging,
+		metadataFetcher:       cfg.MetadataFetcher,
+		metadataSlots:         make(chan struct{}, cfg.MetadataConcurrency),
+		background:            cfg.Context,
+		redirectTypeDefault:   cfg.DefaultRedirectType,
+		permanentMaxAge:       cfg.PermanentRedirectMaxAge,
+		maxGenAttempts:        cfg.MaxGenAttemps,
+		minShortCodeLen:       cfg.MinShortCodeLen,
 	}
 }
 
+// CreateParams 是创建短链接的参数
+type CreateParams struct {
+	LongURL string
+	// Password 非空时访问前需要输入密码，只保存其 bcrypt 哈希
+	Password string
+	// MaxVisits 大于 0 时链接在被访问这么多次后失效，1 即一次性链接
+	MaxVisits int64
+	// NotBefore/NotAfter 链接的生效时间窗口，零值表示该侧不限制
+	NotBefore time.Time
+	NotAfter  time.Time
+	// TeaserURL 生效前访问时跳转的预告页，需要同时设置 NotBefore
+	TeaserURL string
+	// Rules 定向规则，按顺序求值，都不命中时跳转到 LongURL
+	Rules []storage.RedirectRule
+	// Variants A/B 测试的加权目的地，非空时 LongURL 只作为展示与校验用的主地址
+	Variants []storage.Variant
+	// Template 为 true 时 LongURL 是形如 https://shop/{sku}?ref={ref} 的模板
+	Template bool
+	// QueryMode 查询参数透传方式，见 QueryDrop、QueryAppend、QueryMerge
+	QueryMode string
+	// Alias 自定义短码，可以包含 /(如 docs/api)；为空时自动生成，已存在时返回 ErrConflict
+	Alias string
+	// Prefix 为 true 时短码之后的路径段拼接到目的地址之后
+	Prefix bool
+	// Group 链接分组，分组配置了默认打标策略时自动应用
+	Group string
+	// TaggingPolicy 显式指定的打标策略名，优先于分组的默认策略
+	TaggingPolicy string
+	// RedirectType 跳转使用的状态码(301/302/307/308)，0 表示使用服务的默认类型
+	RedirectType int
+}
+
+// CreateShortLink 校验并规范化 longURL 后为其分配短码，是 Create 在无额外选项时的简写
 func (s *Service) CreateShortLink(ctx context.Context, longURL string) (string, error) {
-	if strings.TrimSpace(longURL) == "" {
-		return "", ErrInvalidLongURL
+	link, err := s.Create(ctx, CreateParams{LongURL: longURL})
+	if err != nil {
+		return "", err
 	}
-	var shortCode string
-	for i := range s.maxGenAttempts {
-		log.Printf("DEBUG: Attempting to generate short code,attempt %d,longURL: %s \n", i+1, longURL)
-		code, genErr := s.generator.GenerateShortCode(ctx, longURL)
-		if genErr != nil {
-			return "", fmt.Errorf("attempt %d:failed to generate short code:%w", i+1, genErr)
-		}
-		shortCode = code
-		if len(shortCode) < s.minShortCodeLen {
-			s.logger.Printf("WARN: Generated short code too short, retrying. Code: %s, Attempt: %d\n", shortCode, i+1)
-			if i < s.maxGenAttempts {
-				continue
-			} else {
-				break
+	return link.ShortCode, nil
+}
+
+// Create 校验参数并保存新的短链接，配置了 MetadataFetcher 时随后在后台抓取预览信息
+// longURL 不合法时返回 *InvalidURLError，目的地被策略拒绝时返回 *BlockedDestinationError，
+// 指回本服务或属于不透明跳转链时返回 ErrRedirectLoop / ErrRedirectChain
+func (s *Service) Create(ctx context.Context, params CreateParams) (*storage.Link, error) {
+	link, err := s.create(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	// 复用的已有链接可能已经抓取过
+	if link.Metadata == nil {
+		s.fetchMetadataAsync(link)
+	}
+	return link, nil
+}
+
+func (s *Service) create(ctx context.Context, params CreateParams) (*storage.Link, error) {
+	var longURL string
+	var err error
+	if params.Template {
+		if len(params.Variants) > 0 {
+			return nil, fieldError("template", fmt.Errorf("%w: templates cannot be combined with variants", ErrInvalidOption))
+		}
+		if err = s.validateTemplate(params.LongURL); err != nil {
+			return nil, fieldError("long_url", err)
+		}
+		longURL = strings.TrimSpace(params.LongURL)
+	} else if longURL, err = s.resolveDestination(ctx, params.LongURL); err != nil {
+		return nil, fieldError("long_url", err)
+	}
+	if !validQueryMode(params.QueryMode) {
+		return nil, fieldError("query_mode", fmt.Errorf("%w: unknown query mode %q", ErrInvalidOption, params.QueryMode))
+	}
+	if params.Prefix && params.Template {
+		return nil, fieldError("prefix", fmt.Errorf("%w: prefix links cannot be templates", ErrInvalidOption))
+	}
+	if params.Alias != "" {
+		if err := validateAlias(params.Alias); err != nil {
+			return nil, fieldError("alias", err)
+		}
+	}
+	policy, err := s.taggingPolicy(params.Group, params.TaggingPolicy)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateRedirectType(params.RedirectType); err != nil {
+		return nil, err
+	}
+	if params.MaxVisits < 0 {
+		return nil, fieldError("max_visits", fmt.Errorf("%w: max visits must not be negative", ErrInvalidOption))
+	}
+	if !params.NotBefore.IsZero() && !params.NotAfter.IsZero() && !params.NotAfter.After(params.NotBefore) {
+		return nil, fieldError("not_after", fmt.Errorf("%w: not_after must be after not_before", ErrInvalidOption))
+	}
+	linkToSave := storage.Link{
+		LongURL:       longURL,
+		VisitCount:    0,
+		CreatedAt:     s.now().UTC(),
+		MaxVisits:     params.MaxVisits,
+		NotBefore:     params.NotBefore,
+		NotAfter:      params.NotAfter,
+		Template:      params.Template,
+		QueryMode:     params.QueryMode,
+		Prefix:        params.Prefix,
+		Group:         params.Group,
+		TaggingPolicy: params.TaggingPolicy,
+		RedirectType:  params.RedirectType,
+	}
+	if params.TeaserURL != "" {
+		if params.NotBefore.IsZero() {
+			return nil, fieldError("teaser_url", fmt.Errorf("%w: teaser URL requires not_before", ErrInvalidOption))
+		}
+		if linkToSave.TeaserURL, err = s.normalizeLongURL(params.TeaserURL); err != nil {
+			return nil, fieldError("teaser_url", err)
+		}
+		if err := s.checkDestination(linkToSave.TeaserURL, "create"); err != nil {
+			return nil, fieldError("teaser_url", err)
+		}
+	}
+	if len(params.Rules) > 0 {
+		if linkToSave.Rules, err = s.validateRules(ctx, params.Rules); err != nil {
+			return nil, err
+		}
+	}
+	if len(params.Variants) > 0 {
+		if linkToSave.Variants, err = s.validateVariants(ctx, params.Variants); err != nil {
+			return nil, err
+		}
+	}
+	if params.Password != "" {
+		if linkToSave.PasswordHash, err = s.hashPassword(params.Password); err != nil {
+			return nil, fieldError("password", err)
+		}
+	}
+
+	// 确定性短码哈希的是打标之前的规范化目的地，与分组及打标策略无关，离线工具(shortlink idgen code)可以复现；
+	// 创建阶段的打标结果只是保存下来的目的地
+	contentKey := longURL
+	tagAtCreate(policy, &linkToSave)
+
+	if params.Alias != "" {
+		linkToSave.ShortCode = params.Alias
+		if err := s.store.Save(ctx, linkToSave); err != nil {
+			if errors.Is(err, storage.ErrShortCodeExists) {
+				return nil, fmt.Errorf("alias '%s': %w", params.Alias, ErrConflict)
 			}
+			return nil, fmt.Errorf("alias '%s': failed to save short link: %w", params.Alias, storageError(err))
 		}
+		saved := linkToSave
+		return &saved, nil
+	}
 
-		linkToSave := storage.Link{
-			ShortCode:  shortCode,
-			LongURL:    longURL,
-			VisitCount: 0,
-			CreatedAt:  time.Now().UTC(),
+	salted, isSalted := s.generator.(idgen.SaltedGenerator)
+	for i := range s.maxGenAttempts {
+		var code string
+		var genErr error
+		if isSalted {
+			// 确定性生成器对同一 URL 总是给出同一个短码，冲突时以尝试序号作为盐值取下一个候选
+			code, genErr = salted.GenerateSaltedShortCode(ctx, contentKey, i)
+		} else {
+			code, genErr = s.generator.GenerateShortCode(ctx, contentKey)
 		}
+		if genErr != nil {
+			return nil, fmt.Errorf("attempt %d: %w", i+1, ErrShortCodeGenerationFailed.withCause(genErr))
+		}
+		if len(code) < s.minShortCodeLen {
+			s.logger.Printf("WARN: Generated short code too short, retrying. Code: %s, Attempt: %d\n", code, i+1)
+			continue
+		}
+
+		linkToSave.ShortCode = code
 		saveErr := s.store.Save(ctx, linkToSave)
 		if saveErr != nil {
-			if errors.Is(saveErr, storage.ErrShortCodeExists) && i < s.maxGenAttempts-1 {
-				log.Printf("WARN: Short code collision,retrying,Attempt: %d Code:%s\n", i+1, shortCode)
-				continue
+			if errors.Is(saveErr, storage.ErrShortCodeExists) {
+				// 同一个 URL 已经以该短码保存过(确定性模式下的重复创建)，直接复用而不是换一个新码
+				existing, findErr := s.store.FindByShortCode(ctx, code)
+				if findErr != nil && !errors.Is(findErr, storage.ErrNotFound) {
+					return nil, fmt.Errorf("attempt %d:failed to check existing short link:%w", i+1, storageError(findErr))
+				}
+				if findErr == nil && reusable(existing, linkToSave) {
+					return existing, nil
+				}
+				if i < s.maxGenAttempts-1 {
+					s.logger.Printf("WARN: Short code collision, retrying. Code: %s, Attempt: %d\n", code, i+1)
+					continue
+				}
 			}
-			return "", fmt.Errorf("attempt %d:failed to save short link:%w", i+1, saveErr)
+			if errors.Is(saveErr, storage.ErrShortCodeExists) {
+				return nil, fmt.Errorf("after %d attempts: %w", s.maxGenAttempts, ErrShortCodeGenerationFailed.withCause(saveErr))
+			}
+			return nil, fmt.Errorf("attempt %d:failed to save short link:%w", i+1, storageError(saveErr))
 		}
 
-		return shortCode, nil
+		saved := linkToSave
+		return &saved, nil
 	}
 
-	return "", fmt.Errorf("failed to generate short code after %d attempts", s.maxGenAttempts)
+	return nil, fmt.Errorf("after %d attempts: %w", s.maxGenAttempts, ErrShortCodeGenerationFailed)
+}
+
+// reusable 判断已有链接能否直接作为本次创建的结果：目的地相同且双方都没有附加选项
+// 带选项(如密码)的链接总是单独分配短码，避免不同人的设置互相覆盖
+func reusable(existing *storage.Link, candidate storage.Link) bool {
+	return existing.LongURL == candidate.LongURL &&
+		existing.PasswordHash == "" && candidate.PasswordHash == "" &&
+		existing.MaxVisits == 0 && candidate.MaxVisits == 0 &&
+		!scheduled(existing) && !scheduled(&candidate) &&
+		len(existing.Rules) == 0 && len(candidate.Rules) == 0 &&
+		len(existing.Variants) == 0 && len(candidate.Variants) == 0 &&
+		!existing.Template && !candidate.Template &&
+		!existing.Prefix && !candidate.Prefix &&
+		existing.QueryMode == candidate.QueryMode &&
+		existing.Group == candidate.Group && existing.TaggingPolicy == candidate.TaggingPolicy &&
+		existing.RedirectType == candidate.RedirectType
+}
+
+func scheduled(link *storage.Link) bool {
+	return !link.NotBefore.IsZero() || !link.NotAfter.IsZero()
+}
+
+// Visit 描述一次跳转请求
+type Visit struct {
+	ShortCode string
+	// Unlocked 表示调用方已经验证过访问密码(例如请求携带了有效的解锁 cookie)
+	Unlocked bool
+	// Target 定向规则可见的请求属性，Time 为零值时使用服务的当前时间
+	Target targeting.Request
+	// Variant 访问者之前被分配到的 A/B 变体(例如来自 cookie)，仍然有效时沿用
+	Variant string
+	// ClientID 标识访问者的稳定字符串，没有 Variant 时用它的哈希分配变体
+	ClientID string
+	// Query 请求自带的查询参数，用于模板填充与透传
+	Query url.Values
+	// PathSegments 短码之后的路径段，用于模板填充
+	PathSegments []string
+}
+
+// Redirect 是一次跳转请求的解析结果
+type Redirect struct {
+	URL string
+	// Variant 本次分配的 A/B 变体，链接没有变体或由定向规则决定目的地时为空
+	Variant string
+	// Broken 目的地址已被健康检查标记为失效，调用方可以改为展示提示页
+	Broken bool
+	// Status 跳转使用的 HTTP 状态码
+	Status int
+	// MaxAge 大于 0 时浏览器与 CDN 可以缓存本次跳转这么久，临时跳转总是 0
+	MaxAge time.Duration
+	// Targeted 目的地由定向规则根据请求头选择
+	Targeted bool
+	// Personal 结果取决于访问者的 cookie(密码解锁、A/B 变体)，共享缓存不能保存
+	Personal bool
 }
 
+// GetAndTrackLongURL 返回短码对应的长链接并记录一次访问，是 Resolve 在无额外上下文时的简写
 func (s *Service) GetAndTrackLongURL(ctx context.Context, shortCode string) (string, error) {
-	if len(shortCode) < s.minShortCodeLen {
-		return "", ErrShortCodeTooShort
+	redirect, err := s.Resolve(ctx, Visit{ShortCode: shortCode})
+	if err != nil {
+		return "", err
 	}
-	link, err := s.store.FindByShortCode(ctx, shortCode)
+	return redirect.URL, nil
+}
+
+// Resolve 解析一次跳转并记录访问
+// 受密码保护且未解锁的链接返回 ErrPasswordRequired，此时不计入访问次数；
+// 达到访问上限的链接返回 ErrLinkExhausted(或配置的兜底地址)；
+// 生效前返回 ErrLinkNotYetActive(或预告页)，过期后返回 ErrLinkExpired，两者都不计入访问次数
+func (s *Service) Resolve(ctx context.Context, visit Visit) (*Redirect, error) {
+	shortCode := visit.ShortCode
+	link, err := s.findLink(ctx, shortCode)
 	if err != nil {
-		s.logger.Printf("INFO: Short code not found in store. ShortCode: %s\n", shortCode)
-		if errors.Is(err, storage.ErrNotFound) {
-			return "", fmt.Errorf("for code '%s': %w", shortCode, ErrLinkNotFound)
+		return nil, err
+	}
+	now := s.now()
+	if !link.NotBefore.IsZero() && now.Before(link.NotBefore) {
+		if link.TeaserURL != "" {
+			return temporaryRedirect(link.TeaserURL), nil
 		}
+		return nil, fmt.Errorf("for code '%s': active from %s: %w", shortCode, link.NotBefore.Format(time.RFC3339), ErrLinkNotYetActive)
+	}
+	if !link.NotAfter.IsZero() && !now.Before(link.NotAfter) {
+		return nil, fmt.Errorf("for code '%s': expired at %s: %w", shortCode, link.NotAfter.Format(time.RFC3339), ErrLinkExpired)
+	}
+	// 已经失效的链接无需再要求输入密码；这里只是提前判断，真正的计数以 ConsumeVisit 为准
+	if link.MaxVisits > 0 && link.VisitCount >= link.MaxVisits {
+		return s.exhausted(shortCode)
 	}
+	if link.PasswordHash != "" && !visit.Unlocked {
+		return nil, fmt.Errorf("for code '%s': %w", shortCode, ErrPasswordRequired)
+	}
+	if visit.Target.Time.IsZero() {
+		visit.Target.Time = now
+	}
+	destination, variant := s.destination(link, visit)
+	if destination, err = buildDestination(link, destination, visit); err != nil {
+		return nil, err
+	}
+	destination = s.tagAtRedirect(link, destination)
+	if s.policyOnRedirect {
+		if err := s.checkDestination(destination, "redirect"); err != nil {
+			return nil, revoked(err)
+		}
+	}
+
+	if link.MaxVisits > 0 {
+		// 限次链接的检查与计数必须在存储层原子完成，否则并发访问会超出上限
+		consumed, err := s.store.ConsumeVisit(ctx, shortCode)
+		if errors.Is(err, storage.ErrVisitLimitReached) {
+			return s.exhausted(shortCode)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("for code '%s': failed to record visit: %w", shortCode, storageError(err))
+		}
+		s.logger.Printf("INFO: Limited visit recorded. ShortCode: %s, Visits: %d/%d\n", shortCode, consumed.VisitCount, consumed.MaxVisits)
+		s.countVariantVisit(ctx, shortCode, variant)
+		return s.redirectTo(link, destination, variant), nil
+	}
+	s.countVariantVisit(ctx, shortCode, variant)
 
 	go func(sc string, currentCount int64) {
 		bgCtx := context.Background()
@@ -125,7 +525,113 @@ func (s *Service) GetAndTrackLongURL(ctx context.Context, shortCode string) (str
 		log.Printf("INFO: Visit count incremented successfully.ShortCode: %s,CurrentCount:%d", sc, currentCount+1)
 	}(shortCode, link.VisitCount)
 
-	return link.LongURL, nil
+	return s.redirectTo(link, destination, variant), nil
+}
+
+func broken(link *storage.Link) bool {
+	return link.Health != nil && link.Health.Broken
+}
+
+// Health 返回链接目的地址最近一次健康检查的结果，从未检查过时返回 nil
+func (s *Service) Health(ctx context.Context, shortCode string) (*storage.Health, error) {
+	link, err := s.findLink(ctx, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	return link.Health, nil
+}
+
+// destination 选择本次跳转的目的地：命中的定向规则优先，其次是 A/B 变体，最后是 LongURL
+func (s *Service) destination(link *storage.Link, visit Visit) (longURL, variant string) {
+	if match := s.matchRules(link, visit.Target); match.Index >= 0 {
+		return match.LongURL, ""
+	}
+	if v := assignVariant(link, visit.Variant, visit.ClientID); v != nil {
+		return v.LongURL, v.Name
+	}
+	return link.LongURL, ""
+}
+
+// countVariantVisit 同步记录变体的访问，保证实验数据与实际跳转一致；计数失败不影响跳转
+func (s *Service) countVariantVisit(ctx context.Context, shortCode, variant string) {
+	if variant == "" {
+		return
+	}
+	if err := s.countVariant(ctx, shortCode, variant, func(v *storage.Variant) { v.Visits++ }); err != nil {
+		s.logger.Printf("ERROR: Failed to count variant visit. ShortCode: %s, Variant: %s, Error: %v\n", shortCode, variant, err)
+	}
+}
+
+func (s *Service) exhausted(shortCode string) (*Redirect, error) {
+	if s.exhaustedFallbackURL != "" {
+		return temporaryRedirect(s.exhaustedFallbackURL), nil
+	}
+	return nil, fmt.Errorf("for code '%s': %w", shortCode, ErrLinkExhausted)
+}
+
+// findLink 按短码查找链接，并把存储层错误转换为业务错误
+// 自定义短码可以短于生成的短码，因此长度只在未找到时用于区分 ErrShortCodeTooShort
+func (s *Service) findLink(ctx context.Context, shortCode string) (*storage.Link, error) {
+	if shortCode == "" {
+		return nil, ErrShortCodeTooShort
+	}
+	link, err := s.store.FindByShortCode(ctx, shortCode)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			if len(shortCode) < s.minShortCodeLen {
+				return nil, ErrShortCodeTooShort
+			}
+			s.logger.Printf("INFO: Short code not found in store. ShortCode: %s\n", shortCode)
+			return nil, fmt.Errorf("for code '%s': %w", shortCode, ErrLinkNotFound)
+		}
+		return nil, fmt.Errorf("for code '%s': failed to find link: %w", shortCode, storageError(err))
+	}
+	return link, nil
+}
+
+// resolveDestination 完成创建前的全部目的地检查，返回应当保存的长链接
+func (s *Service) resolveDestination(ctx context.Context, raw string) (string, error) {
+	longURL, err := s.normalizeLongURL(raw)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkDestination(longURL, "create"); err != nil {
+		return "", err
+	}
+	final, err := s.checkChain(ctx, longURL)
+	if err != nil {
+		return "", err
+	}
+	if final == longURL {
+		return longURL, nil
+	}
+	// 最终目的地同样需要满足校验与策略
+	if final, err = s.normalizeLongURL(final); err != nil {
+		return "", err
+	}
+	if err := s.checkDestination(final, "create"); err != nil {
+		return "", err
+	}
+	return final, nil
+}
+
+func (s *Service) normalizeLongURL(raw string) (string, error) {
+	return NormalizeLongURL(s.normalizer, raw)
+}
+
+// NormalizeLongURL 按 normalizer 校验并规范化长链接(IDN 转 punycode、去除跟踪参数等)，
+// 结果即确定性短码哈希的内容。离线计算短码的 shortlink idgen code 与 Service 共用这一步，对同一输入得到同一短码；
+// 开启 SHORTLINK_CHAIN_STORE_FINAL 时服务改为哈希跳转链的最终目的地，离线无法复现
+func NormalizeLongURL(normalizer *urlnorm.Normalizer, raw string) (string, error) {
+	normalized, err := normalizer.Normalize(raw)
+	if err != nil {
+		var nerr *urlnorm.Error
+		if errors.As(err, &nerr) {
+			return "", &InvalidURLError{Reason: nerr.Reason, Err: err}
+		}
+		return "", fmt.Errorf("%w: %w", ErrInvalidLongURL, err)
+	}
+	return normalized, nil
 }
 
 func preview(s string, maxLen int) string {
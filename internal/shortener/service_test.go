@@ -0,0 +1,260 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"shortlink/internal/idgen"
+	"shortlink/internal/policy"
+	"shortlink/internal/storage"
+	"shortlink/internal/tagging"
+)
+
+func newTestService(t *testing.T, store storage.Storer, gen idgen.Generator) *Service {
+	t.Helper()
+	return NewService(Config{
+		Store:     store,
+		Generator: gen,
+		Logger:    log.New(io.Discard, "", 0),
+	})
+}
+
+func TestService_CreateShortLink_Deterministic(t *testing.T) {
+	ctx := context.Background()
+	gen, err := idgen.NewDeterministicGenerator([]byte("test-key"), 7)
+	if err != nil {
+		t.Fatalf("NewDeterministicGenerator() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		// preSeedURL 在 salt 0 对应的短码上预先放置的链接，模拟冲突
+		preSeedURL string
+		longURL    string
+		wantSalt   int
+	}{
+		{
+			name:     "fresh URL gets salt 0 code",
+			longURL:  "https://example.com/a",
+			wantSalt: 0,
+		},
+		{
+			name:       "repeated URL reuses existing code",
+			preSeedURL: "https://example.com/a",
+			longURL:    "https://example.com/a",
+			wantSalt:   0,
+		},
+		{
+			name:       "collision with another URL moves to salt 1",
+			preSeedURL: "https://other.example.com/",
+			longURL:    "https://example.com/a",
+			wantSalt:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewMemoryStore()
+			svc := newTestService(t, store, gen)
+
+			if tt.preSeedURL != "" {
+				code0, err := gen.GenerateSaltedShortCode(ctx, tt.longURL, 0)
+				if err != nil {
+					t.Fatalf("GenerateSaltedShortCode() error = %v", err)
+				}
+				if err := store.Save(ctx, storage.Link{ShortCode: code0, LongURL: tt.preSeedURL}); err != nil {
+					t.Fatalf("seed data failed: %v", err)
+				}
+			}
+
+			want, err := gen.GenerateSaltedShortCode(ctx, tt.longURL, tt.wantSalt)
+			if err != nil {
+				t.Fatalf("GenerateSaltedShortCode() error = %v", err)
+			}
+			got, err := svc.CreateShortLink(ctx, tt.longURL)
+			if err != nil {
+				t.Fatalf("CreateShortLink() error = %v", err)
+			}
+			if got != want {
+				t.Errorf("CreateShortLink() = %q, want %q (salt %d)", got, want, tt.wantSalt)
+			}
+		})
+	}
+}
+
+// TestService_CreateShortLink_DeterministicIgnoresTagging 确定性短码取决于打标之前的目的地，
+// 同一目的地无论属于哪个分组、应用哪个打标策略，第一个候选短码都相同
+func TestService_CreateShortLink_DeterministicIgnoresTagging(t *testing.T) {
+	ctx := context.Background()
+	gen, err := idgen.NewDeterministicGenerator([]byte("test-key"), 7)
+	if err != nil {
+		t.Fatalf("NewDeterministicGenerator() error = %v", err)
+	}
+	set, err := tagging.Parse(strings.NewReader(testTaggingPolicies))
+	if err != nil {
+		t.Fatalf("tagging.Parse() error = %v", err)
+	}
+	const longURL = "https://example.com/a"
+	want, err := gen.GenerateSaltedShortCode(ctx, longURL, 0)
+	if err != nil {
+		t.Fatalf("GenerateSaltedShortCode() error = %v", err)
+	}
+
+	for _, params := range []CreateParams{
+		{LongURL: longURL},
+		{LongURL: longURL, Group: "growth"},
+		{LongURL: longURL, TaggingPolicy: "partner"},
+	} {
+		svc := NewService(Config{
+			Store:     storage.NewMemoryStore(),
+			Generator: gen,
+			Logger:    log.New(io.Discard, "", 0),
+			Tagging:   set,
+		})
+		link, err := svc.Create(ctx, params)
+		if err != nil {
+			t.Fatalf("Create(%+v) error = %v", params, err)
+		}
+		if link.ShortCode != want {
+			t.Errorf("Create(%+v) code = %q (stored %q), want %q", params, link.ShortCode, link.LongURL, want)
+		}
+	}
+}
+
+func TestService_DestinationPolicy(t *testing.T) {
+	ctx := context.Background()
+	pol, err := policy.Parse(strings.NewReader("deny .phish.example\ndeny 10.0.0.0/8\n"))
+	if err != nil {
+		t.Fatalf("policy.Parse() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		longURL string
+		wantErr error
+	}{
+		{name: "allowed destination", longURL: "https://example.com/", wantErr: nil},
+		{name: "denied by suffix rule", longURL: "https://login.phish.example/", wantErr: ErrDestinationBlocked},
+		{name: "denied by cidr rule", longURL: "http://10.0.0.5:8080/admin", wantErr: ErrDestinationBlocked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewService(Config{
+				Store:     storage.NewMemoryStore(),
+				Generator: idgen.NewGenerator(),
+				Logger:    log.New(io.Discard, "", 0),
+				Policy:    pol,
+			})
+			_, err := svc.CreateShortLink(ctx, tt.longURL)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateShortLink(%q) error = %v, wantErr = %v", tt.longURL, err, tt.wantErr)
+			}
+			var blocked *BlockedDestinationError
+			if tt.wantErr != nil && (!errors.As(err, &blocked) || blocked.Decision.Rule == "") {
+				t.Errorf("CreateShortLink(%q) error does not carry the matching rule: %v", tt.longURL, err)
+			}
+		})
+	}
+}
+
+func TestService_DestinationPolicyOnRedirect(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	// 链接在策略生效前已存在
+	if err := store.Save(ctx, storage.Link{ShortCode: "legacy1", LongURL: "https://login.phish.example/"}); err != nil {
+		t.Fatalf("seed data failed: %v", err)
+	}
+	pol, err := policy.Parse(strings.NewReader("deny .phish.example\n"))
+	if err != nil {
+		t.Fatalf("policy.Parse() error = %v", err)
+	}
+
+	for _, onRedirect := range []bool{false, true} {
+		svc := NewService(Config{
+			Store:                 store,
+			Generator:             idgen.NewGenerator(),
+			Logger:                log.New(io.Discard, "", 0),
+			Policy:                pol,
+			CheckPolicyOnRedirect: onRedirect,
+		})
+		_, err := svc.GetAndTrackLongURL(ctx, "legacy1")
+		if got := errors.Is(err, ErrDestinationBlocked); got != onRedirect {
+			t.Errorf("CheckPolicyOnRedirect=%v: GetAndTrackLongURL() error = %v, blocked = %v, want %v", onRedirect, err, got, onRedirect)
+		}
+	}
+}
+
+func TestService_Resolve_VisitLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxVisits  int64
+		fallback   string
+		visits     int
+		wantURLs   []string
+		wantLastOK bool
+	}{
+		{
+			name:      "one-time link",
+			maxVisits: 1,
+			visits:    2,
+			wantURLs:  []string{"https://example.com/reset"},
+		},
+		{
+			name:      "exhausted link falls through to fallback",
+			maxVisits: 2,
+			fallback:  "https://example.com/expired",
+			visits:    3,
+			wantURLs:  []string{"https://example.com/reset", "https://example.com/reset", "https://example.com/expired"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := storage.NewMemoryStore()
+			svc := NewService(Config{
+				Store:                store,
+				Generator:            idgen.NewGenerator(),
+				Logger:               log.New(io.Discard, "", 0),
+				ExhaustedFallbackURL: tt.fallback,
+			})
+			link, err := svc.Create(ctx, CreateParams{LongURL: "https://example.com/reset", MaxVisits: tt.maxVisits})
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			var got []string
+			for i := 0; i < tt.visits; i++ {
+				redirect, err := svc.Resolve(ctx, Visit{ShortCode: link.ShortCode})
+				if errors.Is(err, ErrLinkExhausted) {
+					continue
+				}
+				if err != nil {
+					t.Fatalf("Resolve() visit %d error = %v", i+1, err)
+				}
+				got = append(got, redirect.URL)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantURLs, ",") {
+				t.Errorf("Resolve() URLs = %v, want %v", got, tt.wantURLs)
+			}
+			stored, err := store.FindByShortCode(ctx, link.ShortCode)
+			if err != nil {
+				t.Fatalf("FindByShortCode() error = %v", err)
+			}
+			if stored.VisitCount != tt.maxVisits {
+				t.Errorf("VisitCount = %d, want %d", stored.VisitCount, tt.maxVisits)
+			}
+		})
+	}
+
+	_, err := newTestService(t, storage.NewMemoryStore(), idgen.NewGenerator()).
+		Create(context.Background(), CreateParams{LongURL: "https://example.com/", MaxVisits: -1})
+	if !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Create() with negative MaxVisits error = %v, want ErrInvalidOption", err)
+	}
+}
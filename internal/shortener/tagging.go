@@ -0,0 +1,101 @@
+package shortener
+
+import (
+	"fmt"
+	"regexp"
+
+	"shortlink/internal/storage"
+	"shortlink/internal/tagging"
+)
+
+var groupNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+
+// TaggingPreview 是打标策略作用到长链接上的结果
+type TaggingPreview struct {
+	LongURL  string         `json:"long_url"`
+	FinalURL string         `json:"final_url"`
+	Policy   tagging.Policy `json:"policy"`
+}
+
+// PreviewTagging 返回长链接在指定策略(或分组的默认策略)下最终跳转到的地址，不创建链接
+// 无论策略在创建还是跳转时生效，返回的都是访问者最终看到的地址
+func (s *Service) PreviewTagging(longURL, group, policyName string) (*TaggingPreview, error) {
+	normalized, err := s.normalizeLongURL(longURL)
+	if err != nil {
+		return nil, fieldError("long_url", err)
+	}
+	policy, err := s.taggingPolicy(group, policyName)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		return nil, fieldError("group", fmt.Errorf("%w: group %q has no tagging policy", ErrInvalidOption, group))
+	}
+	return &TaggingPreview{LongURL: normalized, FinalURL: policy.Tag(normalized), Policy: *policy}, nil
+}
+
+// taggingPolicy 返回链接适用的打标策略：显式指定的策略优先，其次是分组的默认策略，都没有时返回 nil
+func (s *Service) taggingPolicy(group, name string) (*tagging.Policy, error) {
+	if group != "" && !groupNamePattern.MatchString(group) {
+		return nil, fieldError("group", fmt.Errorf("%w: group %q must be 1-64 letters, digits, '.', '-' or '_'", ErrInvalidOption, group))
+	}
+	if name != "" {
+		p, ok := s.tagging.Lookup(name)
+		if !ok {
+			return nil, fieldError("tagging_policy", fmt.Errorf("%w: unknown tagging policy %q", ErrInvalidOption, name))
+		}
+		return &p, nil
+	}
+	if p, ok := s.tagging.ForGroup(group); ok {
+		return &p, nil
+	}
+	return nil, nil
+}
+
+// tagAtCreate 把创建阶段生效的策略写入链接的全部目的地(主地址、定向规则与变体)
+func tagAtCreate(policy *tagging.Policy, link *storage.Link) {
+	if policy == nil || policy.Stage != tagging.AtCreate {
+		return
+	}
+	link.LongURL = policy.Tag(link.LongURL)
+	for i := range link.Rules {
+		link.Rules[i].LongURL = policy.Tag(link.Rules[i].LongURL)
+	}
+	for i := range link.Variants {
+		link.Variants[i].LongURL = policy.Tag(link.Variants[i].LongURL)
+	}
+}
+
+// tagAtRedirect 在跳转时应用链接的打标策略，策略已从文件中删除时跳过并记录日志
+func (s *Service) tagAtRedirect(link *storage.Link, destination string) string {
+	policy, err := s.redirectPolicy(link)
+	if err != nil {
+		s.logger.Printf("WARN: Tagging policy unavailable, redirecting untagged. ShortCode: %s, Error: %v\n", link.ShortCode, err)
+		return destination
+	}
+	if policy == nil {
+		return destination
+	}
+	return policy.Tag(destination)
+}
+
+// redirectPolicy 返回链接在跳转阶段生效的打标签策略，没有时为 nil
+func (s *Service) redirectPolicy(link *storage.Link) (*tagging.Policy, error) {
+	if link.Group == "" && link.TaggingPolicy == "" {
+		return nil, nil
+	}
+	policy, err := s.taggingPolicy(link.Group, link.TaggingPolicy)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil || policy.Stage != tagging.AtRedirect {
+		return nil, nil
+	}
+	return policy, nil
+}
+
+// tagsAtRedirect 判断链接的目的地是否在跳转时打标签；策略暂时不可用时也按打标签处理，策略恢复后立即生效
+func (s *Service) tagsAtRedirect(link *storage.Link) bool {
+	policy, err := s.redirectPolicy(link)
+	return err != nil || policy != nil
+}
@@ -0,0 +1,118 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/url"
+	"strings"
+	"testing"
+
+	"shortlink/internal/idgen"
+	"shortlink/internal/storage"
+	"shortlink/internal/tagging"
+)
+
+const testTaggingPolicies = `
+policy spring  utm_source=newsletter utm_campaign=spring
+policy partner apply=redirect existing=overwrite utm_source=partner
+group  growth  spring
+group  partners partner
+`
+
+func newTaggingService(t *testing.T) *Service {
+	t.Helper()
+	set, err := tagging.Parse(strings.NewReader(testTaggingPolicies))
+	if err != nil {
+		t.Fatalf("tagging.Parse() error = %v", err)
+	}
+	return NewService(Config{
+		Store:     storage.NewMemoryStore(),
+		Generator: idgen.NewGenerator(),
+		Logger:    log.New(io.Discard, "", 0),
+		Tagging:   set,
+	})
+}
+
+func TestService_Tagging(t *testing.T) {
+	tests := []struct {
+		name       string
+		params     CreateParams
+		query      string
+		wantStored string
+		wantURL    string
+		wantErr    error
+	}{
+		{name: "no group", params: CreateParams{LongURL: "https://example.com/a"}, wantStored: "https://example.com/a", wantURL: "https://example.com/a"},
+		{name: "group without policy", params: CreateParams{LongURL: "https://example.com/a", Group: "sales"}, wantStored: "https://example.com/a", wantURL: "https://example.com/a"},
+		{name: "applied at create", params: CreateParams{LongURL: "https://example.com/a?utm_source=blog", Group: "growth"}, wantStored: "https://example.com/a?utm_source=blog&utm_campaign=spring", wantURL: "https://example.com/a?utm_source=blog&utm_campaign=spring"},
+		{name: "applied at redirect", params: CreateParams{LongURL: "https://example.com/a?utm_source=blog", Group: "partners"}, wantStored: "https://example.com/a?utm_source=blog", wantURL: "https://example.com/a?utm_source=partner"},
+		{name: "redirect stage overrides passed query", params: CreateParams{LongURL: "https://example.com/a", Group: "partners", QueryMode: QueryMerge}, query: "utm_source=x&id=1", wantStored: "https://example.com/a", wantURL: "https://example.com/a?id=1&utm_source=partner"},
+		{name: "explicit policy wins over group", params: CreateParams{LongURL: "https://example.com/a", Group: "partners", TaggingPolicy: "spring"}, wantStored: "https://example.com/a?utm_source=newsletter&utm_campaign=spring", wantURL: "https://example.com/a?utm_source=newsletter&utm_campaign=spring"},
+		{name: "unknown policy", params: CreateParams{LongURL: "https://example.com/a", TaggingPolicy: "autumn"}, wantErr: ErrInvalidOption},
+		{name: "invalid group", params: CreateParams{LongURL: "https://example.com/a", Group: "a b"}, wantErr: ErrInvalidOption},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc := newTaggingService(t)
+			link, err := svc.Create(ctx, tt.params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Create() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if link.LongURL != tt.wantStored {
+				t.Errorf("stored LongURL = %q, want %q", link.LongURL, tt.wantStored)
+			}
+			query, _ := url.ParseQuery(tt.query)
+			redirect, err := svc.Resolve(ctx, Visit{ShortCode: link.ShortCode, Query: query})
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if redirect.URL != tt.wantURL {
+				t.Errorf("Resolve() URL = %q, want %q", redirect.URL, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestService_Tagging_RulesVariantsAndUpdates(t *testing.T) {
+	ctx := context.Background()
+	svc := newTaggingService(t)
+	link, err := svc.Create(ctx, CreateParams{
+		LongURL: "https://example.com/",
+		Group:   "growth",
+		Rules:   []storage.RedirectRule{{Condition: `platform == "ios"`, LongURL: "https://example.com/ios"}},
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if got := link.Rules[0].LongURL; got != "https://example.com/ios?utm_source=newsletter&utm_campaign=spring" {
+		t.Errorf("rule LongURL = %q", got)
+	}
+	updated, err := svc.UpdateDestination(ctx, link.ShortCode, "https://example.com/new", "alice")
+	if err != nil {
+		t.Fatalf("UpdateDestination() error = %v", err)
+	}
+	if updated.LongURL != "https://example.com/new?utm_source=newsletter&utm_campaign=spring" {
+		t.Errorf("updated LongURL = %q", updated.LongURL)
+	}
+}
+
+func TestService_PreviewTagging(t *testing.T) {
+	svc := newTaggingService(t)
+	preview, err := svc.PreviewTagging("HTTPS://Example.com/a", "partners", "")
+	if err != nil {
+		t.Fatalf("PreviewTagging() error = %v", err)
+	}
+	if preview.LongURL != "https://example.com/a" || preview.FinalURL != "https://example.com/a?utm_source=partner" || preview.Policy.Name != "partner" {
+		t.Errorf("PreviewTagging() = %+v", preview)
+	}
+	if _, err := svc.PreviewTagging("https://example.com/", "sales", ""); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("PreviewTagging(group without policy) error = %v, want %v", err, ErrInvalidOption)
+	}
+}
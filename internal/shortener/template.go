@@ -0,0 +1,196 @@
+package shortener
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"shortlink/internal/storage"
+	"strings"
+)
+
+// 查询参数透传方式，决定跳转时如何处理短链接请求自带的查询参数
+const (
+	// QueryDrop 丢弃请求的查询参数(默认)
+	QueryDrop = "drop"
+	// QueryAppend 把请求的查询参数追加到目的地址已有参数之后，同名参数两者都保留
+	QueryAppend = "append"
+	// QueryMerge 合并查询参数，同名参数以请求中的值为准
+	QueryMerge = "merge"
+)
+
+// maxTemplateValueLen 单个模板占位符取值的最大长度
+const maxTemplateValueLen = 256
+
+var ErrTemplateValue = newError(KindInvalid, "invalid_template_value", "invalid or missing template value")
+
+var placeholderPattern = regexp.MustCompile(`\{([a-zA-Z0-9_]+)\}`)
+
+// TemplateValueError 描述模板占位符无法填充的原因，errors.Is(err, ErrTemplateValue) 对其成立
+type TemplateValueError struct {
+	Name   string
+	Reason string
+}
+
+func (e *TemplateValueError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("shortener: template: %s", e.Reason)
+	}
+	return fmt.Sprintf("shortener: template value {%s}: %s", e.Name, e.Reason)
+}
+
+func (e *TemplateValueError) Unwrap() error {
+	return ErrTemplateValue
+}
+
+func validQueryMode(mode string) bool {
+	return mode == "" || mode == QueryDrop || mode == QueryAppend || mode == QueryMerge
+}
+
+// buildDestination 把模板、前缀路径与查询参数透传应用到选定的目的地上
+// 只有默认目的地(LongURL)按模板填充；前缀链接把短码之后的路径段拼接到目的地之后；
+// 其他链接不能携带多余的路径段
+func buildDestination(link *storage.Link, destination string, visit Visit) (string, error) {
+	var consumed map[string]bool
+	var err error
+	switch {
+	case link.Template && destination == link.LongURL:
+		destination, consumed, err = expandTemplate(destination, visit.Query, visit.PathSegments)
+	case link.Prefix:
+		destination, err = appendPath(destination, visit.PathSegments)
+	case len(visit.PathSegments) > 0:
+		err = fmt.Errorf("path /%s: %w", strings.Join(visit.PathSegments, "/"), ErrLinkNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("for code '%s': %w", link.ShortCode, err)
+	}
+	return passQuery(destination, link.QueryMode, visit.Query, consumed)
+}
+
+// validateTemplate 检查目的地模板：占位符只能出现在路径、查询与片段中，
+// 用示例值填充后的 URL 必须通过与普通长链接相同的校验与策略检查
+func (s *Service) validateTemplate(tmpl string) error {
+	if !placeholderPattern.MatchString(tmpl) {
+		return fmt.Errorf("%w: template has no {placeholders}", ErrInvalidOption)
+	}
+	if scheme, rest, ok := strings.Cut(tmpl, "://"); ok {
+		authority := rest
+		if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+			authority = rest[:i]
+		}
+		if strings.ContainsAny(scheme+authority, "{}") {
+			return fmt.Errorf("%w: placeholders are not allowed in the scheme or host", ErrInvalidOption)
+		}
+	}
+	if strings.Count(tmpl, "{") != len(placeholderPattern.FindAllString(tmpl, -1)) || strings.Count(tmpl, "}") != strings.Count(tmpl, "{") {
+		return fmt.Errorf("%w: malformed template placeholder", ErrInvalidOption)
+	}
+	sample := placeholderPattern.ReplaceAllString(tmpl, "x")
+	normalized, err := s.normalizeLongURL(sample)
+	if err != nil {
+		return err
+	}
+	if err := s.checkDestination(normalized, "create"); err != nil {
+		return err
+	}
+	// 模板的具体地址在跳转时才确定，不做链路解析，只检查主机本身
+	u, err := url.Parse(normalized)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidLongURL, err)
+	}
+	if s.publicHosts.contains(u.Hostname()) {
+		return fmt.Errorf("%w: %s points at this service", ErrRedirectLoop, u.Host)
+	}
+	if s.knownShorteners.contains(u.Hostname()) {
+		return fmt.Errorf("%w: %s is a known URL shortener", ErrRedirectChain, u.Hostname())
+	}
+	return nil
+}
+
+// expandTemplate 填充目的地模板
+// 占位符优先取同名查询参数，否则按出现顺序依次取短码之后的路径段；取值在路径中按路径段转义、
+// 在查询与片段中按查询参数转义，因此无法改变目的地的主机或路径层级。返回被模板消耗的查询参数名
+func expandTemplate(tmpl string, query url.Values, segments []string) (string, map[string]bool, error) {
+	queryStart := strings.IndexAny(tmpl, "?#")
+	consumed := map[string]bool{}
+	next := 0
+	var b strings.Builder
+	last := 0
+	for _, m := range placeholderPattern.FindAllStringSubmatchIndex(tmpl, -1) {
+		name := tmpl[m[2]:m[3]]
+		value := query.Get(name)
+		if value != "" {
+			consumed[name] = true
+		} else {
+			if next >= len(segments) {
+				return "", nil, &TemplateValueError{Name: name, Reason: "missing value"}
+			}
+			value = segments[next]
+			next++
+		}
+		if err := checkTemplateValue(name, value); err != nil {
+			return "", nil, err
+		}
+		b.WriteString(tmpl[last:m[0]])
+		if queryStart >= 0 && m[0] > queryStart {
+			b.WriteString(url.QueryEscape(value))
+		} else {
+			b.WriteString(url.PathEscape(value))
+		}
+		last = m[1]
+	}
+	b.WriteString(tmpl[last:])
+	if next < len(segments) {
+		return "", nil, &TemplateValueError{Reason: fmt.Sprintf("%d unexpected path segments", len(segments)-next)}
+	}
+	return b.String(), consumed, nil
+}
+
+func checkTemplateValue(name, value string) error {
+	if len(value) > maxTemplateValueLen {
+		return &TemplateValueError{Name: name, Reason: fmt.Sprintf("longer than %d bytes", maxTemplateValueLen)}
+	}
+	if value == "." || value == ".." {
+		return &TemplateValueError{Name: name, Reason: "dot segments are not allowed"}
+	}
+	for _, r := range value {
+		if r < 0x20 || r == 0x7f {
+			return &TemplateValueError{Name: name, Reason: "contains control characters"}
+		}
+	}
+	return nil
+}
+
+// passQuery 按 mode 把请求的查询参数带到目的地址上，skip 中的参数(已被模板消耗)不再透传
+func passQuery(destination, mode string, incoming url.Values, skip map[string]bool) (string, error) {
+	if mode == "" || mode == QueryDrop || len(incoming) == 0 {
+		return destination, nil
+	}
+	extra := url.Values{}
+	for k, vs := range incoming {
+		if !skip[k] {
+			extra[k] = vs
+		}
+	}
+	if len(extra) == 0 {
+		return destination, nil
+	}
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", fmt.Errorf("shortener: parse destination %q: %w", destination, err)
+	}
+	switch mode {
+	case QueryAppend:
+		if u.RawQuery == "" {
+			u.RawQuery = extra.Encode()
+		} else {
+			u.RawQuery += "&" + extra.Encode()
+		}
+	case QueryMerge:
+		merged := u.Query()
+		for k, vs := range extra {
+			merged[k] = vs
+		}
+		u.RawQuery = merged.Encode()
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,81 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"shortlink/internal/idgen"
+	"shortlink/internal/storage"
+)
+
+func TestService_Resolve_TemplateAndQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   CreateParams
+		query    string
+		segments []string
+		want     string
+		wantErr  error
+	}{
+		{name: "drop by default", params: CreateParams{LongURL: "https://example.com/a?x=1"}, query: "utm_source=mail", want: "https://example.com/a?x=1"},
+		{name: "append keeps both", params: CreateParams{LongURL: "https://example.com/a?x=1", QueryMode: QueryAppend}, query: "x=2&utm_source=mail", want: "https://example.com/a?x=1&utm_source=mail&x=2"},
+		{name: "append without existing query", params: CreateParams{LongURL: "https://example.com/a", QueryMode: QueryAppend}, query: "utm_source=mail", want: "https://example.com/a?utm_source=mail"},
+		{name: "merge overrides", params: CreateParams{LongURL: "https://example.com/a?x=1&y=2", QueryMode: QueryMerge}, query: "x=9", want: "https://example.com/a?x=9&y=2"},
+		{name: "path segments fill template", params: CreateParams{LongURL: "https://shop.example.com/p/{sku}", Template: true}, segments: []string{"A-100"}, want: "https://shop.example.com/p/A-100"},
+		{name: "query fills template by name", params: CreateParams{LongURL: "https://shop.example.com/p/{sku}?ref={ref}", Template: true}, query: "ref=news&sku=B7", want: "https://shop.example.com/p/B7?ref=news"},
+		{name: "query takes precedence over segments", params: CreateParams{LongURL: "https://shop.example.com/p/{sku}?ref={ref}", Template: true}, query: "ref=mail", segments: []string{"C3"}, want: "https://shop.example.com/p/C3?ref=mail"},
+		{name: "path value is escaped", params: CreateParams{LongURL: "https://shop.example.com/p/{sku}", Template: true}, query: "sku=a/../../admin", want: "https://shop.example.com/p/a%2F..%2F..%2Fadmin"},
+		{name: "query value is escaped", params: CreateParams{LongURL: "https://shop.example.com/?ref={ref}", Template: true}, query: "ref=a%26admin%3Dtrue", want: "https://shop.example.com/?ref=a%26admin%3Dtrue"},
+		{name: "consumed params are not passed through", params: CreateParams{LongURL: "https://shop.example.com/p/{sku}", Template: true, QueryMode: QueryAppend}, query: "sku=D4&utm_source=x", want: "https://shop.example.com/p/D4?utm_source=x"},
+		{name: "missing value", params: CreateParams{LongURL: "https://shop.example.com/p/{sku}", Template: true}, wantErr: ErrTemplateValue},
+		{name: "dot segment", params: CreateParams{LongURL: "https://shop.example.com/p/{sku}", Template: true}, segments: []string{".."}, wantErr: ErrTemplateValue},
+		{name: "too many segments", params: CreateParams{LongURL: "https://shop.example.com/p/{sku}", Template: true}, segments: []string{"a", "b"}, wantErr: ErrTemplateValue},
+		{name: "segments on plain link", params: CreateParams{LongURL: "https://example.com/"}, segments: []string{"a"}, wantErr: ErrLinkNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc := newTestService(t, storage.NewMemoryStore(), idgen.NewGenerator())
+			link, err := svc.Create(ctx, tt.params)
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			query, _ := url.ParseQuery(tt.query)
+			redirect, err := svc.Resolve(ctx, Visit{ShortCode: link.ShortCode, Query: query, PathSegments: tt.segments})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Resolve() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && redirect.URL != tt.want {
+				t.Errorf("Resolve() URL = %q, want %q", redirect.URL, tt.want)
+			}
+		})
+	}
+}
+
+func TestService_Create_InvalidTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  CreateParams
+		wantErr error
+	}{
+		{name: "no placeholders", params: CreateParams{LongURL: "https://example.com/", Template: true}, wantErr: ErrInvalidOption},
+		{name: "placeholder in host", params: CreateParams{LongURL: "https://{tenant}.example.com/", Template: true}, wantErr: ErrInvalidOption},
+		{name: "malformed placeholder", params: CreateParams{LongURL: "https://example.com/{sku", Template: true}, wantErr: ErrInvalidOption},
+		{name: "invalid placeholder name", params: CreateParams{LongURL: "https://example.com/{s-k}", Template: true}, wantErr: ErrInvalidOption},
+		{name: "scheme not allowed", params: CreateParams{LongURL: "javascript:alert('{x}')", Template: true}, wantErr: ErrInvalidLongURL},
+		{name: "unknown query mode", params: CreateParams{LongURL: "https://example.com/", QueryMode: "keep"}, wantErr: ErrInvalidOption},
+		{name: "template with variants", params: CreateParams{LongURL: "https://example.com/{x}", Template: true, Variants: []storage.Variant{{Name: "a", LongURL: "https://example.com/a", Weight: 1}}}, wantErr: ErrInvalidOption},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, storage.NewMemoryStore(), idgen.NewGenerator())
+			if _, err := svc.Create(context.Background(), tt.params); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Create() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
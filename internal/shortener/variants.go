@@ -0,0 +1,178 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"regexp"
+	"shortlink/internal/storage"
+)
+
+// MaxVariants 单个链接允许的 A/B 变体数量上限
+const MaxVariants = 10
+
+var ErrVariantNotFound = newError(KindNotFound, "variant_not_found", "variant not found")
+
+var variantNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,32}$`)
+
+// VariantStats 是一个变体的累计数据
+type VariantStats struct {
+	Name           string  `json:"name"`
+	LongURL        string  `json:"long_url"`
+	Weight         int     `json:"weight"`
+	Visits         int64   `json:"visits"`
+	Conversions    int64   `json:"conversions"`
+	ConversionRate float64 `json:"conversion_rate"`
+}
+
+// Variants 返回链接各变体的权重、访问数与转化数
+func (s *Service) Variants(ctx context.Context, shortCode string) ([]VariantStats, error) {
+	link, err := s.findLink(ctx, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	stats := make([]VariantStats, 0, len(link.Variants))
+	for _, v := range link.Variants {
+		st := VariantStats{Name: v.Name, LongURL: v.LongURL, Weight: v.Weight, Visits: v.Visits, Conversions: v.Conversions}
+		if v.Visits > 0 {
+			st.ConversionRate = float64(v.Conversions) / float64(v.Visits)
+		}
+		stats = append(stats, st)
+	}
+	return stats, nil
+}
+
+// SetVariantWeights 在线调整变体权重，未出现在 weights 中的变体保持原权重
+// 已经分配过变体的访问者(携带 cookie)不受影响，只有新访问者按新权重分配
+func (s *Service) SetVariantWeights(ctx context.Context, shortCode string, weights map[string]int) ([]VariantStats, error) {
+	if _, err := s.findLink(ctx, shortCode); err != nil {
+		return nil, err
+	}
+	_, err := s.store.Update(ctx, shortCode, func(link *storage.Link) error {
+		for name, weight := range weights {
+			i := variantIndex(link.Variants, name)
+			if i < 0 {
+				return fmt.Errorf("for code '%s': variant %q: %w", shortCode, name, ErrVariantNotFound)
+			}
+			link.Variants[i].Weight = weight
+		}
+		return validateWeights(link.Variants)
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("for code '%s': %w", shortCode, ErrLinkNotFound)
+		}
+		return nil, err
+	}
+	s.logger.Printf("INFO: Variant weights updated. ShortCode: %s, Weights: %v\n", shortCode, weights)
+	return s.Variants(ctx, shortCode)
+}
+
+// RecordConversion 为变体记录一次转化
+func (s *Service) RecordConversion(ctx context.Context, shortCode, variant string) error {
+	if _, err := s.findLink(ctx, shortCode); err != nil {
+		return err
+	}
+	return s.countVariant(ctx, shortCode, variant, func(v *storage.Variant) { v.Conversions++ })
+}
+
+// validateVariants 校验变体名称、权重与目的地，返回规范化后的变体
+func (s *Service) validateVariants(ctx context.Context, variants []storage.Variant) ([]storage.Variant, error) {
+	if len(variants) > MaxVariants {
+		return nil, fieldError("variants", fmt.Errorf("%w: at most %d variants are allowed", ErrInvalidOption, MaxVariants))
+	}
+	validated := make([]storage.Variant, 0, len(variants))
+	for i, v := range variants {
+		if !variantNamePattern.MatchString(v.Name) {
+			return nil, fieldError(fmt.Sprintf("variants[%d].name", i), fmt.Errorf("%w: variant name %q must be 1-32 letters, digits, '-' or '_'", ErrInvalidOption, v.Name))
+		}
+		if variantIndex(validated, v.Name) >= 0 {
+			return nil, fieldError(fmt.Sprintf("variants[%d].name", i), fmt.Errorf("%w: duplicate variant name %q", ErrInvalidOption, v.Name))
+		}
+		destination, err := s.resolveDestination(ctx, v.LongURL)
+		if err != nil {
+			return nil, fieldError(fmt.Sprintf("variants[%d].long_url", i), err)
+		}
+		validated = append(validated, storage.Variant{Name: v.Name, LongURL: destination, Weight: v.Weight})
+	}
+	if err := validateWeights(validated); err != nil {
+		return nil, err
+	}
+	return validated, nil
+}
+
+func validateWeights(variants []storage.Variant) error {
+	total := 0
+	for _, v := range variants {
+		if v.Weight < 0 {
+			return fmt.Errorf("%w: variant %q has a negative weight", ErrInvalidOption, v.Name)
+		}
+		total += v.Weight
+	}
+	if total == 0 {
+		return fmt.Errorf("%w: variant weights must not all be zero", ErrInvalidOption)
+	}
+	return nil
+}
+
+// assignVariant 为访问者选择变体
+// 优先沿用之前分配的变体(仍存在且权重大于 0)，否则按 clientID 的哈希在权重区间中定位，
+// 同一访问者在权重不变时总是得到同一个变体；clientID 为空时随机分配
+func assignVariant(link *storage.Link, previous, clientID string) *storage.Variant {
+	if i := variantIndex(link.Variants, previous); i >= 0 && link.Variants[i].Weight > 0 {
+		return &link.Variants[i]
+	}
+	total := 0
+	for _, v := range link.Variants {
+		total += v.Weight
+	}
+	if total <= 0 {
+		return nil
+	}
+	var point int
+	if clientID == "" {
+		point = rand.IntN(total)
+	} else {
+		h := fnv.New64a()
+		h.Write([]byte(link.ShortCode))
+		h.Write([]byte{0})
+		h.Write([]byte(clientID))
+		point = int(h.Sum64() % uint64(total))
+	}
+	for i := range link.Variants {
+		point -= link.Variants[i].Weight
+		if point < 0 {
+			return &link.Variants[i]
+		}
+	}
+	return nil
+}
+
+func (s *Service) countVariant(ctx context.Context, shortCode, variant string, inc func(*storage.Variant)) error {
+	_, err := s.store.Update(ctx, shortCode, func(link *storage.Link) error {
+		i := variantIndex(link.Variants, variant)
+		if i < 0 {
+			return fmt.Errorf("for code '%s': variant %q: %w", shortCode, variant, ErrVariantNotFound)
+		}
+		inc(&link.Variants[i])
+		return nil
+	})
+	if errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("for code '%s': %w", shortCode, ErrLinkNotFound)
+	}
+	return err
+}
+
+func variantIndex(variants []storage.Variant, name string) int {
+	if name == "" {
+		return -1
+	}
+	for i, v := range variants {
+		if v.Name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,152 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"shortlink/internal/idgen"
+	"shortlink/internal/storage"
+)
+
+func newVariantLink(t *testing.T, svc *Service, weightA, weightB int) *storage.Link {
+	t.Helper()
+	link, err := svc.Create(context.Background(), CreateParams{
+		LongURL: "https://example.com/",
+		Variants: []storage.Variant{
+			{Name: "a", LongURL: "https://example.com/landing-a", Weight: weightA},
+			{Name: "b", LongURL: "https://example.com/landing-b", Weight: weightB},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return link
+}
+
+func TestService_Resolve_VariantsWeightedAndSticky(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, storage.NewMemoryStore(), idgen.NewGenerator())
+	link := newVariantLink(t, svc, 80, 20)
+
+	const clients = 2000
+	counts := map[string]int{}
+	for i := 0; i < clients; i++ {
+		clientID := fmt.Sprintf("client-%d", i)
+		first, err := svc.Resolve(ctx, Visit{ShortCode: link.ShortCode, ClientID: clientID})
+		if err != nil {
+			t.Fatalf("Resolve() error = %v", err)
+		}
+		// 同一访问者再次访问得到同一变体
+		again, err := svc.Resolve(ctx, Visit{ShortCode: link.ShortCode, ClientID: clientID})
+		if err != nil {
+			t.Fatalf("Resolve() error = %v", err)
+		}
+		if again.Variant != first.Variant || again.URL != first.URL {
+			t.Fatalf("client %s got %q then %q, want sticky assignment", clientID, first.Variant, again.Variant)
+		}
+		counts[first.Variant]++
+	}
+	// 80/20 权重，允许 ±5% 的偏差
+	if share := float64(counts["a"]) / clients; share < 0.75 || share > 0.85 {
+		t.Errorf("variant a share = %.3f (%v), want about 0.80", share, counts)
+	}
+
+	stats, err := svc.Variants(ctx, link.ShortCode)
+	if err != nil {
+		t.Fatalf("Variants() error = %v", err)
+	}
+	if got := stats[0].Visits + stats[1].Visits; got != 2*clients {
+		t.Errorf("total variant visits = %d, want %d", got, 2*clients)
+	}
+}
+
+func TestService_VariantWeightsAndConversions(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, storage.NewMemoryStore(), idgen.NewGenerator())
+	link := newVariantLink(t, svc, 50, 50)
+
+	// 之前分配到 b 的访问者在权重调整后仍然看到 b
+	if _, err := svc.SetVariantWeights(ctx, link.ShortCode, map[string]int{"a": 100, "b": 1}); err != nil {
+		t.Fatalf("SetVariantWeights() error = %v", err)
+	}
+	redirect, err := svc.Resolve(ctx, Visit{ShortCode: link.ShortCode, Variant: "b"})
+	if err != nil || redirect.Variant != "b" {
+		t.Fatalf("Resolve() with previous variant b = %+v, %v", redirect, err)
+	}
+	// 暂停 b 后，即使之前分配到 b 也改为 a
+	if _, err := svc.SetVariantWeights(ctx, link.ShortCode, map[string]int{"b": 0}); err != nil {
+		t.Fatalf("SetVariantWeights() error = %v", err)
+	}
+	redirect, err = svc.Resolve(ctx, Visit{ShortCode: link.ShortCode, Variant: "b"})
+	if err != nil || redirect.Variant != "a" || redirect.URL != "https://example.com/landing-a" {
+		t.Fatalf("Resolve() with paused variant = %+v, %v, want a", redirect, err)
+	}
+
+	if err := svc.RecordConversion(ctx, link.ShortCode, "a"); err != nil {
+		t.Fatalf("RecordConversion() error = %v", err)
+	}
+	stats, err := svc.Variants(ctx, link.ShortCode)
+	if err != nil {
+		t.Fatalf("Variants() error = %v", err)
+	}
+	want := []VariantStats{
+		{Name: "a", LongURL: "https://example.com/landing-a", Weight: 100, Visits: 1, Conversions: 1, ConversionRate: 1},
+		{Name: "b", LongURL: "https://example.com/landing-b", Weight: 0, Visits: 1},
+	}
+	for i := range want {
+		if stats[i] != want[i] {
+			t.Errorf("Variants()[%d] = %+v, want %+v", i, stats[i], want[i])
+		}
+	}
+
+	tests := []struct {
+		name    string
+		run     func() error
+		wantErr error
+	}{
+		{name: "unknown variant weight", run: func() error {
+			_, err := svc.SetVariantWeights(ctx, link.ShortCode, map[string]int{"c": 1})
+			return err
+		}, wantErr: ErrVariantNotFound},
+		{name: "all weights zero", run: func() error {
+			_, err := svc.SetVariantWeights(ctx, link.ShortCode, map[string]int{"a": 0})
+			return err
+		}, wantErr: ErrInvalidOption},
+		{name: "unknown variant conversion", run: func() error {
+			return svc.RecordConversion(ctx, link.ShortCode, "c")
+		}, wantErr: ErrVariantNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestService_Create_InvalidVariants(t *testing.T) {
+	tests := []struct {
+		name     string
+		variants []storage.Variant
+		wantErr  error
+	}{
+		{name: "duplicate name", variants: []storage.Variant{{Name: "a", LongURL: "https://example.com/1", Weight: 1}, {Name: "a", LongURL: "https://example.com/2", Weight: 1}}, wantErr: ErrInvalidOption},
+		{name: "invalid name", variants: []storage.Variant{{Name: "a b", LongURL: "https://example.com/1", Weight: 1}}, wantErr: ErrInvalidOption},
+		{name: "negative weight", variants: []storage.Variant{{Name: "a", LongURL: "https://example.com/1", Weight: -1}}, wantErr: ErrInvalidOption},
+		{name: "zero total weight", variants: []storage.Variant{{Name: "a", LongURL: "https://example.com/1"}}, wantErr: ErrInvalidOption},
+		{name: "invalid destination", variants: []storage.Variant{{Name: "a", LongURL: "ftp://example.com/", Weight: 1}}, wantErr: ErrInvalidLongURL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, storage.NewMemoryStore(), idgen.NewGenerator())
+			_, err := svc.Create(context.Background(), CreateParams{LongURL: "https://example.com/", Variants: tt.variants})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Create() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
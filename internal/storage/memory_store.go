@@ -2,6 +2,8 @@ package storage
 
 import (
 	"context"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 )
@@ -25,7 +27,10 @@ func (s *MemoryStore) Save(ctx context.Context, link Link) error {
 		return ErrShortCodeExists
 	}
 
-	link.CreatedAt = time.Now()
+	// 调用方(Service)按自己的时钟设置创建时间，未设置时才使用当前时间
+	if link.CreatedAt.IsZero() {
+		link.CreatedAt = time.Now()
+	}
 	s.links[link.ShortCode] = &link
 
 	return nil
@@ -36,7 +41,9 @@ func (s *MemoryStore) FindByShortCode(ctx context.Context, shortCode string) (*L
 	defer s.mu.RUnlock()
 
 	if link, ok := s.links[shortCode]; ok {
-		return link, nil
+		// 返回副本，调用方持有的 Link 不会与后续的计数更新产生数据竞争
+		found := *link
+		return &found, nil
 	}
 
 	return nil, ErrNotFound
@@ -55,6 +62,81 @@ func (s *MemoryStore) IncrementVisitCount(ctx context.Context, shortCode string)
 	return ErrNotFound
 }
 
+func (s *MemoryStore) ConsumeVisit(ctx context.Context, shortCode string) (*Link, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, ok := s.links[shortCode]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if link.MaxVisits > 0 && link.VisitCount >= link.MaxVisits {
+		return nil, ErrVisitLimitReached
+	}
+	link.VisitCount++
+	consumed := *link
+	return &consumed, nil
+}
+
+func (s *MemoryStore) Update(ctx context.Context, shortCode string, fn func(link *Link) error) (*Link, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, ok := s.links[shortCode]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	// 在副本上修改，fn 失败时存储中的数据保持不变
+	updated := cloneLink(link)
+	if err := fn(&updated); err != nil {
+		return nil, err
+	}
+	updated.ShortCode = shortCode
+	s.links[shortCode] = &updated
+	result := updated
+	return &result, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, shortCode string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.links[shortCode]; !ok {
+		return ErrNotFound
+	}
+	delete(s.links, shortCode)
+	return nil
+}
+
+func (s *MemoryStore) List(ctx context.Context) ([]Link, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	links := make([]Link, 0, len(s.links))
+	for _, link := range s.links {
+		links = append(links, cloneLink(link))
+	}
+	slices.SortFunc(links, func(a, b Link) int { return strings.Compare(a.ShortCode, b.ShortCode) })
+	return links, nil
+}
+
+// cloneLink 深拷贝 Link 的切片字段，避免修改与已返回给调用方的副本共享底层数组
+func cloneLink(link *Link) Link {
+	c := *link
+	c.Rules = slices.Clone(link.Rules)
+	c.Variants = slices.Clone(link.Variants)
+	c.History = slices.Clone(link.History)
+	if link.Metadata != nil {
+		m := *link.Metadata
+		c.Metadata = &m
+	}
+	if link.Health != nil {
+		h := *link.Health
+		c.Health = &h
+	}
+	return c
+}
+
 func (log *MemoryStore) Close() error {
 	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -331,3 +332,190 @@ func TestMemoryStore_Save_ConcurrentSameShortCode(t *testing.T) {
 		t.Error("Concurrent save: no link was saved")
 	}
 }
+
+func TestMemoryStore_ConsumeVisit(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxVisits int64
+		visits    int
+		wantOK    int
+	}{
+		{name: "unlimited link never exhausts", maxVisits: 0, visits: 10, wantOK: 10},
+		{name: "one-time link", maxVisits: 1, visits: 3, wantOK: 1},
+		{name: "limited link", maxVisits: 3, visits: 5, wantOK: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			if err := store.Save(context.Background(), Link{ShortCode: "abc123", LongURL: "https://example.com", MaxVisits: tt.maxVisits}); err != nil {
+				t.Fatalf("seed data failed: %v", err)
+			}
+			ok := 0
+			for i := 0; i < tt.visits; i++ {
+				link, err := store.ConsumeVisit(context.Background(), "abc123")
+				if errors.Is(err, ErrVisitLimitReached) {
+					continue
+				}
+				if err != nil {
+					t.Fatalf("ConsumeVisit() error = %v", err)
+				}
+				ok++
+				if link.VisitCount != int64(ok) {
+					t.Errorf("ConsumeVisit().VisitCount = %d, want %d", link.VisitCount, ok)
+				}
+			}
+			if ok != tt.wantOK {
+				t.Errorf("successful visits = %d, want %d", ok, tt.wantOK)
+			}
+		})
+	}
+
+	if _, err := NewMemoryStore().ConsumeVisit(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ConsumeVisit(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryStore_ConsumeVisit_Concurrent(t *testing.T) {
+	store := NewMemoryStore()
+	const maxVisits = 5
+	if err := store.Save(context.Background(), Link{ShortCode: "burn", LongURL: "https://example.com", MaxVisits: maxVisits}); err != nil {
+		t.Fatalf("seed data failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	successCount := 0
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.ConsumeVisit(context.Background(), "burn"); err == nil {
+				mu.Lock()
+				successCount++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 并发访问也不能超过上限
+	if successCount != maxVisits {
+		t.Errorf("concurrent ConsumeVisit successes = %d, want %d", successCount, maxVisits)
+	}
+}
+
+func TestMemoryStore_Update(t *testing.T) {
+	errRejected := errors.New("rejected")
+	tests := []struct {
+		name        string
+		code        string
+		fn          func(*Link) error
+		wantErr     error
+		wantLongURL string
+	}{
+		{
+			name:        "applies change",
+			code:        "abc123",
+			fn:          func(l *Link) error { l.LongURL = "https://example.com/new"; return nil },
+			wantLongURL: "https://example.com/new",
+		},
+		{
+			name: "fn error leaves link unchanged",
+			code: "abc123",
+			fn: func(l *Link) error {
+				l.LongURL = "https://example.com/new"
+				return errRejected
+			},
+			wantErr:     errRejected,
+			wantLongURL: "https://example.com",
+		},
+		{
+			name:        "missing code",
+			code:        "missing",
+			fn:          func(l *Link) error { return nil },
+			wantErr:     ErrNotFound,
+			wantLongURL: "https://example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			if err := store.Save(context.Background(), Link{ShortCode: "abc123", LongURL: "https://example.com"}); err != nil {
+				t.Fatalf("seed data failed: %v", err)
+			}
+			_, err := store.Update(context.Background(), tt.code, tt.fn)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Update() error = %v, want %v", err, tt.wantErr)
+			}
+			link, _ := store.FindByShortCode(context.Background(), "abc123")
+			if link.LongURL != tt.wantLongURL {
+				t.Errorf("LongURL after Update() = %q, want %q", link.LongURL, tt.wantLongURL)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_List(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	for _, code := range []string{"ccc", "aaa", "bbb"} {
+		if err := store.Save(ctx, Link{ShortCode: code, LongURL: "https://example.com/" + code, Rules: []RedirectRule{{Condition: "true", LongURL: "https://example.com/r"}}}); err != nil {
+			t.Fatalf("seed data failed: %v", err)
+		}
+	}
+
+	links, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	var codes []string
+	for _, link := range links {
+		codes = append(codes, link.ShortCode)
+	}
+	if strings.Join(codes, ",") != "aaa,bbb,ccc" {
+		t.Errorf("List() codes = %v, want [aaa bbb ccc]", codes)
+	}
+
+	// 返回的是副本，修改不影响存储中的数据
+	links[0].Rules[0].LongURL = "https://evil.example"
+	stored, _ := store.FindByShortCode(ctx, "aaa")
+	if stored.Rules[0].LongURL != "https://example.com/r" {
+		t.Errorf("List() shares data with the store: %q", stored.Rules[0].LongURL)
+	}
+}
+
+func TestMemoryStore_Delete(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    string
+		wantErr error
+	}{
+		{name: "existing code", code: "abc123"},
+		{name: "missing code", code: "missing", wantErr: ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := NewMemoryStore()
+			if err := store.Save(ctx, Link{ShortCode: "abc123", LongURL: "https://example.com"}); err != nil {
+				t.Fatalf("seed data failed: %v", err)
+			}
+			if err := store.Delete(ctx, tt.code); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Delete() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if _, err := store.FindByShortCode(ctx, tt.code); !errors.Is(err, ErrNotFound) {
+				t.Errorf("FindByShortCode() after Delete() error = %v, want %v", err, ErrNotFound)
+			}
+			// 删除后短码可以重新使用
+			if err := store.Save(ctx, Link{ShortCode: tt.code, LongURL: "https://example.com/new"}); err != nil {
+				t.Errorf("Save() after Delete() error = %v", err)
+			}
+		})
+	}
+}
@@ -14,6 +14,93 @@ type Link struct {
 	// 访问次数，处理层需要处理并发更新问题
 	VisitCount int64
 	CreatedAt  time.Time
+	// PasswordHash 访问密码的 bcrypt 哈希，为空表示链接不受保护
+	PasswordHash string
+	// MaxVisits 允许的最大访问次数，0 表示不限制；达到上限后链接失效(阅后即焚)
+	MaxVisits int64
+	// NotBefore/NotAfter 链接的生效时间窗口，零值表示该侧不限制
+	NotBefore time.Time
+	NotAfter  time.Time
+	// TeaserURL 在 NotBefore 之前访问时跳转的预告页，为空时提示链接尚未生效
+	TeaserURL string
+	// Rules 按顺序求值的定向规则，第一条命中的规则决定目的地，都不命中时使用 LongURL
+	Rules []RedirectRule
+	// Variants A/B 测试的加权目的地，非空时(且没有规则命中)按权重分配，LongURL 不再使用
+	Variants []Variant
+	// Template 为 true 时 LongURL 是含 {占位符} 的模板，跳转时用查询参数或多余的路径段填充
+	Template bool
+	// Prefix 为 true 时短码之后的路径段会拼接到目的地址之后，如 /docs/a/b -> https://docs.internal/a/b
+	Prefix bool
+	// QueryMode 跳转时如何处理请求自带的查询参数：drop(默认)、append 或 merge
+	QueryMode string
+	// Group 链接所属的分组(如活动名)，用于选择默认的 UTM 打标策略
+	Group string
+	// TaggingPolicy 显式指定的打标策略名，为空时使用分组的默认策略
+	TaggingPolicy string
+	// RedirectType 跳转使用的 HTTP 状态码(301/302/307/308)，0 表示使用服务的默认类型
+	RedirectType int
+	// UpdatedAt/UpdatedBy 记录当前目的地的设置时间与操作人，从未修改过时 UpdatedAt 为零值
+	UpdatedAt time.Time
+	UpdatedBy string
+	// History 按时间顺序保存被替换掉的历史目的地，只追加不修改
+	History []Revision
+	// Metadata 目的页面的预览信息，创建或修改目的地后异步抓取，尚未抓取时为 nil
+	Metadata *Metadata
+	// Health 最近一次健康检查的结果，从未检查过时为 nil
+	Health *Health
+}
+
+// RedirectRule 是一条定向跳转规则，Condition 使用 targeting 包的表达式语法
+type RedirectRule struct {
+	Condition string
+	LongURL   string
+}
+
+// Variant 是 A/B 测试的一个目的地及其累计数据
+type Variant struct {
+	Name    string
+	LongURL string
+	// Weight 相对权重，0 表示暂停向新访问者分配该变体
+	Weight      int
+	Visits      int64
+	Conversions int64
+}
+
+// Metadata 是抓取目的页面得到的预览信息
+type Metadata struct {
+	Title       string
+	Description string
+	Favicon     string
+	Image       string
+	FetchedAt   time.Time
+	// Error 抓取失败的原因，成功时为空
+	Error string
+}
+
+// Health 是目的地址的健康检查状态
+type Health struct {
+	// StatusCode 最近一次检查的 HTTP 状态码，请求失败(超时、拒绝连接等)时为 0
+	StatusCode int
+	Latency    time.Duration
+	CheckedAt  time.Time
+	// LastSuccess 最近一次检查成功的时间，从未成功时为零值
+	LastSuccess time.Time
+	// Failures 连续失败次数，成功后清零
+	Failures int
+	// Broken 连续失败达到阈值后置为 true，下一次成功后恢复
+	Broken bool
+	// Error 最近一次失败的原因
+	Error string
+	// NextCheckAt 下一次检查的时间，连续失败时按指数退避推迟
+	NextCheckAt time.Time
+}
+
+// Revision 是链接目的地的一个历史版本
+type Revision struct {
+	LongURL string
+	// SetAt/SetBy 该目的地被设置的时间与操作人
+	SetAt time.Time
+	SetBy string
 }
 
 // Storer 数据存储层需要提供的核心能力
@@ -27,11 +114,23 @@ type Storer interface {
 	// IncrementVisitCount 原子增加短码的访问次数，如果shortCode不存在，可以返回 ErrNotFound，或者静默失败，取决于具体业务需求
 	// 此方法必须是并发安全的
 	IncrementVisitCount(ctx context.Context, shortCode string) error
+	// ConsumeVisit 在同一个原子操作中检查 MaxVisits 并增加访问次数，返回增加后的 Link
+	// 访问次数已达上限时返回 ErrVisitLimitReached 且不修改计数，shortCode 不存在时返回 ErrNotFound
+	ConsumeVisit(ctx context.Context, shortCode string) (*Link, error)
+	// Update 在同一个原子操作中读取链接、调用 fn 修改并保存，返回修改后的 Link
+	// fn 收到的是深拷贝，可以直接修改其中的切片；fn 返回错误时不做任何修改并原样返回该错误，
+	// shortCode 不存在时返回 ErrNotFound
+	Update(ctx context.Context, shortCode string, fn func(link *Link) error) (*Link, error)
+	// Delete 删除短码对应的链接，shortCode 不存在时返回 ErrNotFound
+	Delete(ctx context.Context, shortCode string) error
+	// List 按短码顺序返回全部链接的副本，供后台任务(如健康检查)遍历
+	List(ctx context.Context) ([]Link, error)
 	// Close 关闭并释放存储层占用的资源(如果数据库连接池)，应确保幂等性，多次调用 Close 不会产生副作用
 	Close() error
 }
 
 var (
-	ErrNotFound        = errors.New("storage: link not found")
-	ErrShortCodeExists = errors.New("storage: short code already exists")
+	ErrNotFound          = errors.New("storage: link not found")
+	ErrShortCodeExists   = errors.New("storage: short code already exists")
+	ErrVisitLimitReached = errors.New("storage: visit limit reached")
 )
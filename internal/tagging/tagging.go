@@ -0,0 +1,237 @@
+package tagging
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Stage 决定策略在什么时候作用到目的地址上
+type Stage string
+
+const (
+	// AtCreate 创建(或修改目的地)时写入长链接，保存下来的地址已经带有参数
+	AtCreate Stage = "create"
+	// AtRedirect 跳转时才追加，修改策略文件后对存量链接立即生效
+	AtRedirect Stage = "redirect"
+)
+
+// Existing 决定长链接上已有的同名参数如何处理
+type Existing string
+
+const (
+	// Keep 保留长链接上已有的值，只补充缺失的参数
+	Keep Existing = "keep"
+	// Overwrite 用策略中的值覆盖已有的值
+	Overwrite Existing = "overwrite"
+)
+
+var (
+	namePattern  = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+	paramPattern = regexp.MustCompile(`^utm_[a-z0-9_]{1,32}$`)
+)
+
+// Param 是策略写入的一个查询参数
+type Param struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Policy 是一条命名的 UTM 打标策略
+type Policy struct {
+	Name     string   `json:"name"`
+	Stage    Stage    `json:"apply"`
+	Existing Existing `json:"existing"`
+	Params   []Param  `json:"params"`
+	Line     int      `json:"-"`
+}
+
+// Tag 把策略参数写入 rawURL 的查询串，按文件中的顺序追加在已有参数之后
+// 只改写查询串本身，已有参数保持原样与原顺序，因此目的地模板中的 {占位符} 也不受影响
+func (p Policy) Tag(rawURL string) string {
+	base, fragment, hasFragment := strings.Cut(rawURL, "#")
+	base, query, _ := strings.Cut(base, "?")
+
+	present := map[string]bool{}
+	var kept []string
+	for _, pair := range strings.Split(query, "&") {
+		if pair == "" {
+			continue
+		}
+		key, _, _ := strings.Cut(pair, "=")
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		if p.has(key) {
+			if p.Existing == Overwrite {
+				continue
+			}
+			present[key] = true
+		}
+		kept = append(kept, pair)
+	}
+	for _, param := range p.Params {
+		if !present[param.Key] {
+			kept = append(kept, url.QueryEscape(param.Key)+"="+url.QueryEscape(param.Value))
+		}
+	}
+
+	tagged := base
+	if len(kept) > 0 {
+		tagged += "?" + strings.Join(kept, "&")
+	}
+	if hasFragment {
+		tagged += "#" + fragment
+	}
+	return tagged
+}
+
+func (p Policy) has(key string) bool {
+	for _, param := range p.Params {
+		if param.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Set 是策略文件中定义的全部策略及链接分组的默认策略，构建后只读，可并发使用
+type Set struct {
+	policies map[string]Policy
+	groups   map[string]string
+}
+
+// Lookup 按名称查找策略
+func (s *Set) Lookup(name string) (Policy, bool) {
+	if s == nil {
+		return Policy{}, false
+	}
+	p, ok := s.policies[name]
+	return p, ok
+}
+
+// ForGroup 返回链接分组的默认策略
+func (s *Set) ForGroup(group string) (Policy, bool) {
+	if s == nil {
+		return Policy{}, false
+	}
+	name, ok := s.groups[group]
+	if !ok {
+		return Policy{}, false
+	}
+	return s.Lookup(name)
+}
+
+// Parse 解析打标策略文件，格式为每行一条定义：
+//
+//	# 注释
+//	policy <name> [apply=create|redirect] [existing=keep|overwrite] utm_<key>=<value> ...
+//	group  <group> <policy>
+//
+// apply 缺省为 create，existing 缺省为 keep；参数值按查询参数转义书写(空格写作 %20)。
+// group 行为链接分组指定默认策略，被引用的策略必须在文件中定义(前后位置不限)
+func Parse(r io.Reader) (*Set, error) {
+	s := &Set{policies: map[string]Policy{}, groups: map[string]string{}}
+	groupLines := map[string]int{}
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "policy":
+			p, err := parsePolicy(fields[1:], lineNo)
+			if err != nil {
+				return nil, err
+			}
+			if prev, ok := s.policies[p.Name]; ok {
+				return nil, fmt.Errorf("tagging: line %d: policy %q already defined on line %d", lineNo, p.Name, prev.Line)
+			}
+			s.policies[p.Name] = p
+		case "group":
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("tagging: line %d: want \"group <group> <policy>\", got %q", lineNo, line)
+			}
+			if !namePattern.MatchString(fields[1]) {
+				return nil, fmt.Errorf("tagging: line %d: invalid group name %q", lineNo, fields[1])
+			}
+			if prev, ok := groupLines[fields[1]]; ok {
+				return nil, fmt.Errorf("tagging: line %d: group %q already assigned on line %d", lineNo, fields[1], prev)
+			}
+			s.groups[fields[1]] = fields[2]
+			groupLines[fields[1]] = lineNo
+		default:
+			return nil, fmt.Errorf("tagging: line %d: unknown directive %q", lineNo, fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("tagging: read policies: %w", err)
+	}
+	for group, name := range s.groups {
+		if _, ok := s.policies[name]; !ok {
+			return nil, fmt.Errorf("tagging: line %d: group %q uses undefined policy %q", groupLines[group], group, name)
+		}
+	}
+	return s, nil
+}
+
+func parsePolicy(fields []string, lineNo int) (Policy, error) {
+	if len(fields) == 0 || !namePattern.MatchString(fields[0]) {
+		return Policy{}, fmt.Errorf("tagging: line %d: want \"policy <name> key=value ...\" with a name of letters, digits, '.', '-' or '_'", lineNo)
+	}
+	p := Policy{Name: fields[0], Stage: AtCreate, Existing: Keep, Line: lineNo}
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return Policy{}, fmt.Errorf("tagging: line %d: want key=value, got %q", lineNo, field)
+		}
+		switch {
+		case key == "apply":
+			p.Stage = Stage(value)
+			if p.Stage != AtCreate && p.Stage != AtRedirect {
+				return Policy{}, fmt.Errorf("tagging: line %d: unknown apply stage %q", lineNo, value)
+			}
+		case key == "existing":
+			p.Existing = Existing(value)
+			if p.Existing != Keep && p.Existing != Overwrite {
+				return Policy{}, fmt.Errorf("tagging: line %d: unknown existing mode %q", lineNo, value)
+			}
+		case paramPattern.MatchString(key):
+			if p.has(key) {
+				return Policy{}, fmt.Errorf("tagging: line %d: duplicate parameter %q", lineNo, key)
+			}
+			v, err := url.QueryUnescape(value)
+			if err != nil || v == "" {
+				return Policy{}, fmt.Errorf("tagging: line %d: invalid value for %s", lineNo, key)
+			}
+			p.Params = append(p.Params, Param{Key: key, Value: v})
+		default:
+			return Policy{}, fmt.Errorf("tagging: line %d: unknown key %q, parameters must start with utm_", lineNo, key)
+		}
+	}
+	if len(p.Params) == 0 {
+		return Policy{}, fmt.Errorf("tagging: line %d: policy %q sets no utm_ parameters", lineNo, p.Name)
+	}
+	return p, nil
+}
+
+// LoadFile 读取并解析打标策略文件
+func LoadFile(path string) (*Set, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("tagging: open %s: %w", path, err)
+	}
+	defer f.Close()
+	return Parse(f)
+}
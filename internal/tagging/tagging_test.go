@@ -0,0 +1,87 @@
+package tagging
+
+import (
+	"strings"
+	"testing"
+)
+
+const testPolicies = `# 春季活动
+policy spring   utm_source=newsletter utm_medium=email utm_campaign=spring_sale
+policy strict   apply=redirect existing=overwrite utm_source=partner utm_campaign=q3%20launch
+group  growth   spring
+`
+
+func TestPolicy_Tag(t *testing.T) {
+	set, err := Parse(strings.NewReader(testPolicies))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	spring, _ := set.Lookup("spring")
+	strict, _ := set.Lookup("strict")
+
+	tests := []struct {
+		name   string
+		policy Policy
+		rawURL string
+		want   string
+	}{
+		{name: "no query", policy: spring, rawURL: "https://example.com/a", want: "https://example.com/a?utm_source=newsletter&utm_medium=email&utm_campaign=spring_sale"},
+		{name: "existing params kept in order", policy: spring, rawURL: "https://example.com/a?b=2&a=1", want: "https://example.com/a?b=2&a=1&utm_source=newsletter&utm_medium=email&utm_campaign=spring_sale"},
+		{name: "keep existing value", policy: spring, rawURL: "https://example.com/?utm_source=blog", want: "https://example.com/?utm_source=blog&utm_medium=email&utm_campaign=spring_sale"},
+		{name: "overwrite existing value", policy: strict, rawURL: "https://example.com/?utm_source=blog&x=1", want: "https://example.com/?x=1&utm_source=partner&utm_campaign=q3+launch"},
+		{name: "fragment preserved", policy: strict, rawURL: "https://example.com/p#top", want: "https://example.com/p?utm_source=partner&utm_campaign=q3+launch#top"},
+		{name: "template placeholders untouched", policy: strict, rawURL: "https://shop.example.com/p/{sku}?ref={ref}", want: "https://shop.example.com/p/{sku}?ref={ref}&utm_source=partner&utm_campaign=q3+launch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Tag(tt.rawURL); got != tt.want {
+				t.Errorf("Tag(%q) = %q, want %q", tt.rawURL, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSet_ForGroup(t *testing.T) {
+	set, err := Parse(strings.NewReader(testPolicies))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if p, ok := set.ForGroup("growth"); !ok || p.Name != "spring" || p.Stage != AtCreate || p.Existing != Keep {
+		t.Errorf("ForGroup(growth) = %+v, %v", p, ok)
+	}
+	if _, ok := set.ForGroup("sales"); ok {
+		t.Error("ForGroup(sales) found a policy, want none")
+	}
+	var empty *Set
+	if _, ok := empty.Lookup("spring"); ok {
+		t.Error("nil Set Lookup() found a policy")
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{name: "unknown directive", input: "rule x utm_source=a", wantErr: "line 1: unknown directive"},
+		{name: "no params", input: "policy empty apply=create", wantErr: "sets no utm_ parameters"},
+		{name: "non utm param", input: "policy p ref=abc", wantErr: "must start with utm_"},
+		{name: "bad stage", input: "policy p apply=later utm_source=a", wantErr: "unknown apply stage"},
+		{name: "bad existing mode", input: "policy p existing=merge utm_source=a", wantErr: "unknown existing mode"},
+		{name: "duplicate param", input: "policy p utm_source=a utm_source=b", wantErr: "duplicate parameter"},
+		{name: "duplicate policy", input: "policy p utm_source=a\npolicy p utm_source=b", wantErr: "line 2: policy \"p\" already defined on line 1"},
+		{name: "undefined policy", input: "group growth missing", wantErr: "uses undefined policy"},
+		{name: "malformed group", input: "group growth", wantErr: "want \"group <group> <policy>\""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
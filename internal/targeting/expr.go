@@ -0,0 +1,366 @@
+// Package targeting 实现定向跳转规则使用的小型表达式语言
+//
+// 表达式只能比较请求的固定属性，不支持函数调用、变量赋值或循环，求值时间与表达式长度成正比，
+// 因此可以安全地执行用户提交的规则。语法：
+//
+//	expr       = or
+//	or         = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | "(" expr ")" | comparison
+//	comparison = field op value | field ["not"] "in" list
+//	op         = "==" | "!=" | "contains" | "<" | "<=" | ">" | ">="
+//	list       = "[" value { "," value } "]"
+//	value      = string | integer
+//
+// 字段见 Fields，字符串比较不区分大小写，例如：
+//
+//	platform == "ios" && lang in ["de", "de-at"]
+//	country != "US" || (hour >= 9 && hour < 17)
+package targeting
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxExprLen 单条表达式的最大长度
+const MaxExprLen = 1024
+
+// Fields 列出表达式可以引用的请求属性及其类型
+var Fields = map[string]fieldKind{
+	"platform": kindString, // ios, android, windows, macos, linux, other
+	"lang":     kindString, // 首选语言，如 de 或 de-at；"de" 同样匹配 de-at
+	"country":  kindString, // 国家代码，来自 CountryResolver
+	"referrer": kindString, // Referer 的主机名
+	"hour":     kindInt,    // 0-23
+	"weekday":  kindString, // mon, tue, wed, thu, fri, sat, sun
+}
+
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindInt
+)
+
+// Request 是规则求值时可见的请求属性
+type Request struct {
+	Platform string
+	Language string
+	Country  string
+	Referrer string
+	Time     time.Time
+}
+
+// Expr 是编译后的表达式，可并发求值
+type Expr struct {
+	src  string
+	root node
+}
+
+// String 返回表达式源码
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Match 判断请求是否满足表达式
+func (e *Expr) Match(req Request) bool {
+	return e.root.eval(req)
+}
+
+// SyntaxError 描述表达式无法编译的原因及位置(字节偏移)
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("targeting: %s at offset %d", e.Msg, e.Pos)
+}
+
+// Compile 解析并检查表达式，未知字段、类型不匹配等错误在此阶段返回 *SyntaxError
+func Compile(src string) (*Expr, error) {
+	if len(src) > MaxExprLen {
+		return nil, &SyntaxError{Pos: MaxExprLen, Msg: fmt.Sprintf("expression longer than %d bytes", MaxExprLen)}
+	}
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %q", tok.text)}
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+type node interface {
+	eval(req Request) bool
+}
+
+type orNode struct{ left, right node }
+
+func (n orNode) eval(req Request) bool { return n.left.eval(req) || n.right.eval(req) }
+
+type andNode struct{ left, right node }
+
+func (n andNode) eval(req Request) bool { return n.left.eval(req) && n.right.eval(req) }
+
+type notNode struct{ operand node }
+
+func (n notNode) eval(req Request) bool { return !n.operand.eval(req) }
+
+type comparison struct {
+	field string
+	op    string
+	strs  []string
+	ints  []int
+}
+
+func (c comparison) eval(req Request) bool {
+	if Fields[c.field] == kindInt {
+		return c.evalInt(fieldInt(req, c.field))
+	}
+	return c.evalString(fieldString(req, c.field))
+}
+
+func (c comparison) evalString(v string) bool {
+	switch c.op {
+	case "==":
+		return c.equal(v, c.strs[0])
+	case "!=":
+		return !c.equal(v, c.strs[0])
+	case "contains":
+		return strings.Contains(v, c.strs[0])
+	case "in", "not in":
+		found := false
+		for _, s := range c.strs {
+			if c.equal(v, s) {
+				found = true
+				break
+			}
+		}
+		return found == (c.op == "in")
+	}
+	return false
+}
+
+// equal 比较字符串，lang 字段允许用基础语言匹配地区变体(de 匹配 de-at)
+func (c comparison) equal(v, want string) bool {
+	if v == want {
+		return true
+	}
+	return c.field == "lang" && strings.HasPrefix(v, want+"-")
+}
+
+func (c comparison) evalInt(v int) bool {
+	switch c.op {
+	case "==":
+		return v == c.ints[0]
+	case "!=":
+		return v != c.ints[0]
+	case "<":
+		return v < c.ints[0]
+	case "<=":
+		return v <= c.ints[0]
+	case ">":
+		return v > c.ints[0]
+	case ">=":
+		return v >= c.ints[0]
+	case "in", "not in":
+		found := false
+		for _, n := range c.ints {
+			if v == n {
+				found = true
+				break
+			}
+		}
+		return found == (c.op == "in")
+	}
+	return false
+}
+
+func fieldString(req Request, field string) string {
+	switch field {
+	case "platform":
+		return strings.ToLower(req.Platform)
+	case "lang":
+		return strings.ToLower(req.Language)
+	case "country":
+		return strings.ToLower(req.Country)
+	case "referrer":
+		return strings.ToLower(req.Referrer)
+	case "weekday":
+		return strings.ToLower(req.Time.Weekday().String()[:3])
+	}
+	return ""
+}
+
+func fieldInt(req Request, field string) int {
+	if field == "hour" {
+		return req.Time.Hour()
+	}
+	return 0
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokAnd {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokNot:
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand}, nil
+	case tokLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, &SyntaxError{Pos: closing.pos, Msg: "expected )"}
+		}
+		return inner, nil
+	case tokIdent:
+		return p.parseComparison(tok)
+	case tokEOF:
+		return nil, &SyntaxError{Pos: tok.pos, Msg: "unexpected end of expression"}
+	}
+	return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %q", tok.text)}
+}
+
+func (p *parser) parseComparison(field token) (node, error) {
+	kind, ok := Fields[field.text]
+	if !ok {
+		return nil, &SyntaxError{Pos: field.pos, Msg: fmt.Sprintf("unknown field %q", field.text)}
+	}
+	opTok := p.next()
+	op := opTok.text
+	switch {
+	case opTok.kind == tokOp:
+	case opTok.kind == tokIdent && (op == "in" || op == "contains"):
+	case opTok.kind == tokIdent && op == "not":
+		if in := p.next(); in.kind != tokIdent || in.text != "in" {
+			return nil, &SyntaxError{Pos: in.pos, Msg: `expected "in" after "not"`}
+		}
+		op = "not in"
+	default:
+		return nil, &SyntaxError{Pos: opTok.pos, Msg: fmt.Sprintf("expected operator after %s", field.text)}
+	}
+	switch op {
+	case "<", "<=", ">", ">=":
+		if kind != kindInt {
+			return nil, &SyntaxError{Pos: opTok.pos, Msg: fmt.Sprintf("operator %s needs a numeric field", op)}
+		}
+	case "contains":
+		if kind != kindString {
+			return nil, &SyntaxError{Pos: opTok.pos, Msg: "operator contains needs a string field"}
+		}
+	}
+
+	var values []token
+	if op == "in" || op == "not in" {
+		list, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		values = list
+	} else {
+		values = []token{p.next()}
+	}
+
+	c := comparison{field: field.text, op: op}
+	for _, v := range values {
+		switch {
+		case kind == kindString && v.kind == tokString:
+			c.strs = append(c.strs, strings.ToLower(v.text))
+		case kind == kindInt && v.kind == tokNumber:
+			n, err := strconv.Atoi(v.text)
+			if err != nil {
+				return nil, &SyntaxError{Pos: v.pos, Msg: fmt.Sprintf("invalid number %q", v.text)}
+			}
+			c.ints = append(c.ints, n)
+		case v.kind == tokEOF:
+			return nil, &SyntaxError{Pos: v.pos, Msg: "unexpected end of expression"}
+		default:
+			want := "string"
+			if kind == kindInt {
+				want = "number"
+			}
+			return nil, &SyntaxError{Pos: v.pos, Msg: fmt.Sprintf("field %s needs a %s, got %q", field.text, want, v.text)}
+		}
+	}
+	return c, nil
+}
+
+func (p *parser) parseList() ([]token, error) {
+	if open := p.next(); open.kind != tokLBracket {
+		return nil, &SyntaxError{Pos: open.pos, Msg: "expected ["}
+	}
+	var values []token
+	for {
+		values = append(values, p.next())
+		sep := p.next()
+		if sep.kind == tokRBracket {
+			return values, nil
+		}
+		if sep.kind != tokComma {
+			return nil, &SyntaxError{Pos: sep.pos, Msg: "expected , or ]"}
+		}
+	}
+}
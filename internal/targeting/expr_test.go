@@ -0,0 +1,150 @@
+package targeting
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompile_Match(t *testing.T) {
+	// 2030-03-04 是周一
+	monday10 := time.Date(2030, 3, 4, 10, 30, 0, 0, time.UTC)
+	iosDE := Request{Platform: "ios", Language: "de-at", Country: "AT", Referrer: "news.google.com", Time: monday10}
+	androidEN := Request{Platform: "android", Language: "en-us", Country: "US", Time: monday10.Add(10 * time.Hour)}
+
+	tests := []struct {
+		name string
+		expr string
+		req  Request
+		want bool
+	}{
+		{name: "platform equal", expr: `platform == "ios"`, req: iosDE, want: true},
+		{name: "platform case insensitive", expr: `platform == "iOS"`, req: iosDE, want: true},
+		{name: "platform not equal", expr: `platform != "ios"`, req: androidEN, want: true},
+		{name: "base language matches region", expr: `lang == "de"`, req: iosDE, want: true},
+		{name: "region does not match other region", expr: `lang == "de-ch"`, req: iosDE, want: false},
+		{name: "base language does not match prefix word", expr: `lang == "d"`, req: iosDE, want: false},
+		{name: "country in list", expr: `country in ["DE", "AT", "CH"]`, req: iosDE, want: true},
+		{name: "country not in list", expr: `country not in ["DE", "AT"]`, req: androidEN, want: true},
+		{name: "referrer contains", expr: `referrer contains "google"`, req: iosDE, want: true},
+		{name: "empty referrer", expr: `referrer == ""`, req: androidEN, want: true},
+		{name: "business hours", expr: `hour >= 9 && hour < 17`, req: iosDE, want: true},
+		{name: "outside business hours", expr: `hour >= 9 && hour < 17`, req: androidEN, want: false},
+		{name: "hour in list", expr: `hour in [10, 11]`, req: iosDE, want: true},
+		{name: "weekday", expr: `weekday in ["sat", "sun"]`, req: iosDE, want: false},
+		{name: "and binds tighter than or", expr: `platform == "android" || platform == "ios" && country == "US"`, req: iosDE, want: false},
+		{name: "parentheses", expr: `(platform == "android" || platform == "ios") && country == "AT"`, req: iosDE, want: true},
+		{name: "negation", expr: `!(platform == "ios")`, req: iosDE, want: false},
+		{name: "double negation", expr: `!!(platform == "ios")`, req: iosDE, want: true},
+		{name: "escaped string", expr: `referrer == "a\"b"`, req: Request{Referrer: `a"b`}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("Compile(%q) error = %v", tt.expr, err)
+			}
+			if got := expr.Match(tt.req); got != tt.want {
+				t.Errorf("Compile(%q).Match() = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantMsg string
+	}{
+		{name: "empty", expr: "", wantMsg: "unexpected end"},
+		{name: "unknown field", expr: `os == "ios"`, wantMsg: "unknown field"},
+		{name: "missing operator", expr: `platform "ios"`, wantMsg: "expected operator"},
+		{name: "string compared to number", expr: `hour == "9"`, wantMsg: "needs a number"},
+		{name: "number compared to string", expr: `platform == 1`, wantMsg: "needs a string"},
+		{name: "ordering on string field", expr: `platform < "m"`, wantMsg: "numeric field"},
+		{name: "contains on number", expr: `hour contains 1`, wantMsg: "string field"},
+		{name: "unterminated string", expr: `platform == "ios`, wantMsg: "unterminated"},
+		{name: "unclosed paren", expr: `(platform == "ios"`, wantMsg: "expected )"},
+		{name: "unclosed list", expr: `country in ["DE"`, wantMsg: "expected , or ]"},
+		{name: "in without list", expr: `country in "DE"`, wantMsg: "expected ["},
+		{name: "not without in", expr: `country not "DE"`, wantMsg: `expected "in"`},
+		{name: "trailing tokens", expr: `platform == "ios" "android"`, wantMsg: "unexpected"},
+		{name: "function call", expr: `exec("rm")`, wantMsg: "unknown field"},
+		{name: "illegal character", expr: `platform == 'ios'`, wantMsg: "unexpected character"},
+		{name: "too long", expr: strings.Repeat(" ", MaxExprLen+1), wantMsg: "longer than"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.expr)
+			var serr *SyntaxError
+			if !errors.As(err, &serr) {
+				t.Fatalf("Compile(%q) error = %v, want *SyntaxError", tt.expr, err)
+			}
+			if !strings.Contains(serr.Msg, tt.wantMsg) {
+				t.Errorf("Compile(%q) error = %q, want it to contain %q", tt.expr, serr.Msg, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestFromHTTP(t *testing.T) {
+	tests := []struct {
+		name           string
+		userAgent      string
+		acceptLanguage string
+		referer        string
+		countryHeader  string
+		want           Request
+	}{
+		{
+			name:           "iPhone in Germany",
+			userAgent:      "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15",
+			acceptLanguage: "de-DE,de;q=0.9,en;q=0.8",
+			referer:        "https://www.Google.com/search?q=x",
+			countryHeader:  "de",
+			want:           Request{Platform: "ios", Language: "de-de", Country: "DE", Referrer: "www.google.com"},
+		},
+		{
+			name:           "Android with weighted languages",
+			userAgent:      "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36",
+			acceptLanguage: "en;q=0.5, fr-CH, *;q=0.1",
+			want:           Request{Platform: "android", Language: "fr-ch"},
+		},
+		{
+			name:           "Mac desktop",
+			userAgent:      "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) AppleWebKit/605.1.15",
+			acceptLanguage: "en-US;q=0, ja",
+			want:           Request{Platform: "macos", Language: "ja"},
+		},
+		{
+			name:      "Windows",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64)",
+			want:      Request{Platform: "windows"},
+		},
+		{
+			name:      "unknown client",
+			userAgent: "curl/8.5.0",
+			want:      Request{Platform: "other"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/abc", nil)
+			r.Header.Set("User-Agent", tt.userAgent)
+			r.Header.Set("Accept-Language", tt.acceptLanguage)
+			r.Header.Set("Referer", tt.referer)
+			r.Header.Set("CF-IPCountry", tt.countryHeader)
+
+			got := FromHTTP(r, HeaderCountryResolver{Header: "CF-IPCountry"})
+			if got != tt.want {
+				t.Errorf("FromHTTP() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package targeting
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CountryResolver 根据请求判断访问者所在国家，返回 ISO 3166-1 alpha-2 代码，无法判断时返回空字符串
+type CountryResolver interface {
+	Country(r *http.Request) string
+}
+
+// HeaderCountryResolver 从 CDN 或反向代理设置的请求头(如 CF-IPCountry)读取国家代码
+type HeaderCountryResolver struct {
+	Header string
+}
+
+func (h HeaderCountryResolver) Country(r *http.Request) string {
+	return strings.ToUpper(strings.TrimSpace(r.Header.Get(h.Header)))
+}
+
+// FromHTTP 从 HTTP 请求提取规则可见的属性，Time 由调用方按自己的时钟填写
+// resolver 为空时 Country 为空字符串
+func FromHTTP(r *http.Request, resolver CountryResolver) Request {
+	req := Request{
+		Platform: Platform(r.UserAgent()),
+		Language: PreferredLanguage(r.Header.Get("Accept-Language")),
+		Referrer: refererHost(r.Referer()),
+	}
+	if resolver != nil {
+		req.Country = resolver.Country(r)
+	}
+	return req
+}
+
+// Platform 根据 User-Agent 粗略判断操作系统：ios, android, windows, macos, linux 或 other
+func Platform(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	// iOS 的 UA 同时包含 "like Mac OS X"，Android 的 UA 同时包含 "Linux"，需要先判断
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ipod"):
+		return "ios"
+	case strings.Contains(ua, "android"):
+		return "android"
+	case strings.Contains(ua, "windows"):
+		return "windows"
+	case strings.Contains(ua, "macintosh"), strings.Contains(ua, "mac os x"):
+		return "macos"
+	case strings.Contains(ua, "linux"), strings.Contains(ua, "x11"):
+		return "linux"
+	}
+	return "other"
+}
+
+// PreferredLanguage 返回 Accept-Language 中权重最高的语言标签(小写)，忽略 * 与 q=0
+func PreferredLanguage(header string) string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var langs []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+		langs = append(langs, weighted{tag, q})
+	}
+	if len(langs) == 0 {
+		return ""
+	}
+	// 权重相同时保持出现顺序
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+	return langs[0].tag
+}
+
+func refererHost(referer string) string {
+	if referer == "" {
+		return ""
+	}
+	u, err := url.Parse(referer)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
@@ -0,0 +1,288 @@
+package urlnorm
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// 校验失败原因，取值稳定，可直接返回给 API 调用方
+const (
+	ReasonEmpty            = "empty"
+	ReasonTooLong          = "too_long"
+	ReasonControlChars     = "control_characters"
+	ReasonMalformed        = "malformed"
+	ReasonNotAbsolute      = "not_absolute"
+	ReasonSchemeNotAllowed = "scheme_not_allowed"
+	ReasonMissingHost      = "missing_host"
+	ReasonInvalidHost      = "invalid_host"
+	ReasonInvalidPort      = "invalid_port"
+	ReasonCredentialsInURL = "credentials_not_allowed"
+	defaultMaxLength       = 2048
+)
+
+// DefaultTrackingParams 是默认剔除的点击追踪参数
+// utm_* 不在其中：营销链接通常有意携带它们
+var DefaultTrackingParams = []string{"fbclid", "gclid", "dclid", "gbraid", "wbraid", "msclkid", "yclid", "igshid", "mc_eid", "_ga"}
+
+// Error 描述 URL 未通过校验的原因
+type Error struct {
+	Reason string
+	Detail string
+}
+
+func (e *Error) Error() string {
+	if e.Detail == "" {
+		return "urlnorm: " + e.Reason
+	}
+	return fmt.Sprintf("urlnorm: %s: %s", e.Reason, e.Detail)
+}
+
+type Options struct {
+	// AllowedSchemes 允许的 scheme(小写)，为空时只允许 http 与 https
+	AllowedSchemes []string
+	// StripDefaultPort 去掉与 scheme 对应的默认端口，如 https 的 443
+	StripDefaultPort bool
+	// StripFragment 去掉 # 之后的片段
+	StripFragment bool
+	// TrackingParams 需要剔除的查询参数名，以 * 结尾表示前缀匹配，例如 "utm_*"
+	TrackingParams []string
+	// MaxLength 输入的最大字节数，<= 0 时使用 2048
+	MaxLength int
+}
+
+// Normalizer 校验长链接并输出规范形式，构建后只读，可并发使用
+type Normalizer struct {
+	schemes       map[string]bool
+	stripPort     bool
+	stripFragment bool
+	exactParams   map[string]bool
+	prefixParams  []string
+	maxLength     int
+}
+
+func New(opts Options) *Normalizer {
+	if len(opts.AllowedSchemes) == 0 {
+		opts.AllowedSchemes = []string{"http", "https"}
+	}
+	if opts.MaxLength <= 0 {
+		opts.MaxLength = defaultMaxLength
+	}
+	n := &Normalizer{
+		schemes:       make(map[string]bool, len(opts.AllowedSchemes)),
+		stripPort:     opts.StripDefaultPort,
+		stripFragment: opts.StripFragment,
+		exactParams:   make(map[string]bool, len(opts.TrackingParams)),
+		maxLength:     opts.MaxLength,
+	}
+	for _, s := range opts.AllowedSchemes {
+		n.schemes[strings.ToLower(s)] = true
+	}
+	for _, p := range opts.TrackingParams {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			n.prefixParams = append(n.prefixParams, prefix)
+			continue
+		}
+		n.exactParams[p] = true
+	}
+	return n
+}
+
+// Normalize 校验 raw 并返回规范化后的 URL，失败时返回 *Error
+func (n *Normalizer) Normalize(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", &Error{Reason: ReasonEmpty}
+	}
+	if len(raw) > n.maxLength {
+		return "", &Error{Reason: ReasonTooLong, Detail: fmt.Sprintf("%d bytes exceeds limit of %d", len(raw), n.maxLength)}
+	}
+	for _, c := range raw {
+		if c < 0x20 || c == 0x7f {
+			return "", &Error{Reason: ReasonControlChars}
+		}
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", &Error{Reason: ReasonMalformed, Detail: err.Error()}
+	}
+	if !u.IsAbs() {
+		return "", &Error{Reason: ReasonNotAbsolute}
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if !n.schemes[u.Scheme] {
+		return "", &Error{Reason: ReasonSchemeNotAllowed, Detail: u.Scheme}
+	}
+	if u.Opaque != "" || u.Host == "" {
+		return "", &Error{Reason: ReasonMissingHost}
+	}
+	// https://trusted.com@evil.com 这类写法常被用来伪装真实目的地
+	if u.User != nil {
+		return "", &Error{Reason: ReasonCredentialsInURL}
+	}
+
+	host, err := normalizeHost(u.Hostname())
+	if err != nil {
+		return "", err
+	}
+	port := u.Port()
+	if port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil || p < 1 || p > 65535 {
+			return "", &Error{Reason: ReasonInvalidPort, Detail: port}
+		}
+		if n.stripPort && isDefaultPort(u.Scheme, port) {
+			port = ""
+		}
+	}
+	switch {
+	case port != "":
+		u.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		u.Host = "[" + host + "]"
+	default:
+		u.Host = host
+	}
+
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	if n.stripFragment {
+		u.Fragment = ""
+		u.RawFragment = ""
+	}
+	u.RawQuery = n.stripTrackingParams(u.RawQuery)
+	u.ForceQuery = false
+	return u.String(), nil
+}
+
+// normalizeHost 小写主机名并把 IDN 转换为 punycode，IP 字面量原样(小写)保留
+// 整数、十六进制、八进制与简写形式的 IPv4(如 167772161、0x7f.1、10.1)转换为点分十进制，与浏览器访问的地址一致
+func normalizeHost(host string) (string, error) {
+	host = strings.TrimSuffix(host, ".")
+	if host == "" {
+		return "", &Error{Reason: ReasonMissingHost}
+	}
+	if addr, ok, err := ParseIPv4(host); ok {
+		if err != nil {
+			return "", &Error{Reason: ReasonInvalidHost, Detail: err.Error()}
+		}
+		return addr.String(), nil
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return strings.ToLower(ip.String()), nil
+	}
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", &Error{Reason: ReasonInvalidHost, Detail: err.Error()}
+	}
+	for _, label := range strings.Split(ascii, ".") {
+		if label == "" {
+			return "", &Error{Reason: ReasonInvalidHost, Detail: "empty label"}
+		}
+	}
+	return strings.ToLower(ascii), nil
+}
+
+// ParseIPv4 按 WHATWG URL 标准识别并解析 IPv4 主机：最后一个标签是数字(十进制或 0x 十六进制)时整个主机按 IPv4 解析，
+// 每段可以是十进制、0x 开头的十六进制或 0 开头的八进制，少于四段时最后一段占满剩余的字节。
+// host 不是 IPv4 形式时 ok 为 false；是 IPv4 形式但数值超出范围时 ok 为 true 并返回错误，浏览器同样拒绝这样的主机
+func ParseIPv4(host string) (addr netip.Addr, ok bool, err error) {
+	host = strings.TrimSuffix(host, ".")
+	parts := strings.Split(host, ".")
+	if !endsInNumber(parts[len(parts)-1]) {
+		return netip.Addr{}, false, nil
+	}
+	if len(parts) > 4 {
+		return netip.Addr{}, true, fmt.Errorf("too many parts in IPv4 address %q", host)
+	}
+	var ipv4 uint64
+	for i, part := range parts {
+		n, err := parseIPv4Number(part)
+		if err != nil {
+			return netip.Addr{}, true, fmt.Errorf("invalid IPv4 part %q in %q", part, host)
+		}
+		if i < len(parts)-1 {
+			if n > 255 {
+				return netip.Addr{}, true, fmt.Errorf("IPv4 part %q in %q out of range", part, host)
+			}
+			ipv4 |= n << (8 * (3 - i))
+			continue
+		}
+		if n >= 1<<(8*(5-len(parts))) {
+			return netip.Addr{}, true, fmt.Errorf("IPv4 part %q in %q out of range", part, host)
+		}
+		ipv4 |= n
+	}
+	return netip.AddrFrom4([4]byte{byte(ipv4 >> 24), byte(ipv4 >> 16), byte(ipv4 >> 8), byte(ipv4)}), true, nil
+}
+
+// endsInNumber 判断主机的最后一个标签是否为数字：全部是十进制数字，或 0x 后只有十六进制数字
+func endsInNumber(label string) bool {
+	if len(label) >= 2 && (label[:2] == "0x" || label[:2] == "0X") {
+		return strings.Trim(label[2:], "0123456789abcdefABCDEF") == ""
+	}
+	return label != "" && strings.Trim(label, "0123456789") == ""
+}
+
+// parseIPv4Number 解析 IPv4 的一段：0x 前缀为十六进制，0 开头为八进制，其余为十进制
+func parseIPv4Number(s string) (uint64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty IPv4 part")
+	}
+	base := 10
+	switch {
+	case len(s) >= 2 && (s[:2] == "0x" || s[:2] == "0X"):
+		s, base = s[2:], 16
+		if s == "" {
+			return 0, nil
+		}
+	case len(s) >= 2 && s[0] == '0':
+		s, base = s[1:], 8
+	}
+	return strconv.ParseUint(s, base, 32)
+}
+
+func isDefaultPort(scheme, port string) bool {
+	return (scheme == "http" && port == "80") || (scheme == "https" && port == "443")
+}
+
+// stripTrackingParams 按原顺序保留非追踪参数；url.Values.Encode 会重排参数，这里刻意不用
+func (n *Normalizer) stripTrackingParams(rawQuery string) string {
+	if rawQuery == "" || (len(n.exactParams) == 0 && len(n.prefixParams) == 0) {
+		return rawQuery
+	}
+	parts := strings.Split(rawQuery, "&")
+	kept := parts[:0]
+	for _, part := range parts {
+		if part == "" {
+			continue
+		}
+		key, _, _ := strings.Cut(part, "=")
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		if n.isTrackingParam(key) {
+			continue
+		}
+		kept = append(kept, part)
+	}
+	return strings.Join(kept, "&")
+}
+
+func (n *Normalizer) isTrackingParam(key string) bool {
+	if n.exactParams[key] {
+		return true
+	}
+	for _, prefix := range n.prefixParams {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package urlnorm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizer_Normalize(t *testing.T) {
+	defaults := Options{StripDefaultPort: true, TrackingParams: DefaultTrackingParams}
+
+	tests := []struct {
+		name       string
+		opts       Options
+		raw        string
+		want       string
+		wantReason string
+	}{
+		{name: "already canonical", opts: defaults, raw: "https://example.com/a?b=1", want: "https://example.com/a?b=1"},
+		{name: "surrounding whitespace", opts: defaults, raw: "  https://example.com/a  ", want: "https://example.com/a"},
+		{name: "scheme and host are lowercased", opts: defaults, raw: "HTTPS://Example.COM/Path", want: "https://example.com/Path"},
+		{name: "empty path becomes root", opts: defaults, raw: "https://example.com", want: "https://example.com/"},
+		{name: "default port stripped", opts: defaults, raw: "http://example.com:80/a", want: "http://example.com/a"},
+		{name: "default port kept when not configured", opts: Options{}, raw: "https://example.com:443/a", want: "https://example.com:443/a"},
+		{name: "non default port kept", opts: defaults, raw: "https://example.com:8443/a", want: "https://example.com:8443/a"},
+		{name: "fragment kept by default", opts: defaults, raw: "https://example.com/a#top", want: "https://example.com/a#top"},
+		{name: "fragment stripped when configured", opts: Options{StripFragment: true}, raw: "https://example.com/a#top", want: "https://example.com/a"},
+		{name: "tracking params removed in order", opts: defaults, raw: "https://example.com/?b=2&fbclid=x&a=1&gclid=y", want: "https://example.com/?b=2&a=1"},
+		{name: "prefix tracking params", opts: Options{TrackingParams: []string{"utm_*"}}, raw: "https://example.com/?utm_source=x&utm_medium=y&id=3", want: "https://example.com/?id=3"},
+		{name: "IDN host to punycode", opts: defaults, raw: "https://Bücher.de/", want: "https://xn--bcher-kva.de/"},
+		{name: "IPv6 literal", opts: defaults, raw: "http://[2001:DB8::1]:80/", want: "http://[2001:db8::1]/"},
+		{name: "IPv4 as one number", opts: defaults, raw: "http://167772161/", want: "http://10.0.0.1/"},
+		{name: "IPv4 in hex", opts: defaults, raw: "http://0x0a000001/", want: "http://10.0.0.1/"},
+		{name: "IPv4 short form", opts: defaults, raw: "http://10.1/", want: "http://10.0.0.1/"},
+		{name: "IPv4 mixed hex short form", opts: defaults, raw: "http://0x7f.1/", want: "http://127.0.0.1/"},
+		{name: "IPv4 octal part", opts: defaults, raw: "http://0177.0.0.1:8080/", want: "http://127.0.0.1:8080/"},
+		{name: "numeric label not last is a hostname", opts: defaults, raw: "https://1.example.com/", want: "https://1.example.com/"},
+		{name: "trailing dot in host", opts: defaults, raw: "https://example.com./a", want: "https://example.com/a"},
+		{name: "custom scheme allow-list", opts: Options{AllowedSchemes: []string{"https", "ftp"}}, raw: "ftp://files.example.com/x", want: "ftp://files.example.com/x"},
+
+		{name: "empty", opts: defaults, raw: "   ", wantReason: ReasonEmpty},
+		{name: "too long", opts: Options{MaxLength: 30}, raw: "https://example.com/" + strings.Repeat("a", 20), wantReason: ReasonTooLong},
+		{name: "control characters", opts: defaults, raw: "https://example.com/\x00", wantReason: ReasonControlChars},
+		{name: "javascript scheme", opts: defaults, raw: "javascript:alert(1)", wantReason: ReasonSchemeNotAllowed},
+		{name: "ftp not allowed by default", opts: defaults, raw: "ftp://files.example.com/", wantReason: ReasonSchemeNotAllowed},
+		{name: "relative path", opts: defaults, raw: "/just/a/path", wantReason: ReasonNotAbsolute},
+		{name: "protocol relative", opts: defaults, raw: "//example.com/a", wantReason: ReasonNotAbsolute},
+		{name: "garbage", opts: defaults, raw: "not a url", wantReason: ReasonNotAbsolute},
+		{name: "opaque http", opts: defaults, raw: "http:example.com", wantReason: ReasonMissingHost},
+		{name: "missing host", opts: defaults, raw: "https:///path", wantReason: ReasonMissingHost},
+		{name: "credentials", opts: defaults, raw: "https://trusted.com@evil.com/", wantReason: ReasonCredentialsInURL},
+		{name: "invalid label", opts: defaults, raw: "https://-bad-.com/", wantReason: ReasonInvalidHost},
+		{name: "empty label", opts: defaults, raw: "https://a..com/", wantReason: ReasonInvalidHost},
+		{name: "IPv4 part out of range", opts: defaults, raw: "http://256.0.0.1/", wantReason: ReasonInvalidHost},
+		{name: "IPv4 number out of range", opts: defaults, raw: "http://4294967296/", wantReason: ReasonInvalidHost},
+		{name: "IPv4 too many parts", opts: defaults, raw: "http://1.2.3.4.5/", wantReason: ReasonInvalidHost},
+		{name: "IPv4 invalid octal", opts: defaults, raw: "http://09.0.0.1/", wantReason: ReasonInvalidHost},
+		{name: "port out of range", opts: defaults, raw: "https://example.com:99999/", wantReason: ReasonInvalidPort},
+		{name: "malformed escape", opts: defaults, raw: "https://example.com/%zz", wantReason: ReasonMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(tt.opts).Normalize(tt.raw)
+			if tt.wantReason != "" {
+				var nerr *Error
+				if !errors.As(err, &nerr) {
+					t.Fatalf("Normalize(%q) error = %v, want *Error with reason %q", tt.raw, err, tt.wantReason)
+				}
+				if nerr.Reason != tt.wantReason {
+					t.Errorf("Normalize(%q) reason = %q, want %q", tt.raw, nerr.Reason, tt.wantReason)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Normalize(%q) unexpected error = %v", tt.raw, err)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,23 +2,44 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"shortlink/internal/api/http/handler"
 	"shortlink/internal/api/http/server"
+	"shortlink/internal/cli"
 	"shortlink/internal/config"
+	"shortlink/internal/healthcheck"
 	"shortlink/internal/idgen"
+	"shortlink/internal/metadata"
+	"shortlink/internal/metrics"
+	"shortlink/internal/policy"
 	"shortlink/internal/shortener"
 	"shortlink/internal/storage"
+	"shortlink/internal/tagging"
+	"shortlink/internal/targeting"
+	"shortlink/internal/urlnorm"
 	"syscall"
 	"time"
 )
 
 func main() {
-	c, _ := config.LoadConfig()
+	// 子命令模式：shortlink idgen ...
+	if len(os.Args) > 1 && os.Args[1] == "idgen" {
+		if err := cli.RunIdgen(os.Args[2:], os.Stdout, os.Stderr); err != nil {
+			fmt.Fprintln(os.Stderr, "shortlink idgen:", err)
+			os.Exit(1)
+		}
+		return
+	}
 	// 使用标准库
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
-	log.Println("Starting shortlink service", "version", "shortlink-demo1")
+	c, err := config.LoadConfig()
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+	log.Println("Starting shortlink service", "version", "shortlink-demo1", "idgen", c.IDGen.Mode)
 	// 初始化依赖
 	storeImpl := storage.NewMemoryStore()
 	defer func() {
@@ -26,18 +47,99 @@ func main() {
 			log.Println("Failed to close storage:", err)
 		}
 	}()
-	idGenImpl := idgen.NewGenerator()
+	registry := metrics.NewRegistry()
+	idGenImpl, err := idgen.New(c.IDGen.Options(registry.Counter("idgen_blocked_total")))
+	if err != nil {
+		log.Fatal("Failed to create id generator:", err)
+	}
 
-	shortenerSvc := shortener.NewService(shortener.Config{
-		Store:         storeImpl,
-		Generator:     idGenImpl,
-		MaxGenAttemps: 3,
-	})
+	// 后台任务(策略热加载等)的生命周期与进程一致，退出时统一取消
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	svcCfg := shortener.Config{
+		Store:                   storeImpl,
+		Generator:               idGenImpl,
+		URLNormalizer:           urlnorm.New(c.URL.Options()),
+		CheckPolicyOnRedirect:   c.Policy.CheckOnRedirect,
+		PublicHosts:             c.Chain.PublicHosts,
+		KnownShorteners:         c.Chain.KnownShorteners,
+		StoreFinalDestination:   c.Chain.StoreFinal,
+		ExhaustedFallbackURL:    c.Access.ExhaustedFallbackURL,
+		Location:                c.Targeting.Location,
+		DefaultRedirectType:     c.Redirect.Type,
+		PermanentRedirectMaxAge: c.Redirect.PermanentMaxAge,
+		MaxGenAttemps:           3,
+		Context:                 bgCtx,
+	}
+	if c.Chain.Resolve {
+		svcCfg.ChainResolver = shortener.NewChainResolver(shortener.ChainOptions{Timeout: c.Chain.Timeout, MaxHops: c.Chain.MaxHops})
+	}
+	if c.Policy.File != "" {
+		watcher, err := policy.NewWatcher(c.Policy.File, c.Policy.ReloadInterval, nil)
+		if err != nil {
+			log.Fatal("Failed to load destination policy:", err)
+		}
+		go watcher.Run(bgCtx)
+		svcCfg.Policy = watcher
+	}
+	if c.Tagging.File != "" {
+		policies, err := tagging.LoadFile(c.Tagging.File)
+		if err != nil {
+			log.Fatal("Failed to load tagging policies:", err)
+		}
+		svcCfg.Tagging = policies
+	}
+	if c.Metadata.Fetch {
+		svcCfg.MetadataFetcher = metadata.NewFetcher(metadata.Options{Timeout: c.Metadata.Timeout, MaxBytes: c.Metadata.MaxBytes})
+		svcCfg.MetadataConcurrency = c.Metadata.Concurrency
+	}
+	shortenerSvc := shortener.NewService(svcCfg)
 	if shortenerSvc == nil {
 		log.Fatal("Failed to create shortener service")
 	}
+	if c.Health.Check {
+		checker, err := healthcheck.New(healthcheck.Config{
+			Store:            storeImpl,
+			Interval:         c.Health.Interval,
+			RetryDelay:       c.Health.RetryDelay,
+			MaxBackoff:       c.Health.MaxBackoff,
+			Timeout:          c.Health.Timeout,
+			FailureThreshold: c.Health.FailureThreshold,
+			PerHost:          c.Health.PerHost,
+		})
+		if err != nil {
+			log.Fatal("Failed to create health checker:", err)
+		}
+		go checker.Run(bgCtx)
+	}
+	linkAPIOpts := handler.Options{
+		CookieSecret:           []byte(c.Access.CookieSecret),
+		UnlockTTL:              c.Access.UnlockTTL,
+		PasswordAttempts:       c.Access.PasswordAttempts,
+		PasswordWindow:         c.Access.PasswordWindow,
+		BrokenLinkInterstitial: c.Health.Interstitial,
+		BatchMaxItems:          c.Batch.MaxItems,
+		BatchConcurrency:       c.Batch.Concurrency,
+		IdempotencyTTL:         c.IdempotencyTTL,
+		BaseURL:                c.Server.BaseURL,
+	}
+	if c.Targeting.CountryHeader != "" {
+		linkAPIOpts.CountryResolver = targeting.HeaderCountryResolver{Header: c.Targeting.CountryHeader}
+	}
+	var accessLog []server.Group
+	for _, g := range c.Server.AccessLog {
+		accessLog = append(accessLog, server.Group(g))
+	}
 	// 创建http服务器
-	httpServer := server.NewServer(c.Server.Port, shortenerSvc)
+	httpServer := server.NewServer(server.Config{
+		Port:           c.Server.Port,
+		Service:        shortenerSvc,
+		Metrics:        registry,
+		LinkAPI:        linkAPIOpts,
+		TrustedProxies: c.Server.TrustedProxies,
+		AccessLog:      accessLog,
+	})
 	go func() {
 		if err := httpServer.Start(); err != nil {
 			log.Fatal("Failed to start http server:", err)